@@ -0,0 +1,208 @@
+package dmxusbpro
+
+import (
+	"sync"
+	"time"
+)
+
+// Counts of the asynchronous commits, since enabling them or the last reset
+type CommitStatistics struct {
+	// Frames committed
+	Commits uint64
+	// Frames written
+	Frames uint64
+	// Frames superseded by a newer commit before being written
+	Dropped uint64
+	// Frames that could not be written
+	Errors    uint64
+	LastError error
+	// Time writing the last frame took
+	LastLatency time.Duration
+	// Longest time writing a frame took
+	MaxLatency time.Duration
+	// Time writing all frames took, see 'GetAverageLatency'
+	TotalLatency time.Duration
+}
+
+// Returns the average time writing a frame took
+func (s CommitStatistics) GetAverageLatency() time.Duration {
+	if s.Frames == 0 {
+		return 0
+	}
+	return s.TotalLatency / time.Duration(s.Frames)
+}
+
+// Routine writing the latest committed frame, dropping frames superseded while writing
+type asyncWriter struct {
+	mu sync.Mutex
+	// Closed to stop the routine, nil if not running
+	stop chan struct{}
+	// Closed when the routine returned, after writing the pending frame
+	done chan struct{}
+	// Signals a pending frame to the routine
+	wake chan struct{}
+	// Frame waiting to be written, and the one being written
+	pending    []byte
+	hasPending bool
+	writing    []byte
+	stats      CommitStatistics
+}
+
+func (w *asyncWriter) start(send func(frame []byte) error, onError func()) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.stop != nil {
+		return
+	}
+	w.stop = make(chan struct{})
+	w.done = make(chan struct{})
+	w.wake = make(chan struct{}, 1)
+	w.stats = CommitStatistics{}
+	go w.run(send, onError, w.stop, w.done, w.wake)
+}
+
+// Stop the routine once the pending frame is written, does nothing if not running
+func (w *asyncWriter) halt() {
+	w.mu.Lock()
+	stop := w.stop
+	done := w.done
+	w.stop = nil
+	w.mu.Unlock()
+	if stop == nil {
+		return
+	}
+	close(stop)
+	<-done
+}
+
+func (w *asyncWriter) isRunning() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.stop != nil
+}
+
+func (w *asyncWriter) getStatistics() CommitStatistics {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.stats
+}
+
+func (w *asyncWriter) resetStatistics() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.stats = CommitStatistics{}
+}
+
+/*
+Hand the frame to the routine, replacing a frame not written yet.
+
+Sends the frame right away if the routine is not running, after a stopping routine wrote its pending frame.
+*/
+func (w *asyncWriter) commit(frame []byte, send func(frame []byte) error) error {
+	w.mu.Lock()
+	if w.stop == nil {
+		done := w.done
+		w.mu.Unlock()
+		if done != nil {
+			<-done
+		}
+		return send(frame)
+	}
+	w.stats.Commits++
+	if w.hasPending {
+		w.stats.Dropped++
+	}
+	w.pending = append(w.pending[:0], frame...)
+	w.hasPending = true
+	wake := w.wake
+	w.mu.Unlock()
+	select {
+	case wake <- struct{}{}:
+	default:
+		// Already woken, the routine picks up the latest frame
+	}
+	return nil
+}
+
+func (w *asyncWriter) run(send func(frame []byte) error, onError func(), stop <-chan struct{}, done chan<- struct{}, wake <-chan struct{}) {
+	defer close(done)
+	for {
+		select {
+		case <-wake:
+			w.writePending(send, onError)
+		case <-stop:
+			w.writePending(send, onError)
+			return
+		}
+	}
+}
+
+func (w *asyncWriter) writePending(send func(frame []byte) error, onError func()) {
+	w.mu.Lock()
+	if !w.hasPending {
+		w.mu.Unlock()
+		return
+	}
+	w.pending, w.writing = w.writing, w.pending
+	w.hasPending = false
+	w.mu.Unlock()
+
+	start := time.Now()
+	err := send(w.writing)
+	latency := time.Since(start)
+
+	w.mu.Lock()
+	if err != nil {
+		w.stats.Errors++
+		w.stats.LastError = err
+	} else {
+		w.stats.Frames++
+		w.stats.LastLatency = latency
+		w.stats.TotalLatency += latency
+		if latency > w.stats.MaxLatency {
+			w.stats.MaxLatency = latency
+		}
+	}
+	w.mu.Unlock()
+	if err != nil {
+		onError()
+	}
+}
+
+/*
+Let 'Commit' return right away, while a routine writes the frames.
+
+The routine always writes the latest committed frame, frames committed while it is writing are superseded and dropped.
+Write errors are not returned by 'Commit', but counted by 'GetCommitStatistics'.
+
+Example useage:
+
+	controller.EnableAsyncCommit()
+	for {
+		render(controller) // stage the next frame
+		controller.Commit() // does not wait for the widget
+	}
+*/
+func (d *EnttecDMXUSBProController) EnableAsyncCommit() {
+	d.async.start(d.sendFrame, d.frames.markDirty)
+}
+
+// Let 'Commit' wait for the frame to be written again, after writing the pending frame
+func (d *EnttecDMXUSBProController) DisableAsyncCommit() {
+	d.async.halt()
+}
+
+// Returns true between 'EnableAsyncCommit' and 'DisableAsyncCommit'
+func (d *EnttecDMXUSBProController) IsAsyncCommit() bool {
+	return d.async.isRunning()
+}
+
+// Returns the counts of the asynchronous commits since enabling them or the last 'ResetCommitStatistics'
+func (d *EnttecDMXUSBProController) GetCommitStatistics() CommitStatistics {
+	return d.async.getStatistics()
+}
+
+// Set all counts of the asynchronous commits to 0
+func (d *EnttecDMXUSBProController) ResetCommitStatistics() {
+	d.async.resetStatistics()
+}
@@ -0,0 +1,140 @@
+package dmxusbpro
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/H3rby7/usbdmx-golang/controller/enttec/dmxusbpro/messages"
+)
+
+// Writer holding each write until released
+type gateWriter struct {
+	entered chan []byte
+	release chan struct{}
+}
+
+func newGateWriter() *gateWriter {
+	return &gateWriter{entered: make(chan []byte, 16), release: make(chan struct{})}
+}
+
+func (g *gateWriter) Write(data []byte) (int, error) {
+	g.entered <- append([]byte{}, data...)
+	<-g.release
+	return len(data), nil
+}
+
+// Wait for the next write and return the payload of the frame written
+func (g *gateWriter) nextFrame(t *testing.T) []byte {
+	select {
+	case data := <-g.entered:
+		msg, err := messages.FromBytes(data)
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		return msg.GetPayload()
+	case <-time.After(time.Second):
+		t.Fatalf("expected a frame to be written")
+	}
+	return nil
+}
+
+// Writer failing every write
+type failingWriter struct{}
+
+func (w failingWriter) Write(data []byte) (int, error) {
+	return 0, errors.New("cable pulled")
+}
+
+func TestAsyncCommitDropsSupersededFrames(t *testing.T) {
+	gate := newGateWriter()
+	d := newFakeController(t, &fakeTransport{w: gate}, true)
+	d.EnableAsyncCommit()
+	d.Stage(1, 1)
+	if err := d.Commit(); err != nil {
+		t.Fatalf("expected no error on commit, but got %v", err)
+	}
+	first := gate.nextFrame(t)
+	// The first frame is still being written, commits return right away
+	for v := byte(2); v <= 4; v++ {
+		d.Stage(1, v)
+		if err := d.Commit(); err != nil {
+			t.Fatalf("expected no error on commit, but got %v", err)
+		}
+	}
+	close(gate.release)
+	second := gate.nextFrame(t)
+	d.DisableAsyncCommit()
+	if !bytes.Equal(first, []byte{0, 1, 0, 0}) || !bytes.Equal(second, []byte{0, 4, 0, 0}) {
+		t.Errorf("expected the first and the latest frame to be written, but were %v and %v", first, second)
+	}
+	select {
+	case data := <-gate.entered:
+		t.Errorf("expected superseded frames to be dropped, but %v was written", data)
+	default:
+	}
+	stats := d.GetCommitStatistics()
+	if stats.Commits != 4 || stats.Frames != 2 || stats.Dropped != 2 {
+		t.Errorf("expected 4 commits, 2 frames and 2 dropped, but got %+v", stats)
+	}
+}
+
+func TestAsyncCommitLatency(t *testing.T) {
+	gate := newGateWriter()
+	d := newFakeController(t, &fakeTransport{w: gate}, true)
+	d.EnableAsyncCommit()
+	d.Commit()
+	gate.nextFrame(t)
+	time.Sleep(20 * time.Millisecond)
+	close(gate.release)
+	d.DisableAsyncCommit()
+	stats := d.GetCommitStatistics()
+	if stats.LastLatency < 20*time.Millisecond || stats.MaxLatency != stats.LastLatency || stats.GetAverageLatency() != stats.LastLatency {
+		t.Errorf("expected a latency of at least 20ms, but got %+v", stats)
+	}
+}
+
+func TestDisableAsyncCommitWritesPendingFrame(t *testing.T) {
+	gate := newGateWriter()
+	d := newFakeController(t, &fakeTransport{w: gate}, true)
+	d.EnableAsyncCommit()
+	d.Commit()
+	gate.nextFrame(t)
+	d.Stage(2, 2)
+	d.Commit()
+	go func() {
+		// Let the pending frame be written, while disabling waits for it
+		time.Sleep(10 * time.Millisecond)
+		close(gate.release)
+	}()
+	d.DisableAsyncCommit()
+	if d.IsAsyncCommit() {
+		t.Errorf("expected async commit to be disabled")
+	}
+	if frame := gate.nextFrame(t); !bytes.Equal(frame, []byte{0, 0, 2, 0}) {
+		t.Errorf("expected the pending frame to be written, but was %v", frame)
+	}
+	// Written right away again
+	d.Stage(3, 3)
+	d.Commit()
+	if frame := gate.nextFrame(t); !bytes.Equal(frame, []byte{0, 0, 2, 3}) {
+		t.Errorf("expected the frame to be written, but was %v", frame)
+	}
+}
+
+func TestAsyncCommitCountsErrors(t *testing.T) {
+	d := newFakeController(t, &fakeTransport{w: failingWriter{}}, true)
+	d.EnableAsyncCommit()
+	if err := d.Commit(); err != nil {
+		t.Errorf("expected the error not to be returned by commit, but got %v", err)
+	}
+	d.Disconnect()
+	if d.IsAsyncCommit() {
+		t.Errorf("expected async commit to stop when disconnecting")
+	}
+	stats := d.GetCommitStatistics()
+	if stats.Errors != 1 || stats.Frames != 0 || stats.LastError == nil {
+		t.Errorf("expected 1 error, but got %+v", stats)
+	}
+}
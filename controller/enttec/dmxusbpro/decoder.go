@@ -0,0 +1,120 @@
+package dmxusbpro
+
+import (
+	"bytes"
+
+	"github.com/H3rby7/usbdmx-golang/controller/enttec/dmxusbpro/messages"
+)
+
+/*
+Detect messages in a stream of bytes, fed as they arrive.
+
+The end of a message is found using its declared data length, so the payload may contain any bytes.
+Bytes not belonging to a message are dropped, until the next 'MSG_DELIM_START'.
+
+Messages returned by 'Next' share memory with the decoder and are only valid until the next call of 'Feed'.
+
+Example useage:
+
+	decoder := dmxusbpro.NewDecoder()
+	decoder.Feed(readBuf[:n]) // add freshly read bytes
+	for msg, ok := decoder.Next(); ok; msg, ok = decoder.Next() { ... } // handle complete messages
+*/
+type Decoder struct {
+	// Fed bytes, the ones before 'start' are already decoded or dropped
+	buf   []byte
+	start int
+	// Number of bytes dropped since the last call of 'Dropped'
+	dropped int
+	// Labels of messages to detect
+	labels messages.LabelSet
+}
+
+// Helper function for creating a new Decoder
+func NewDecoder() *Decoder {
+	return NewDecoderWithLabels(messages.LabelSet{})
+}
+
+// Helper function for creating a new Decoder, also detecting messages with the extra labels of the set (e.g. 'messages.Mk2Labels')
+func NewDecoderWithLabels(labels messages.LabelSet) *Decoder {
+	return &Decoder{buf: make([]byte, 0, 2*messages.MAXIMUM_MESSAGE_LENGTH), labels: labels}
+}
+
+// Add freshly read bytes, invalidates messages returned before
+func (d *Decoder) Feed(data []byte) {
+	if d.start > 0 {
+		// Reuse the space of decoded messages
+		n := copy(d.buf, d.buf[d.start:])
+		d.buf = d.buf[:n]
+		d.start = 0
+	}
+	d.buf = append(d.buf, data...)
+}
+
+/*
+Returns the next complete message, if any.
+
+Returns false if more bytes need to be fed first.
+*/
+func (d *Decoder) Next() (msg messages.EnttecDMXUSBProApplicationMessage, ok bool) {
+	for {
+		pending := d.buf[d.start:]
+		// Resynchronise at the next start delimiter
+		skip := bytes.IndexByte(pending, messages.MSG_DELIM_START)
+		if skip < 0 {
+			d.drop(len(pending))
+			return msg, false
+		}
+		d.drop(skip)
+		pending = pending[skip:]
+		if len(pending) < messages.NUM_BYTES_BEFORE_PAYLOAD {
+			return msg, false
+		}
+		label := pending[messages.MSG_LABEL_INDEX]
+		dataLength := int(pending[messages.MSG_DATA_LENGTH_LSB_INDEX]) + 256*int(pending[messages.MSG_DATA_LENGTH_MSB_INDEX])
+		if !d.labels.Contains(label) || dataLength > messages.MAXIMUM_DATA_LENGTH {
+			// Not a message header, the start delimiter was just data
+			d.drop(1)
+			continue
+		}
+		size := dataLength + messages.NUM_BYTES_WRAPPER
+		if len(pending) < size {
+			return msg, false
+		}
+		found, err := messages.FromBytesWithLabels(pending[:size], d.labels)
+		if err != nil {
+			d.drop(1)
+			continue
+		}
+		d.start += size
+		return found, true
+	}
+}
+
+/*
+Returns the number of bytes dropped since the last call, as they did not belong to any message.
+
+Includes bytes dropped by 'Reset'.
+*/
+func (d *Decoder) Dropped() int {
+	dropped := d.dropped
+	d.dropped = 0
+	return dropped
+}
+
+// Returns the number of bytes waiting to complete a message
+func (d *Decoder) Buffered() int {
+	return len(d.buf) - d.start
+}
+
+// Drop all buffered bytes, e.g. after reconnecting
+func (d *Decoder) Reset() {
+	d.drop(d.Buffered())
+	d.buf = d.buf[:0]
+	d.start = 0
+}
+
+func (d *Decoder) drop(n int) {
+	d.start += n
+	d.dropped += n
+}
@@ -0,0 +1,150 @@
+package dmxusbpro
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/H3rby7/usbdmx-golang/controller/enttec/dmxusbpro/messages"
+)
+
+// Full DMX frame, containing both delimiters as channel values
+func newTestFrame() []byte {
+	payload := make([]byte, 513)
+	for i := range payload {
+		payload[i] = byte(i)
+	}
+	payload[69] = messages.MSG_DELIM_END
+	payload[96] = messages.MSG_DELIM_START
+	msg := messages.NewEnttecDMXUSBProApplicationMessage(messages.LABEL_RECEIVED_DMX_PACKET, payload)
+	packet, _ := msg.ToBytes()
+	return packet
+}
+
+// Decode everything fed so far
+func decodeAll(decoder *Decoder) []messages.EnttecDMXUSBProApplicationMessage {
+	msgs := make([]messages.EnttecDMXUSBProApplicationMessage, 0)
+	for msg, ok := decoder.Next(); ok; msg, ok = decoder.Next() {
+		msgs = append(msgs, msg)
+	}
+	return msgs
+}
+
+func TestDecoderPayloadContainingDelimiters(t *testing.T) {
+	frame := newTestFrame()
+	decoder := NewDecoder()
+	decoder.Feed(frame)
+	msgs := decodeAll(decoder)
+	if len(msgs) != 1 {
+		t.Fatalf("expected one message, however received %d", len(msgs))
+	}
+	if payload := msgs[0].GetPayload(); !bytes.Equal(payload, frame[4:len(frame)-1]) {
+		t.Errorf("expected payload to be %v, but was %v", frame[4:len(frame)-1], payload)
+	}
+	if dropped := decoder.Dropped(); dropped != 0 {
+		t.Errorf("expected no dropped bytes, but were %d", dropped)
+	}
+}
+
+func TestDecoderPartialFeeds(t *testing.T) {
+	frame := newTestFrame()
+	decoder := NewDecoder()
+	count := 0
+	for i := 0; i < len(frame); i += 7 {
+		end := i + 7
+		if end > len(frame) {
+			end = len(frame)
+		}
+		decoder.Feed(frame[i:end])
+		count += len(decodeAll(decoder))
+	}
+	if count != 1 {
+		t.Errorf("expected one message, however received %d", count)
+	}
+	if buffered := decoder.Buffered(); buffered != 0 {
+		t.Errorf("expected no buffered bytes, but were %d", buffered)
+	}
+}
+
+func TestDecoderMultipleMessages(t *testing.T) {
+	decoder := NewDecoder()
+	decoder.Feed([]byte{0x7E, 10, 4, 0, 1, 2, 3, 4, 0xE7, 0x7E, 3, 0, 0, 0xE7})
+	msgs := decodeAll(decoder)
+	if len(msgs) != 2 {
+		t.Fatalf("expected two messages, however received %d", len(msgs))
+	}
+	if msgs[0].GetLabel() != 10 || msgs[1].GetLabel() != 3 {
+		t.Errorf("expected labels to be 10 and 3, but were %d and %d", msgs[0].GetLabel(), msgs[1].GetLabel())
+	}
+}
+
+func TestDecoderResynchronises(t *testing.T) {
+	decoder := NewDecoder()
+	// Garbage, a start delimiter with invalid label and one with a wrong end, then a message
+	decoder.Feed([]byte{0x11, 0xE7, 0x7E, 0x7E, 0x42, 0, 0, 0x7E, 5, 1, 0, 0, 0x00, 0x7E, 5, 1, 0, 0, 0xE7})
+	msgs := decodeAll(decoder)
+	if len(msgs) != 1 {
+		t.Fatalf("expected one message, however received %d", len(msgs))
+	}
+	if msgs[0].GetLabel() != 5 || !bytes.Equal(msgs[0].GetPayload(), []byte{0}) {
+		t.Errorf("expected label 5 with payload [0], but was label %d with payload %v", msgs[0].GetLabel(), msgs[0].GetPayload())
+	}
+	if dropped := decoder.Dropped(); dropped != 13 {
+		t.Errorf("expected 13 dropped bytes, but were %d", dropped)
+	}
+}
+
+func TestDecoderWaitsForCompleteMessage(t *testing.T) {
+	decoder := NewDecoder()
+	decoder.Feed([]byte{0x11, 0x7E, 5, 3, 0, 0})
+	if _, ok := decoder.Next(); ok {
+		t.Errorf("expected no message yet")
+	}
+	if buffered := decoder.Buffered(); buffered != 5 {
+		t.Errorf("expected 5 buffered bytes, but were %d", buffered)
+	}
+	decoder.Reset()
+	if dropped := decoder.Dropped(); dropped != 6 {
+		t.Errorf("expected 6 dropped bytes, but were %d", dropped)
+	}
+}
+
+// Stream of frames, read in chunks as a serial port would return them
+func newBenchmarkChunks() [][]byte {
+	stream := make([]byte, 0)
+	for i := 0; i < 10; i++ {
+		stream = append(stream, newTestFrame()...)
+	}
+	chunks := make([][]byte, 0)
+	for i := 0; i < len(stream); i += 64 {
+		end := i + 64
+		if end > len(stream) {
+			end = len(stream)
+		}
+		chunks = append(chunks, stream[i:end])
+	}
+	return chunks
+}
+
+func BenchmarkExtract(b *testing.B) {
+	chunks := newBenchmarkChunks()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		oldBuf := make([]byte, 0)
+		for _, chunk := range chunks {
+			_, oldBuf = Extract(append(oldBuf, chunk...))
+		}
+	}
+}
+
+func BenchmarkDecoder(b *testing.B) {
+	chunks := newBenchmarkChunks()
+	decoder := NewDecoder()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		for _, chunk := range chunks {
+			decoder.Feed(chunk)
+			for _, ok := decoder.Next(); ok; _, ok = decoder.Next() {
+			}
+		}
+	}
+}
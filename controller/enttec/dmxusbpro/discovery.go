@@ -0,0 +1,350 @@
+package dmxusbpro
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/H3rby7/usbdmx-golang/controller/enttec/dmxusbpro/messages"
+	"github.com/tarm/serial"
+)
+
+const (
+	// USB vendor ID of the FTDI chip used by the widget
+	FTDI_USB_VENDOR_ID = "0403"
+	// USB product ID of the FTDI chip used by the widget
+	FTDI_USB_PRODUCT_ID = "6001"
+)
+
+// Widget found by 'Discover'
+type DetectedWidget struct {
+	// Path of the serial port, e.g. "/dev/ttyUSB0"
+	Port string
+	// Serial number as printed on the widget case
+	SerialNumber uint32
+	// Firmware version, MSB is the major and LSB the minor version
+	FirmwareVersion uint16
+}
+
+// Options for 'Discover'
+type DiscoveryOptions struct {
+	// Root of the sysfs to look for serial devices
+	SysfsRoot string
+	// Directory containing the device files
+	DevRoot string
+	// Opens a candidate port for probing, 'SerialNumberOpener' marks the port it returns as in use
+	Open func(port string) (Transport, error)
+	// Time each candidate has to answer the probe
+	Timeout time.Duration
+}
+
+// Returns options probing serial ports at 57600 baud, waiting up to 500ms for answers
+func DefaultDiscoveryOptions() DiscoveryOptions {
+	return DiscoveryOptions{
+		SysfsRoot: "/sys",
+		DevRoot:   "/dev",
+		Open: func(port string) (Transport, error) {
+			return openSerial(serial.Config{Name: port, Baud: 57600})
+		},
+		Timeout: 500 * time.Millisecond,
+	}
+}
+
+/*
+Find attached widgets.
+
+Probes every serial device using the widget's FTDI chip (see 'ListCandidatePorts') for its serial number and firmware version.
+Candidates that cannot be opened or do not answer are skipped.
+
+Note: Probing stops the DMX output of a widget in WRITE mode, until the next frame is sent.
+Probing a widget a controller has open, e.g. in another process, also takes replies meant for that controller.
+*/
+func Discover(opts DiscoveryOptions) ([]DetectedWidget, error) {
+	candidates, err := ListCandidatePorts(opts.SysfsRoot, opts.DevRoot)
+	if err != nil {
+		return nil, err
+	}
+	widgets := make([]DetectedWidget, 0, len(candidates))
+	for _, port := range candidates {
+		transport, err := opts.Open(port)
+		if err != nil {
+			continue
+		}
+		widget, err := Probe(transport, opts.Timeout)
+		transport.Close()
+		if err != nil {
+			continue
+		}
+		widget.Port = port
+		widgets = append(widgets, widget)
+	}
+	return widgets, nil
+}
+
+/*
+List the serial devices using the widget's FTDI chip, by scanning the Linux sysfs.
+
+Returns the device paths, e.g. "/dev/ttyUSB0", sorted by name.
+*/
+func ListCandidatePorts(sysfsRoot string, devRoot string) ([]string, error) {
+	ttyClass := filepath.Join(sysfsRoot, "class", "tty")
+	entries, err := os.ReadDir(ttyClass)
+	if err != nil {
+		return nil, fmt.Errorf(ENTTEC_DMX_USB_PRO_LOG_PREFIX+": could not list serial devices: %v", err)
+	}
+	ports := make([]string, 0)
+	for _, entry := range entries {
+		device, err := filepath.EvalSymlinks(filepath.Join(ttyClass, entry.Name(), "device"))
+		if err != nil {
+			// Virtual terminals have no device
+			continue
+		}
+		vendor, product, ok := findUSBIDs(device, sysfsRoot)
+		if ok && vendor == FTDI_USB_VENDOR_ID && product == FTDI_USB_PRODUCT_ID {
+			ports = append(ports, filepath.Join(devRoot, entry.Name()))
+		}
+	}
+	sort.Strings(ports)
+	return ports, nil
+}
+
+// Walk up the device tree to the USB device and read its vendor and product ID
+func findUSBIDs(device string, sysfsRoot string) (vendor string, product string, ok bool) {
+	root, err := filepath.EvalSymlinks(sysfsRoot)
+	if err != nil {
+		return
+	}
+	for dir := device; strings.HasPrefix(dir, root) && dir != root; dir = filepath.Dir(dir) {
+		vendorBytes, vendorErr := os.ReadFile(filepath.Join(dir, "idVendor"))
+		productBytes, productErr := os.ReadFile(filepath.Join(dir, "idProduct"))
+		if vendorErr == nil && productErr == nil {
+			return strings.TrimSpace(string(vendorBytes)), strings.TrimSpace(string(productBytes)), true
+		}
+	}
+	return
+}
+
+/*
+Ask the widget behind the transport for its serial number (label 10) and parameters (label 3).
+
+Fails if the widget does not answer both within the timeout.
+The transport is not closed, but should be, as a pending read may still consume data.
+*/
+func Probe(transport Transport, timeout time.Duration) (DetectedWidget, error) {
+	widget, _, err := probe(transport, timeout)
+	return widget, err
+}
+
+// Like 'Probe', also returning a channel closed when the routine reading the transport stopped
+func probe(transport Transport, timeout time.Duration) (DetectedWidget, <-chan struct{}, error) {
+	widget := DetectedWidget{}
+	stopped := make(chan struct{})
+	requests := []messages.EnttecDMXUSBProApplicationMessage{
+		messages.NewEnttecDMXUSBProApplicationMessage(messages.LABEL_WIDGET_GET_SERIAL_NUMBER_REQUEST, []byte{}),
+		messages.NewEnttecDMXUSBProApplicationMessage(messages.LABEL_GET_WIDGET_PARAMS_REQUEST, []byte{0, 0}),
+	}
+	for _, msg := range requests {
+		packet, err := msg.ToBytes()
+		if err != nil {
+			close(stopped)
+			return widget, stopped, err
+		}
+		if _, err := transport.Write(packet); err != nil {
+			close(stopped)
+			return widget, stopped, fmt.Errorf(ENTTEC_DMX_USB_PRO_LOG_PREFIX+": could not send probe: %v", err)
+		}
+	}
+	replies := make(chan messages.EnttecDMXUSBProApplicationMessage)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		defer close(stopped)
+		readReplies(transport, replies, done)
+	}()
+	deadline := time.After(timeout)
+	hasSerialNumber, hasParameters := false, false
+	for !hasSerialNumber || !hasParameters {
+		select {
+		case msg := <-replies:
+			switch msg.GetLabel() {
+			case messages.LABEL_WIDGET_GET_SERIAL_NUMBER_REPLY:
+				serialNumber, err := messages.ToSerialNumber(msg)
+				if err != nil {
+					return widget, stopped, err
+				}
+				widget.SerialNumber = serialNumber
+				hasSerialNumber = true
+			case messages.LABEL_GET_WIDGET_PARAMS_REPLY:
+				params, err := messages.ToWidgetParameters(msg)
+				if err != nil {
+					return widget, stopped, err
+				}
+				widget.FirmwareVersion = params.FirmwareVersion
+				hasParameters = true
+			}
+		case <-deadline:
+			return widget, stopped, fmt.Errorf(ENTTEC_DMX_USB_PRO_LOG_PREFIX+": no answer to probe within %v", timeout)
+		}
+	}
+	return widget, stopped, nil
+}
+
+// Read messages from the transport until reading fails or 'done' is closed
+func readReplies(transport Transport, replies chan<- messages.EnttecDMXUSBProApplicationMessage, done <-chan struct{}) {
+	readBuf := make([]byte, messages.MAXIMUM_MESSAGE_LENGTH)
+	decoder := NewDecoder()
+	for {
+		n, err := transport.Read(readBuf)
+		if err != nil {
+			return
+		}
+		decoder.Feed(readBuf[:n])
+		for msg, ok := decoder.Next(); ok; msg, ok = decoder.Next() {
+			// Copy, as the message is only valid until the next 'Feed'
+			msg = messages.NewEnttecDMXUSBProApplicationMessage(msg.GetLabel(), append([]byte{}, msg.GetPayload()...))
+			select {
+			case replies <- msg:
+			case <-done:
+				return
+			}
+		}
+		select {
+		case <-done:
+			return
+		default:
+		}
+	}
+}
+
+var (
+	portsInUseMu sync.Mutex
+	// Ports opened by 'SerialOpener' or 'SerialNumberOpener' and not closed yet, counted by path
+	portsInUse = make(map[string]int)
+)
+
+// Mark the port as in use, until the returned function is called
+func claimPort(port string) func() {
+	portsInUseMu.Lock()
+	defer portsInUseMu.Unlock()
+	portsInUse[port]++
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			portsInUseMu.Lock()
+			defer portsInUseMu.Unlock()
+			if portsInUse[port]--; portsInUse[port] <= 0 {
+				delete(portsInUse, port)
+			}
+		})
+	}
+}
+
+// Returns true if the port was opened by this process and not closed yet
+func isPortInUse(port string) bool {
+	portsInUseMu.Lock()
+	defer portsInUseMu.Unlock()
+	return portsInUse[port] > 0
+}
+
+// Transport releasing the claim on its port when closed
+type claimedTransport struct {
+	Transport
+	release func()
+}
+
+func (t *claimedTransport) Close() error {
+	t.release()
+	return t.Transport.Close()
+}
+
+/*
+Opens the widget with the given serial number, wherever it is attached.
+
+The port is looked up using discovery on every 'Open', so reconnecting finds the widget on its new port.
+The port the widget was last found on is probed first, probing stops at the first match.
+Ports this process has open (by 'SerialOpener' or 'SerialNumberOpener') are not probed, so other controllers are not disturbed.
+
+Note: Probing a widget opened by another process takes replies meant for that process, see 'Discover'.
+*/
+type SerialNumberOpener struct {
+	serialNumber uint32
+	opts         DiscoveryOptions
+
+	mu sync.Mutex
+	// Port the widget was last found on
+	port string
+}
+
+// Helper function for creating a new SerialNumberOpener
+func NewSerialNumberOpener(serialNumber uint32, opts DiscoveryOptions) *SerialNumberOpener {
+	return &SerialNumberOpener{serialNumber: serialNumber, opts: opts}
+}
+
+/*
+Find the port of the widget and open it.
+
+The probed transport is returned once the probe stopped reading.
+Transports without read timeout keep the probe reading, they are opened again instead.
+*/
+func (o *SerialNumberOpener) Open() (Transport, error) {
+	candidates, err := ListCandidatePorts(o.opts.SysfsRoot, o.opts.DevRoot)
+	if err != nil {
+		return nil, err
+	}
+	o.mu.Lock()
+	lastPort := o.port
+	o.mu.Unlock()
+	ports := make([]string, 0, len(candidates))
+	for _, port := range candidates {
+		if port == lastPort {
+			ports = append([]string{port}, ports...)
+		} else {
+			ports = append(ports, port)
+		}
+	}
+	for _, port := range ports {
+		if isPortInUse(port) {
+			continue
+		}
+		transport, err := o.opts.Open(port)
+		if err != nil {
+			continue
+		}
+		widget, stopped, err := probe(transport, o.opts.Timeout)
+		if err != nil || widget.SerialNumber != o.serialNumber {
+			transport.Close()
+			continue
+		}
+		select {
+		case <-stopped:
+		case <-time.After(o.opts.Timeout):
+			transport.Close()
+			if transport, err = o.opts.Open(port); err != nil {
+				return nil, fmt.Errorf(ENTTEC_DMX_USB_PRO_LOG_PREFIX+": found widget with serial number %d on %s, but reopening failed: %v", o.serialNumber, port, err)
+			}
+		}
+		o.mu.Lock()
+		o.port = port
+		o.mu.Unlock()
+		return &claimedTransport{Transport: transport, release: claimPort(port)}, nil
+	}
+	return nil, fmt.Errorf(ENTTEC_DMX_USB_PRO_LOG_PREFIX+": no widget with serial number %d found", o.serialNumber)
+}
+
+/*
+Returns the port the widget was last found on.
+
+e.g. "/dev/ttyUSB0" or "serial number 12345678" if not found yet
+*/
+func (o *SerialNumberOpener) GetName() string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.port == "" {
+		return fmt.Sprintf("serial number %d", o.serialNumber)
+	}
+	return o.port
+}
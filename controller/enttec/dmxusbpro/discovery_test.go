@@ -0,0 +1,226 @@
+package dmxusbpro
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/H3rby7/usbdmx-golang/controller/enttec/dmxusbpro/emulator"
+)
+
+// Create a sysfs entry for a tty, attached to a USB device with the given IDs
+func addFakeTTY(t *testing.T, sysfs string, name string, vendor string, product string) {
+	usbDevice := filepath.Join(sysfs, "devices", "usb1", name)
+	interfaceDir := filepath.Join(usbDevice, "1-1:1.0", name)
+	if err := os.MkdirAll(interfaceDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(filepath.Join(usbDevice, "idVendor"), []byte(vendor+"\n"), 0644)
+	os.WriteFile(filepath.Join(usbDevice, "idProduct"), []byte(product+"\n"), 0644)
+	ttyDir := filepath.Join(sysfs, "class", "tty", name)
+	if err := os.MkdirAll(ttyDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(interfaceDir, filepath.Join(ttyDir, "device")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func newFakeSysfs(t *testing.T) string {
+	sysfs := t.TempDir()
+	addFakeTTY(t, sysfs, "ttyUSB0", FTDI_USB_VENDOR_ID, FTDI_USB_PRODUCT_ID)
+	addFakeTTY(t, sysfs, "ttyUSB1", "1a86", "7523")
+	addFakeTTY(t, sysfs, "ttyUSB2", FTDI_USB_VENDOR_ID, FTDI_USB_PRODUCT_ID)
+	// Virtual terminal without device
+	os.MkdirAll(filepath.Join(sysfs, "class", "tty", "tty0"), 0755)
+	return sysfs
+}
+
+func TestListCandidatePorts(t *testing.T) {
+	ports, err := ListCandidatePorts(newFakeSysfs(t), "/dev")
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if len(ports) != 2 || ports[0] != "/dev/ttyUSB0" || ports[1] != "/dev/ttyUSB2" {
+		t.Errorf("expected ports to be [/dev/ttyUSB0 /dev/ttyUSB2], but were %v", ports)
+	}
+}
+
+func TestDiscover(t *testing.T) {
+	conf := emulator.DefaultConfig()
+	conf.SerialNumber = 12345678
+	conf.FirmwareVersion = 0x0203
+	opts := DiscoveryOptions{
+		SysfsRoot: newFakeSysfs(t),
+		DevRoot:   "/dev",
+		Open: func(port string) (Transport, error) {
+			switch port {
+			case "/dev/ttyUSB0":
+				host, device := emulator.NewPipe()
+				go emulator.NewWidget(conf).Serve(device)
+				return host, nil
+			case "/dev/ttyUSB2":
+				// Some other FTDI device, that never answers
+				return &fakeTransport{r: timeoutReader{}, w: io.Discard}, nil
+			}
+			return nil, errors.New("unexpected port " + port)
+		},
+		Timeout: 100 * time.Millisecond,
+	}
+	widgets, err := Discover(opts)
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if len(widgets) != 1 {
+		t.Fatalf("expected 1 widget, but found %d", len(widgets))
+	}
+	expected := DetectedWidget{Port: "/dev/ttyUSB0", SerialNumber: 12345678, FirmwareVersion: 0x0203}
+	if widgets[0] != expected {
+		t.Errorf("expected widget to be %+v, but was %+v", expected, widgets[0])
+	}
+}
+
+func TestSerialNumberOpenerFollowsWidget(t *testing.T) {
+	serialNumbers := map[string]uint32{"/dev/ttyUSB0": 1, "/dev/ttyUSB2": 2}
+	var mu sync.Mutex
+	opts := DiscoveryOptions{
+		SysfsRoot: newFakeSysfs(t),
+		DevRoot:   "/dev",
+		Open: func(port string) (Transport, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			conf := emulator.DefaultConfig()
+			conf.SerialNumber = serialNumbers[port]
+			host, device := emulator.NewPipe()
+			go emulator.NewWidget(conf).Serve(device)
+			return host, nil
+		},
+		Timeout: 100 * time.Millisecond,
+	}
+	opener := NewSerialNumberOpener(2, opts)
+	if name := opener.GetName(); name != "serial number 2" {
+		t.Errorf("expected name to be 'serial number 2', but was '%s'", name)
+	}
+	d := NewEnttecDMXUSBProControllerWithTransport(opener, 3, true)
+	if err := d.Connect(); err != nil {
+		t.Fatalf("expected no error on connect, but got %v", err)
+	}
+	if name := d.GetName(); name != "/dev/ttyUSB2" {
+		t.Errorf("expected name to be '/dev/ttyUSB2', but was '%s'", name)
+	}
+	d.Disconnect()
+	// Reboot, swapping the ports
+	mu.Lock()
+	serialNumbers["/dev/ttyUSB0"], serialNumbers["/dev/ttyUSB2"] = 2, 1
+	mu.Unlock()
+	if err := d.Connect(); err != nil {
+		t.Fatalf("expected no error on connect, but got %v", err)
+	}
+	defer d.Disconnect()
+	if name := d.GetName(); name != "/dev/ttyUSB0" {
+		t.Errorf("expected name to be '/dev/ttyUSB0', but was '%s'", name)
+	}
+}
+
+func TestSerialNumberOpenerNotFound(t *testing.T) {
+	opts := DiscoveryOptions{
+		SysfsRoot: newFakeSysfs(t),
+		DevRoot:   "/dev",
+		Open: func(port string) (Transport, error) {
+			host, device := emulator.NewPipe()
+			go emulator.NewWidget(emulator.DefaultConfig()).Serve(device)
+			return host, nil
+		},
+		Timeout: 100 * time.Millisecond,
+	}
+	if _, err := NewSerialNumberOpener(42, opts).Open(); err == nil {
+		t.Errorf("expected an error, as no widget has serial number 42")
+	}
+}
+
+// Options serving a widget with the serial number of the port, counting the opens per port
+func newCountingOptions(t *testing.T, serialNumbers map[string]uint32) (DiscoveryOptions, func(port string) int) {
+	var mu sync.Mutex
+	opens := make(map[string]int)
+	opts := DiscoveryOptions{
+		SysfsRoot: newFakeSysfs(t),
+		DevRoot:   "/dev",
+		Open: func(port string) (Transport, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			opens[port]++
+			conf := emulator.DefaultConfig()
+			conf.SerialNumber = serialNumbers[port]
+			host, device := emulator.NewPipeWithReadTimeout(10 * time.Millisecond)
+			go emulator.NewWidget(conf).Serve(device)
+			return host, nil
+		},
+		Timeout: 100 * time.Millisecond,
+	}
+	return opts, func(port string) int {
+		mu.Lock()
+		defer mu.Unlock()
+		return opens[port]
+	}
+}
+
+func TestSerialNumberOpenerKeepsProbedTransport(t *testing.T) {
+	opts, opens := newCountingOptions(t, map[string]uint32{"/dev/ttyUSB0": 1, "/dev/ttyUSB2": 2})
+	d := NewEnttecDMXUSBProControllerWithTransport(NewSerialNumberOpener(2, opts), 3, true)
+	if err := d.Connect(); err != nil {
+		t.Fatalf("expected no error on connect, but got %v", err)
+	}
+	defer d.Disconnect()
+	if n := opens("/dev/ttyUSB2"); n != 1 {
+		t.Errorf("expected the matching port to be opened once, but was opened %d times", n)
+	}
+	portsInUseMu.Lock()
+	claims := portsInUse["/dev/ttyUSB2"]
+	portsInUseMu.Unlock()
+	if claims != 1 {
+		t.Errorf("expected the port to be claimed once, but was claimed %d times", claims)
+	}
+	if serialNumber, err := d.GetSerialNumber(); err != nil || serialNumber != 2 {
+		t.Errorf("expected serial number 2 using the probed transport, but got %d and %v", serialNumber, err)
+	}
+}
+
+func TestSerialNumberOpenerTriesLastPortFirst(t *testing.T) {
+	opts, opens := newCountingOptions(t, map[string]uint32{"/dev/ttyUSB0": 1, "/dev/ttyUSB2": 2})
+	opener := NewSerialNumberOpener(2, opts)
+	transport, err := opener.Open()
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	transport.Close()
+	transport, err = opener.Open()
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	transport.Close()
+	if n := opens("/dev/ttyUSB0"); n != 1 {
+		t.Errorf("expected the other port to be probed only when searching first, but was opened %d times", n)
+	}
+}
+
+func TestSerialNumberOpenerSkipsPortsInUse(t *testing.T) {
+	opts, opens := newCountingOptions(t, map[string]uint32{"/dev/ttyUSB0": 1, "/dev/ttyUSB2": 2})
+	first, err := NewSerialNumberOpener(1, opts).Open()
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if _, err := NewSerialNumberOpener(3, opts).Open(); err == nil {
+		t.Errorf("expected an error, as no widget has serial number 3")
+	}
+	if n := opens("/dev/ttyUSB0"); n != 1 {
+		t.Errorf("expected the port in use not to be probed, but was opened %d times", n)
+	}
+	first.Close()
+	if isPortInUse("/dev/ttyUSB0") {
+		t.Errorf("expected the port to be released when closed")
+	}
+}
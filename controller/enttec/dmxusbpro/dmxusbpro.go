@@ -1,11 +1,14 @@
 package dmxusbpro
 
 import (
+	"context"
 	"fmt"
 	"log"
+	"sync"
 	"time"
 
 	"github.com/H3rby7/usbdmx-golang/controller/enttec/dmxusbpro/messages"
+	"github.com/H3rby7/usbdmx-golang/rdm"
 	"github.com/tarm/serial"
 )
 
@@ -14,31 +17,114 @@ const ENTTEC_DMX_USB_PRO_LOG_PREFIX = "EDUP"
 
 // Controller for Enttec DMX USB Pro device to handle communication
 type EnttecDMXUSBProController struct {
-	// Holds DMX data, as DMX starts with channel '1' the index '0' is unused.
-	channels []byte
+	// Holds DMX data, staged and committed, safe for concurrent use
+	frames *frameBuffer
+	// Sends the stage at a fixed rate, see 'StartRefresh'
+	refresh *refresher
+	// Writes committed frames, see 'EnableAsyncCommit'
+	async *asyncWriter
+	// Stop the routines of all ports, when disconnecting
+	haltOnDisconnect []func()
 
 	isWriter bool
 	isReader bool
-	// Is the widget in 'only read changes'-mode (as opposed to read everything)
+	// Another port of the widget receives DMX, so reading is allowed even if this controller writes (see 'EnttecDMXUSBProMk2Controller')
+	otherPortReads bool
+	// Labels of messages sent and received, the standard ones unless extended
+	labels messages.LabelSet
+	// Is the widget in 'only read changes'-mode (as opposed to read everything), guarded by 'connMu'
 	readOnChange bool
+	// Pause after each read of 'OnDMXChangeContext', guarded by 'connMu'
+	readInterval time.Duration
 
-	isConnected  bool
-	conf         *serial.Config
-	port         *serial.Port
+	// Guards the connection, as reading and reconnecting happen in their own routines
+	connMu      sync.Mutex
+	isConnected bool
+	opener      TransportOpener
+	port        Transport
+
+	// Guards 'logVerbosity' only, as 'printf' is called with 'connMu' held
+	logMu        sync.Mutex
 	logVerbosity uint8
+
+	connState ConnectionState
+	// Closed and replaced whenever 'connState' changes
+	connStateChanged chan struct{}
+	connSubscribers  []chan<- ConnectionState
+	autoReconnect    bool
+	reconnectPolicy  ReconnectPolicy
+	// Closed to stop a running reconnect
+	reconnectStop chan struct{}
+	// Reason reconnecting gave up
+	reconnectErr error
+	// Last value passed to 'SwitchReadMode', restored after reconnecting
+	readMode    byte
+	hasReadMode bool
+	// Last committed frame, restored after reconnecting
+	lastCommitted []byte
+	// Restores further state after reconnecting, before the read mode (e.g. activating the Mk2 API)
+	restoreFirst func()
+
+	// Guards the requests waiting for replies from the widget
+	replyMu sync.Mutex
+	// Requests waiting for a reply, by label of the reply
+	replyWaiters map[byte][]*replyWaiter
+	// Number of running read routines, that hand replies to the waiting requests
+	readLoops    int
+	replyTimeout time.Duration
+
+	// Guards the serial number of the connected widget, cached by 'GetSerialNumber'
+	serialMu        sync.Mutex
+	serialNumber    uint32
+	hasSerialNumber bool
+
+	// Guards RDM transactions, one at a time
+	rdmMu           sync.Mutex
+	rdmSourceUID    rdm.UID
+	hasRDMSourceUID bool
+	rdmTransaction  byte
+
+	statsMu      sync.Mutex
+	receiveStats ReceiveStatistics
 }
 
-// Helper function for creating a new DMX USB PRO controller
+// Helper function for creating a new DMX USB PRO controller using a serial port
 func NewEnttecDMXUSBProController(conf *serial.Config, dmxChannelCount int, isWriter bool) *EnttecDMXUSBProController {
+	return NewEnttecDMXUSBProControllerWithTransport(NewSerialOpener(conf), dmxChannelCount, isWriter)
+}
+
+/*
+Helper function for creating a new DMX USB PRO controller using the widget with the given serial number.
+
+The widget is looked up on every connect, so it is found even if its port changed, see 'SerialNumberOpener'.
+
+Example useage:
+
+	controller := dmxusbpro.NewEnttecDMXUSBProControllerBySerialNumber(12345678, 512, true)
+*/
+func NewEnttecDMXUSBProControllerBySerialNumber(serialNumber uint32, dmxChannelCount int, isWriter bool) *EnttecDMXUSBProController {
+	opener := NewSerialNumberOpener(serialNumber, DefaultDiscoveryOptions())
+	return NewEnttecDMXUSBProControllerWithTransport(opener, dmxChannelCount, isWriter)
+}
+
+// Helper function for creating a new DMX USB PRO controller using any Transport
+func NewEnttecDMXUSBProControllerWithTransport(opener TransportOpener, dmxChannelCount int, isWriter bool) *EnttecDMXUSBProController {
 	d := &EnttecDMXUSBProController{}
-	d.channels = make([]byte, dmxChannelCount+1)
+	d.frames = newFrameBuffer(dmxChannelCount)
+	d.refresh = &refresher{}
+	d.async = &asyncWriter{}
+	d.haltOnDisconnect = []func(){d.refresh.halt, d.async.halt}
 
-	d.conf = conf
+	d.opener = opener
 	d.isWriter = isWriter
 	d.isReader = !isWriter
 	d.readOnChange = false
 	d.isConnected = false
 	d.logVerbosity = 0
+	d.connState = CONNECTION_STATE_DISCONNECTED
+	d.connStateChanged = make(chan struct{})
+	d.replyWaiters = make(map[byte][]*replyWaiter)
+	d.replyTimeout = DEFAULT_REPLY_TIMEOUT
 
 	return d
 }
@@ -49,81 +135,130 @@ func NewEnttecDMXUSBProController(conf *serial.Config, dmxChannelCount int, isWr
 e.g. "COM4" or "/dev/tty.usbserial"
 */
 func (d *EnttecDMXUSBProController) GetName() string {
-	return d.conf.Name
+	return d.opener.GetName()
 }
 
 /*
-	Open the serial connection to Enttec DMX USB PRO Widget
+	Open the connection to Enttec DMX USB PRO Widget
 
 Succeeded if no error is returned
 */
 func (d *EnttecDMXUSBProController) Connect() error {
-	s, err := serial.OpenPort(d.conf)
+	s, err := d.opener.Open()
 	if err != nil {
 		return err
 	}
+	d.forgetSerialNumber()
+	d.connMu.Lock()
+	defer d.connMu.Unlock()
 	d.port = s
 	d.isConnected = true
+	d.setConnectionState(CONNECTION_STATE_CONNECTED)
 	return nil
 }
 
 /*
-	Close the serial connection to Enttec DMX USB PRO Widget
+	Close the connection to Enttec DMX USB PRO Widget
 
 Succeeded if no error is returned
 */
 func (d *EnttecDMXUSBProController) Disconnect() error {
-	if d.port == nil {
-		return d.errorf("not connected.")
+	for _, halt := range d.haltOnDisconnect {
+		halt()
 	}
+	d.connMu.Lock()
+	port := d.port
+	wasReconnecting := d.connState == CONNECTION_STATE_RECONNECTING
 	d.isConnected = false
-	return d.port.Close()
+	if d.reconnectStop != nil {
+		close(d.reconnectStop)
+		d.reconnectStop = nil
+	}
+	d.setConnectionState(CONNECTION_STATE_DISCONNECTED)
+	d.connMu.Unlock()
+	if port == nil {
+		return d.errorf("not connected.")
+	}
+	if wasReconnecting {
+		// The lost transport is closed by the reconnect routine
+		return nil
+	}
+	return port.Close()
+}
+
+// Returns the transport, if connected
+func (d *EnttecDMXUSBProController) connectedPort() (Transport, bool) {
+	d.connMu.Lock()
+	defer d.connMu.Unlock()
+	return d.port, d.port != nil && d.isConnected
 }
 
 // Gets a copy of all staged channel values
 func (d *EnttecDMXUSBProController) GetStage() []byte {
-	channels := make([]byte, len(d.channels))
-
-	copy(channels, d.channels)
-
-	return channels
+	return d.frames.getStaged()
 }
 
 /*
 Prepare a channel to be changed to the given value
 
 Note: This does not send out the changes, you must call the 'Commit' method to apply the stage live.
+
+Safe to call from several routines, also while committing.
 */
 func (d *EnttecDMXUSBProController) Stage(channel int16, value byte) error {
+	return d.StageRange(channel, []byte{value})
+}
+
+/*
+Prepare consecutive channels, beginning at the given channel, to be changed to the given values.
+
+The values are staged at once, so a concurrent 'Commit' sends either all or none of them.
+
+Example useage:
+
+	controller.StageRange(6, []byte{255, 0, 128}) // RGB of a fixture starting at channel 6
+	controller.Commit()
+*/
+func (d *EnttecDMXUSBProController) StageRange(channel int16, values []byte) error {
 	if !d.isWriter {
 		return d.errorf("controller is not in WRITE mode")
 	}
-	highestChannel := int16(len(d.channels))
-	if channel < 1 || channel > highestChannel {
-		return d.errorf("index %d out of range, must be between 1 and %d", channel, highestChannel)
-	}
-	d.channels[channel] = value
-	return nil
+	return d.frames.stage(channel, values)
 }
 
 /*
 Apply the 'staged' values to go live.
 
+The stage is snapshotted at once, values staged while sending go out with the next commit.
+Commits from several routines are sent one after another.
+Returns before the frame is written, if enabled by 'EnableAsyncCommit'.
+
 Note: This does not clear the Stage!
 */
 func (d *EnttecDMXUSBProController) Commit() error {
 	if !d.isWriter {
 		return d.errorf("controller is not in WRITE mode")
 	}
-	msg := messages.NewEnttecDMXUSBProApplicationMessage(messages.LABEL_OUTPUT_ONLY_SEND_DMX_PACKET_REQUEST, d.channels)
+	return d.frames.commit(d.commitFrame)
+}
+
+// Send a committed frame, or hand it to the routine writing frames if 'EnableAsyncCommit' was called
+func (d *EnttecDMXUSBProController) commitFrame(frame []byte) error {
+	return d.async.commit(frame, d.sendFrame)
+}
+
+// Send a committed frame, remembering it for reconnecting
+func (d *EnttecDMXUSBProController) sendFrame(frame []byte) error {
+	d.connMu.Lock()
+	d.lastCommitted = append(d.lastCommitted[:0], frame...)
+	d.connMu.Unlock()
+	msg := messages.NewEnttecDMXUSBProApplicationMessage(messages.LABEL_OUTPUT_ONLY_SEND_DMX_PACKET_REQUEST, frame)
 	return d.writeMessage(msg)
 }
 
 // Set all values of the staged channels to '0'
 func (d *EnttecDMXUSBProController) ClearStage() {
-	for i := range d.channels {
-		d.channels[i] = 0
-	}
+	d.frames.clear()
 }
 
 /*
@@ -154,36 +289,50 @@ func (d *EnttecDMXUSBProController) SwitchReadMode(changesOnly byte) error {
 		return d.errorf("controller is not in READ mode")
 	}
 	msg := messages.NewEnttecDMXUSBProApplicationMessage(messages.LABEL_RECEIVE_DMX_ON_CHANGE, []byte{changesOnly})
+	d.connMu.Lock()
+	d.readMode = changesOnly
+	d.hasReadMode = true
+	d.connMu.Unlock()
 	if err := d.writeMessage(msg); err != nil {
 		return err
 	}
+	d.connMu.Lock()
 	d.readOnChange = true
+	d.connMu.Unlock()
 	return nil
 }
 
 /*
-Expose serial read to be used directly
+Expose transport read to be used directly
 */
 func (d *EnttecDMXUSBProController) Read(buf []byte) (int, error) {
-	if d.port == nil || !d.isConnected {
+	port, ok := d.connectedPort()
+	if !ok {
 		return -1, d.errorf("not connected")
 	}
-	if !d.isReader {
+	if !d.isReader && !d.otherPortReads {
 		return -1, d.errorf("controller is not in READ mode")
 	}
-	n, err := d.port.Read(buf)
+	n, err := port.Read(buf)
+	if err != nil {
+		d.connectionLost(port, err)
+	}
 	d.printf(2, "Read %d bytes:\t%v", n, buf[0:n])
 	return n, err
 }
 
 /*
-Expose serial write to be used directly
+Expose transport write to be used directly
 */
 func (d *EnttecDMXUSBProController) Write(buf []byte) (int, error) {
-	if d.port == nil || !d.isConnected {
+	port, ok := d.connectedPort()
+	if !ok {
 		return -1, fmt.Errorf("not connected")
 	}
-	n, err := d.port.Write(buf)
+	n, err := port.Write(buf)
+	if err != nil {
+		d.connectionLost(port, err)
+	}
 	d.printf(2, "Wrote %d bytes:\t%v", n, buf[0:n])
 	return n, err
 }
@@ -191,41 +340,147 @@ func (d *EnttecDMXUSBProController) Write(buf []byte) (int, error) {
 /*
 Start routine to read from DMX and get the results back via channel
 
+Panics if reading fails, use 'OnDMXChangeContext' to handle errors instead.
+
+The optional 'readIntervalMS' is deprecated, it calls 'SetReadInterval' with the given milliseconds.
+Panics if more than one value is given.
+
 Example useage:
 
 	c := make(chan messages.EnttecDMXUSBProApplicationMessage) // create channel
 	go controller.OnDMXChange(c) // start routine
-	for msg := range c { ... } // handle incoming data
+	for msg := range c { ... } // handle incoming data, ends on 'Disconnect'
 */
-func (d *EnttecDMXUSBProController) OnDMXChange(c chan messages.EnttecDMXUSBProApplicationMessage, readIntervalMS int) {
-	if !d.readOnChange {
-		d.panicf("controller is not in READ ON CHANGE mode!")
+func (d *EnttecDMXUSBProController) OnDMXChange(c chan messages.EnttecDMXUSBProApplicationMessage, readIntervalMS ...int) {
+	if len(readIntervalMS) > 1 {
+		close(c)
+		d.panicf("expected at most one read interval, but got %d", len(readIntervalMS))
 	}
+	if len(readIntervalMS) == 1 {
+		d.SetReadInterval(time.Millisecond * time.Duration(readIntervalMS[0]))
+	}
+	if err := d.OnDMXChangeContext(context.Background(), c, nil); err != nil {
+		d.panicf("%v", err)
+	}
+}
+
+/*
+Set a pause after each read of 'OnDMXChange' and 'OnDMXChangeContext', trading latency for fewer reads.
+
+0, the default, reads as soon as data arrives. Applies to reading routines started afterwards.
+*/
+func (d *EnttecDMXUSBProController) SetReadInterval(interval time.Duration) {
+	d.connMu.Lock()
+	defer d.connMu.Unlock()
+	d.readInterval = interval
+}
+
+/*
+Start routine to read from DMX and get the results back via channel, until the context is done.
+
+The channel 'c' is closed when the routine returns.
+
+Returns nil when the context is done or the controller got disconnected, else the error that stopped reading.
+
+Problems decoding the data that do not stop reading are sent to 'errs', unless it is nil.
+
+Reads block until data arrives or the transport's read timeout passes, messages are sent as soon as they are complete.
+'SetReadInterval' adds a pause after each read, trading latency for fewer reads.
+
+Example useage:
+
+	c := make(chan messages.EnttecDMXUSBProApplicationMessage) // create channel
+	go func() {
+		if err := controller.OnDMXChangeContext(ctx, c, nil); err != nil { ... } // start routine
+	}()
+	for msg := range c { ... } // handle incoming data, ends when the routine returns
+*/
+func (d *EnttecDMXUSBProController) OnDMXChangeContext(ctx context.Context, c chan<- messages.EnttecDMXUSBProApplicationMessage, errs chan<- error) error {
+	defer close(c)
+	d.connMu.Lock()
+	readOnChange := d.readOnChange
+	readInterval := d.readInterval
+	d.connMu.Unlock()
+	if !readOnChange {
+		return d.errorf("controller is not in READ ON CHANGE mode!")
+	}
+	d.startReadLoop()
+	defer d.stopReadLoop()
 	// Buffer used for reading fresh data
 	readBuf := make([]byte, messages.MAXIMUM_MESSAGE_LENGTH)
-	// Buffer containing old, yet unused data
-	oldBuf := make([]byte, 0)
-	// Detected messages
-	var msgs []messages.EnttecDMXUSBProApplicationMessage
-	for {
+	decoder := NewDecoderWithLabels(d.labels)
+	for ctx.Err() == nil {
 		n, err := d.Read(readBuf)
 		if err != nil {
-			d.panicf("error reading from serial, %v", err)
+			retry, err := d.recoverRead(ctx, err)
+			if retry {
+				// The rest of a message from the lost connection is no error
+				decoder.Reset()
+				decoder.Dropped()
+				continue
+			}
+			return err
 		}
-		// Combine newly read data with yet unused data
-		combined := append(oldBuf, readBuf[:n]...)
-		// Try to extract valid messages
-		msgs, oldBuf = Extract(combined)
-		for _, msg := range msgs {
+		decoder.Feed(readBuf[:n])
+		for msg, ok := decoder.Next(); ok; msg, ok = decoder.Next() {
 			d.printf(1, "Read \tlabel=%v \tdata=%v", msg.GetLabel(), msg.GetPayload())
-			c <- msg
+			d.countReceived(msg)
+			// Copy, as the decoder reuses its memory
+			msg = messages.NewEnttecDMXUSBProApplicationMessageWithLabels(d.labels, msg.GetLabel(), append([]byte{}, msg.GetPayload()...))
+			if d.deliverReply(msg) {
+				continue
+			}
+			select {
+			case c <- msg:
+			case <-ctx.Done():
+				return nil
+			}
 		}
-		if len(oldBuf) > messages.MAXIMUM_MESSAGE_LENGTH {
-			dropOldDataBefore := len(oldBuf) - messages.MAXIMUM_MESSAGE_LENGTH
-			d.printf(1, "Dropping old, unused data:\t%v", oldBuf[:dropOldDataBefore])
-			oldBuf = oldBuf[dropOldDataBefore:]
+		if dropped := decoder.Dropped(); dropped > 0 {
+			d.printf(1, "Dropped %d bytes not belonging to any message", dropped)
+			d.reportError(ctx, errs, d.errorf("dropped %d bytes not belonging to any message", dropped))
 		}
-		time.Sleep(time.Millisecond * time.Duration(readIntervalMS))
+		if readInterval > 0 {
+			select {
+			case <-time.After(readInterval):
+			case <-ctx.Done():
+			}
+		}
+	}
+	return nil
+}
+
+/*
+Decide how to continue after a failed read.
+
+Retry after reconnecting, stop without error after 'Disconnect', else stop with an error.
+*/
+func (d *EnttecDMXUSBProController) recoverRead(ctx context.Context, readErr error) (retry bool, err error) {
+	d.connMu.Lock()
+	supervised := d.autoReconnect
+	state := d.connState
+	d.connMu.Unlock()
+	if state == CONNECTION_STATE_DISCONNECTED {
+		// Stopped by 'Disconnect', or reconnecting gave up
+		return false, d.getReconnectErr()
+	}
+	if !supervised {
+		return false, d.errorf("error reading from transport, %v", readErr)
+	}
+	if d.waitReconnected(ctx) {
+		return true, nil
+	}
+	return false, d.getReconnectErr()
+}
+
+// Send the error, unless nobody is interested or the context is done
+func (d *EnttecDMXUSBProController) reportError(ctx context.Context, errs chan<- error, err error) {
+	if errs == nil {
+		return
+	}
+	select {
+	case errs <- err:
+	case <-ctx.Done():
 	}
 }
 
@@ -242,12 +497,17 @@ func (d *EnttecDMXUSBProController) SetLogVerbosity(verbosity uint8) {
 	if verbosity > 2 {
 		d.panicf("invalid value, only 0, 1 and 2 are allowed, but got '%d'", verbosity)
 	}
+	d.logMu.Lock()
+	defer d.logMu.Unlock()
 	d.logVerbosity = verbosity
 }
 
 func (d *EnttecDMXUSBProController) printf(level uint8, format string, v ...any) {
-	if d.logVerbosity == level {
-		log.Printf(ENTTEC_DMX_USB_PRO_LOG_PREFIX+": "+format, v...)
+	d.logMu.Lock()
+	verbosity := d.logVerbosity
+	d.logMu.Unlock()
+	if verbosity == level {
+		log.Printf(ENTTEC_DMX_USB_PRO_LOG_PREFIX+" "+d.GetIdentifier()+": "+format, v...)
 	}
 }
 
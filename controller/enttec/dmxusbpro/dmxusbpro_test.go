@@ -0,0 +1,337 @@
+package dmxusbpro
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/H3rby7/usbdmx-golang/controller/enttec/dmxusbpro/emulator"
+	"github.com/H3rby7/usbdmx-golang/controller/enttec/dmxusbpro/messages"
+)
+
+// In-memory Transport, reading from 'r' and writing into 'w'
+type fakeTransport struct {
+	r      io.Reader
+	w      io.Writer
+	closed bool
+}
+
+func (f *fakeTransport) Read(buf []byte) (int, error) {
+	return f.r.Read(buf)
+}
+
+func (f *fakeTransport) Write(buf []byte) (int, error) {
+	return f.w.Write(buf)
+}
+
+func (f *fakeTransport) Close() error {
+	f.closed = true
+	return nil
+}
+
+// Reader simulating a read timeout, as no data ever arrives
+type timeoutReader struct{}
+
+func (r timeoutReader) Read(buf []byte) (int, error) {
+	time.Sleep(time.Millisecond)
+	return 0, nil
+}
+
+// Reader failing with the given error
+type failingReader struct {
+	err error
+}
+
+func (r failingReader) Read(buf []byte) (int, error) {
+	return 0, r.err
+}
+
+func newFakeController(t *testing.T, transport Transport, isWriter bool) *EnttecDMXUSBProController {
+	opener := NewTransportOpener("fake", func() (Transport, error) {
+		return transport, nil
+	})
+	d := NewEnttecDMXUSBProControllerWithTransport(opener, 3, isWriter)
+	if err := d.Connect(); err != nil {
+		t.Fatalf("expected no error on connect, but got %v", err)
+	}
+	return d
+}
+
+func TestControllerUsesOpenerName(t *testing.T) {
+	d := newFakeController(t, &fakeTransport{}, true)
+	if d.GetName() != "fake" {
+		t.Errorf("expected name to be 'fake', but was '%s'", d.GetName())
+	}
+}
+
+func TestControllerDisconnectClosesTransport(t *testing.T) {
+	transport := &fakeTransport{}
+	d := newFakeController(t, transport, true)
+	if err := d.Disconnect(); err != nil {
+		t.Errorf("expected no error on disconnect, but got %v", err)
+	}
+	if !transport.closed {
+		t.Errorf("expected transport to be closed")
+	}
+}
+
+func TestControllerCommit(t *testing.T) {
+	out := &bytes.Buffer{}
+	d := newFakeController(t, &fakeTransport{w: out}, true)
+	d.Stage(1, 69)
+	d.Stage(3, 96)
+	if err := d.Commit(); err != nil {
+		t.Errorf("expected no error on commit, but got %v", err)
+	}
+	expected := []byte{0x7E, messages.LABEL_OUTPUT_ONLY_SEND_DMX_PACKET_REQUEST, 4, 0, 0, 69, 0, 96, 0xE7}
+	if !bytes.Equal(out.Bytes(), expected) {
+		t.Errorf("expected written bytes to be %v, but were %v", expected, out.Bytes())
+	}
+}
+
+func TestControllerStageRange(t *testing.T) {
+	out := &bytes.Buffer{}
+	d := newFakeController(t, &fakeTransport{w: out}, true)
+	if err := d.StageRange(2, []byte{7, 8}); err != nil {
+		t.Errorf("expected no error, but got %v", err)
+	}
+	for _, channel := range []int16{0, 3} {
+		if err := d.StageRange(channel, []byte{1, 1}); err == nil {
+			t.Errorf("expected error staging 2 channels at channel %d", channel)
+		}
+	}
+	if err := d.Stage(4, 1); err == nil {
+		t.Errorf("expected error staging channel 4")
+	}
+	expected := []byte{0, 0, 7, 8}
+	if !bytes.Equal(d.GetStage(), expected) {
+		t.Errorf("expected stage to be %v, but was %v", expected, d.GetStage())
+	}
+}
+
+func TestControllerConcurrentStageAndCommit(t *testing.T) {
+	writes := &recordingWriter{w: io.Discard}
+	d := newFakeController(t, &fakeTransport{w: writes}, true)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 1000; i++ {
+			v := byte(i)
+			d.StageRange(1, []byte{v, v, v})
+		}
+	}()
+	var wg sync.WaitGroup
+	for r := 0; r < 2; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				if err := d.Commit(); err != nil {
+					t.Errorf("expected no error on commit, but got %v", err)
+					return
+				}
+				select {
+				case <-done:
+					return
+				default:
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	msgs := writes.getMessages()
+	if len(msgs) == 0 {
+		t.Fatalf("expected frames to be committed")
+	}
+	for i, msg := range msgs {
+		frame := msg.GetPayload()
+		if frame[1] != frame[2] || frame[2] != frame[3] {
+			t.Fatalf("expected frame %d to hold a single staged range, but was %v", i, frame)
+		}
+	}
+}
+
+func TestControllerSwitchReadMode(t *testing.T) {
+	out := &bytes.Buffer{}
+	d := newFakeController(t, &fakeTransport{w: out}, false)
+	if err := d.SwitchReadMode(1); err != nil {
+		t.Errorf("expected no error on switching read mode, but got %v", err)
+	}
+	expected := []byte{0x7E, messages.LABEL_RECEIVE_DMX_ON_CHANGE, 1, 0, 1, 0xE7}
+	if !bytes.Equal(out.Bytes(), expected) {
+		t.Errorf("expected written bytes to be %v, but were %v", expected, out.Bytes())
+	}
+}
+
+func TestControllerOnDMXChange(t *testing.T) {
+	in, widget := io.Pipe()
+	d := newFakeController(t, &fakeTransport{r: in, w: io.Discard}, false)
+	d.SwitchReadMode(1)
+	c := make(chan messages.EnttecDMXUSBProApplicationMessage)
+	go d.OnDMXChange(c)
+	// Send the message in two parts to simulate a partial read
+	go func() {
+		widget.Write([]byte{0x11, 0x7E, messages.LABEL_RECEIVED_DMX_CHANGE_OF_STATE_PACKET, 7, 0, 0, 2})
+		widget.Write([]byte{0, 0, 0, 0, 96, 0xE7})
+	}()
+	select {
+	case msg := <-c:
+		cs, err := messages.ToChangeSet(msg)
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		if cs[1] != 96 {
+			t.Errorf("expected channel[%d] to be %d, but was %d", 1, 96, cs[1])
+		}
+	case <-time.After(time.Second):
+		t.Errorf("expected a message to be read")
+	}
+}
+
+// Expect the channel to be closed, after receiving any remaining messages
+func expectClosed(t *testing.T, c chan messages.EnttecDMXUSBProApplicationMessage) {
+	timeout := time.After(time.Second)
+	for {
+		select {
+		case _, ok := <-c:
+			if !ok {
+				return
+			}
+		case <-timeout:
+			t.Fatalf("expected channel to be closed")
+		}
+	}
+}
+
+func TestControllerOnDMXChangeContextCancel(t *testing.T) {
+	d := newFakeController(t, &fakeTransport{r: timeoutReader{}, w: io.Discard}, false)
+	d.SwitchReadMode(1)
+	ctx, cancel := context.WithCancel(context.Background())
+	c := make(chan messages.EnttecDMXUSBProApplicationMessage)
+	result := make(chan error)
+	go func() {
+		result <- d.OnDMXChangeContext(ctx, c, nil)
+	}()
+	cancel()
+	if err := <-result; err != nil {
+		t.Errorf("expected no error after cancel, but got %v", err)
+	}
+	expectClosed(t, c)
+}
+
+func TestControllerOnDMXChangeContextDisconnect(t *testing.T) {
+	host, device := emulator.NewPipe()
+	go emulator.NewWidget(emulator.DefaultConfig()).Serve(device)
+	d := newFakeController(t, host, false)
+	d.SwitchReadMode(0)
+	c := make(chan messages.EnttecDMXUSBProApplicationMessage)
+	result := make(chan error)
+	go func() {
+		result <- d.OnDMXChangeContext(context.Background(), c, nil)
+	}()
+	d.Disconnect()
+	if err := <-result; err != nil {
+		t.Errorf("expected no error after disconnect, but got %v", err)
+	}
+	expectClosed(t, c)
+}
+
+func TestControllerOnDMXChangeContextReadError(t *testing.T) {
+	d := newFakeController(t, &fakeTransport{r: failingReader{err: errors.New("cable pulled")}, w: io.Discard}, false)
+	d.SwitchReadMode(1)
+	c := make(chan messages.EnttecDMXUSBProApplicationMessage)
+	result := make(chan error)
+	go func() {
+		result <- d.OnDMXChangeContext(context.Background(), c, nil)
+	}()
+	if err := <-result; err == nil {
+		t.Errorf("expected read error to be returned")
+	}
+	expectClosed(t, c)
+}
+
+func TestControllerOnDMXChangeContextReportsDroppedData(t *testing.T) {
+	in, widget := io.Pipe()
+	d := newFakeController(t, &fakeTransport{r: in, w: io.Discard}, false)
+	d.SwitchReadMode(1)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := make(chan messages.EnttecDMXUSBProApplicationMessage)
+	errs := make(chan error)
+	go d.OnDMXChangeContext(ctx, c, errs)
+	go widget.Write(make([]byte, messages.MAXIMUM_MESSAGE_LENGTH+1))
+	select {
+	case err := <-errs:
+		if err == nil {
+			t.Errorf("expected an error")
+		}
+	case <-time.After(time.Second):
+		t.Errorf("expected dropped data to be reported")
+	}
+}
+
+// Reader counting its reads
+type countingReader struct {
+	mu    sync.Mutex
+	reads int
+}
+
+func (r *countingReader) Read(buf []byte) (int, error) {
+	r.mu.Lock()
+	r.reads++
+	r.mu.Unlock()
+	time.Sleep(time.Millisecond)
+	return 0, nil
+}
+
+func TestControllerSetReadInterval(t *testing.T) {
+	r := &countingReader{}
+	d := newFakeController(t, &fakeTransport{r: r, w: io.Discard}, false)
+	d.SwitchReadMode(1)
+	d.SetReadInterval(50 * time.Millisecond)
+	ctx, cancel := context.WithTimeout(context.Background(), 120*time.Millisecond)
+	defer cancel()
+	c := make(chan messages.EnttecDMXUSBProApplicationMessage)
+	go d.OnDMXChangeContext(ctx, c, nil)
+	expectClosed(t, c)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.reads < 2 || r.reads > 4 {
+		t.Errorf("expected about 3 reads within 120ms at an interval of 50ms, but got %d", r.reads)
+	}
+}
+
+func TestControllerOnDMXChangeReadIntervalMS(t *testing.T) {
+	d := newFakeController(t, &fakeTransport{r: timeoutReader{}, w: io.Discard}, false)
+	d.SwitchReadMode(1)
+	c := make(chan messages.EnttecDMXUSBProApplicationMessage)
+	go d.OnDMXChange(c, 30)
+	defer d.Disconnect()
+	for start := time.Now(); ; time.Sleep(time.Millisecond) {
+		d.connMu.Lock()
+		interval := d.readInterval
+		d.connMu.Unlock()
+		if interval == 30*time.Millisecond {
+			break
+		}
+		if time.Since(start) > time.Second {
+			t.Fatalf("expected read interval to be 30ms, but was %v", interval)
+		}
+	}
+}
+
+func TestControllerOnDMXChangeTooManyIntervalsPanics(t *testing.T) {
+	d := newFakeController(t, &fakeTransport{r: timeoutReader{}, w: io.Discard}, false)
+	d.SwitchReadMode(1)
+	c := make(chan messages.EnttecDMXUSBProApplicationMessage)
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected a panic for two read intervals")
+		}
+	}()
+	d.OnDMXChange(c, 30, 30)
+}
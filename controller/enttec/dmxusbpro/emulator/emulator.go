@@ -0,0 +1,548 @@
+/*
+Software emulator of the Enttec DMX USB Pro widget.
+
+The emulator speaks the widget protocol on any io.ReadWriter, e.g. a pseudo terminal (see OpenPTY) or an in-memory pipe (see NewPipe).
+
+DMX sent with 'Output Only Send DMX Packet' (label 6) is received by all widgets on the same DMX line. A widget that has not been linked to other widgets receives its own output (loopback).
+*/
+package emulator
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"sync"
+
+	"github.com/H3rby7/usbdmx-golang/controller/enttec/dmxusbpro/messages"
+)
+
+// Prefix for any log and error messages
+const EMULATOR_LOG_PREFIX = "EMU"
+
+// Number of bytes in a DMX packet, including the start code
+const DMX_PACKET_SIZE = 513
+
+// Number of channels covered by one 'Received DMX Change Of State Packet'
+const CHANGE_OF_STATE_BLOCK_SIZE = 40
+
+// Configuration of an emulated widget
+type Config struct {
+	// Serial number as printed on the widget case
+	SerialNumber uint32
+	// Firmware version, reported by 'Get Widget Parameters'
+	FirmwareVersion uint16
+	// DMX output break time in 10.67 microsecond units
+	BreakTime byte
+	// DMX output Mark After Break time in 10.67 microsecond units
+	MABTime byte
+	// DMX output rate in packets per second
+	OutputRate byte
+	// User defined configuration data
+	UserConfig []byte
+	// Number of bytes available for programming firmware, further flash pages are rejected, 0 means unlimited
+	FlashSize int
+	// Labels of the Mk2 API, leave unset to emulate a widget with a single port (see 'DefaultMk2Config')
+	Mk2Labels messages.Mk2Labels
+	// Key activating the Mk2 API
+	APIKey uint32
+}
+
+// Returns a configuration resembling a factory new widget
+func DefaultConfig() Config {
+	return Config{
+		SerialNumber:    1,
+		FirmwareVersion: 1<<8 | 44, // 1.44
+		BreakTime:       9,
+		MABTime:         1,
+		OutputRate:      40,
+		UserConfig:      []byte{},
+	}
+}
+
+// Emulated Enttec DMX USB Pro widget
+type Widget struct {
+	mu   sync.Mutex
+	conf Config
+	// Is the widget in 'only send changes'-mode (as opposed to send always)
+	receiveOnChange bool
+	// Last received DMX packet, beginning with the start code
+	input []byte
+	// DMX line the widget is connected to
+	line *dmxLine
+	// DMX line the second port of a Mk2 is connected to
+	port2Line *dmxLine
+	// Is the widget running the bootstrap, only accepting flash pages
+	bootstrap bool
+	// Firmware programmed since entering the bootstrap
+	firmware []byte
+
+	// Labels understood, including the Mk2 labels if configured
+	labels messages.LabelSet
+	// Has the host activated the Mk2 API using the right key
+	mk2Activated bool
+	// Assignment of port 1 and port 2, see 'messages.MK2_PORT_...'
+	portAssignment [2]byte
+	// Is port 2 in 'only send changes'-mode (as opposed to send always)
+	port2ReceiveOnChange bool
+	// Last DMX packet received on port 2, beginning with the start code
+	port2Input []byte
+
+	// Host connection, receiving replies and unsolicited messages
+	hostMu sync.Mutex
+	host   io.Writer
+
+	logVerbosity uint8
+}
+
+// Widgets and RDM responders connected to each other using DMX cables
+type dmxLine struct {
+	mu      sync.Mutex
+	widgets []*Widget
+	// Mk2 widgets connected using their second port
+	port2Widgets []*Widget
+	responders   []RDMResponder
+}
+
+// Pass the packet to all ports on the line, but the sending one unless it is alone (loopback)
+func (l *dmxLine) transmit(sender *Widget, senderPort int, packet []byte) {
+	l.mu.Lock()
+	widgets := l.widgets
+	port2Widgets := l.port2Widgets
+	l.mu.Unlock()
+	loopback := len(widgets)+len(port2Widgets) == 1
+	for _, receiver := range widgets {
+		if receiver != sender || senderPort != 1 || loopback {
+			receiver.receive(packet)
+		}
+	}
+	for _, receiver := range port2Widgets {
+		if receiver != sender || senderPort != 2 || loopback {
+			receiver.receivePort2(packet)
+		}
+	}
+}
+
+// Device on the DMX line answering RDM requests, e.g. a 'Fixture'
+type RDMResponder interface {
+	// Handle an RDM packet beginning with the start code, returns the raw response or nil if not responding
+	HandleRDM(packet []byte) []byte
+}
+
+// Helper function for creating a new emulated widget
+func NewWidget(conf Config) *Widget {
+	w := &Widget{}
+	w.conf = conf
+	w.input = make([]byte, DMX_PACKET_SIZE)
+	w.line = &dmxLine{widgets: []*Widget{w}}
+	w.port2Line = &dmxLine{port2Widgets: []*Widget{w}}
+	w.portAssignment = [2]byte{messages.MK2_PORT_DMX, messages.MK2_PORT_DISABLED}
+	w.port2Input = make([]byte, DMX_PACKET_SIZE)
+	if conf.IsMk2() {
+		w.labels = conf.Mk2Labels.ToLabelSet()
+	}
+	return w
+}
+
+/*
+Connect the DMX ports of the given widgets, so that the output of one is received by the others.
+
+Linked widgets no longer receive their own output.
+*/
+func Link(widgets ...*Widget) {
+	line := &dmxLine{widgets: append([]*Widget{}, widgets...)}
+	merged := make(map[*dmxLine]bool)
+	for _, w := range widgets {
+		w.mu.Lock()
+		if !merged[w.line] {
+			merged[w.line] = true
+			w.line.mu.Lock()
+			line.responders = append(line.responders, w.line.responders...)
+			w.line.mu.Unlock()
+		}
+		w.line = line
+		w.mu.Unlock()
+	}
+}
+
+// Connect RDM responders to the DMX line of the widget
+func (w *Widget) AttachRDMResponder(responders ...RDMResponder) {
+	w.mu.Lock()
+	line := w.line
+	w.mu.Unlock()
+	line.mu.Lock()
+	defer line.mu.Unlock()
+	line.responders = append(line.responders, responders...)
+}
+
+/*
+Speak the widget protocol on the given connection.
+
+Blocks until reading from the connection fails, returns the error (e.g. io.EOF).
+*/
+func (w *Widget) Serve(conn io.ReadWriter) error {
+	w.hostMu.Lock()
+	w.host = conn
+	w.hostMu.Unlock()
+	defer func() {
+		w.hostMu.Lock()
+		w.host = nil
+		w.hostMu.Unlock()
+	}()
+	r := bufio.NewReaderSize(conn, messages.MAXIMUM_MESSAGE_LENGTH)
+	for {
+		msg, err := readMessage(r, w.labels)
+		if err != nil {
+			return err
+		}
+		w.printf(1, "Received \tlabel=%v \tdata=%v", msg.GetLabel(), msg.GetPayload())
+		w.handle(msg)
+	}
+}
+
+/*
+Read the next valid message from the host.
+
+Bytes not belonging to a valid message are skipped.
+*/
+func readMessage(r *bufio.Reader, labels messages.LabelSet) (msg messages.EnttecDMXUSBProApplicationMessage, err error) {
+	header := make([]byte, messages.NUM_BYTES_BEFORE_PAYLOAD)
+	for {
+		if header[0], err = r.ReadByte(); err != nil {
+			return
+		}
+		if header[0] != messages.MSG_DELIM_START {
+			continue
+		}
+		if _, err = io.ReadFull(r, header[1:]); err != nil {
+			return
+		}
+		dataLength := int(header[messages.MSG_DATA_LENGTH_LSB_INDEX]) + 256*int(header[messages.MSG_DATA_LENGTH_MSB_INDEX])
+		if dataLength > messages.MAXIMUM_DATA_LENGTH {
+			continue
+		}
+		raw := make([]byte, messages.NUM_BYTES_WRAPPER+dataLength)
+		copy(raw, header)
+		if _, err = io.ReadFull(r, raw[messages.NUM_BYTES_BEFORE_PAYLOAD:]); err != nil {
+			return
+		}
+		if parsed, parseErr := messages.FromBytesWithLabels(raw, labels); parseErr == nil {
+			return parsed, nil
+		}
+	}
+}
+
+// React to a message from the host
+func (w *Widget) handle(msg messages.EnttecDMXUSBProApplicationMessage) {
+	payload := msg.GetPayload()
+	w.mu.Lock()
+	bootstrap := w.bootstrap
+	w.mu.Unlock()
+	if bootstrap && msg.GetLabel() != messages.LABEL_PROGRAM_FLASH_PAGE_REQUEST && msg.GetLabel() != messages.LABEL_REPROGRAM_FIRMWARE_REQUEST {
+		w.printf(1, "Ignoring in bootstrap \tlabel=%v", msg.GetLabel())
+		return
+	}
+	switch msg.GetLabel() {
+	case messages.LABEL_REPROGRAM_FIRMWARE_REQUEST:
+		w.startBootstrap()
+	case messages.LABEL_PROGRAM_FLASH_PAGE_REQUEST:
+		w.programFlashPage(payload)
+	case messages.LABEL_GET_WIDGET_PARAMS_REQUEST:
+		w.replyWidgetParameters(payload)
+	case messages.LABEL_SET_WIDGET_PARAMS_REQUEST:
+		w.setWidgetParameters(payload)
+	case messages.LABEL_OUTPUT_ONLY_SEND_DMX_PACKET_REQUEST:
+		w.output(payload)
+	case messages.LABEL_RECEIVE_DMX_ON_CHANGE:
+		w.setReceiveMode(payload)
+	case messages.LABEL_WIDGET_GET_SERIAL_NUMBER_REQUEST:
+		w.replySerialNumber()
+	case messages.LABEL_SEND_RDM_PACKET_REQUEST:
+		w.sendRDM(payload)
+	case messages.LABEL_SEND_RDM_DISCOVERY_REQUEST:
+		w.sendRDMDiscovery(payload)
+	default:
+		if !w.handleMk2(msg) {
+			w.printf(1, "Ignoring unsupported \tlabel=%v", msg.GetLabel())
+		}
+	}
+}
+
+// Run the bootstrap (label 1) and start programming from the first page, restarting is not emulated, so the widget stays in the bootstrap
+func (w *Widget) startBootstrap() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.bootstrap = true
+	w.firmware = make([]byte, 0)
+}
+
+// Program a flash page (label 2), rejecting it if not in the bootstrap, of wrong size or beyond the flash size
+func (w *Widget) programFlashPage(payload []byte) {
+	w.mu.Lock()
+	ok := w.bootstrap && len(payload) == messages.FLASH_PAGE_SIZE
+	if ok && w.conf.FlashSize > 0 && len(w.firmware)+len(payload) > w.conf.FlashSize {
+		ok = false
+	}
+	if ok {
+		w.firmware = append(w.firmware, payload...)
+	}
+	w.mu.Unlock()
+	if !ok {
+		w.send(messages.LABEL_PROGRAM_FLASH_PAGE_REPLY, []byte(messages.FLASH_PAGE_REPLY_FAILURE))
+		return
+	}
+	w.send(messages.LABEL_PROGRAM_FLASH_PAGE_REPLY, []byte(messages.FLASH_PAGE_REPLY_SUCCESS))
+}
+
+// Returns the firmware programmed since entering the bootstrap
+func (w *Widget) GetFirmware() []byte {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return append([]byte{}, w.firmware...)
+}
+
+// Reply to 'Get Widget Parameters' (label 3)
+func (w *Widget) replyWidgetParameters(payload []byte) {
+	if len(payload) < 2 {
+		w.printf(1, "Ignoring get widget parameters request with payload %v", payload)
+		return
+	}
+	userConfigSize := int(payload[0]) + 256*int(payload[1])
+	w.mu.Lock()
+	reply := []byte{
+		byte(w.conf.FirmwareVersion & 0xFF),
+		byte(w.conf.FirmwareVersion >> 8 & 0xFF),
+		w.conf.BreakTime,
+		w.conf.MABTime,
+		w.conf.OutputRate,
+	}
+	userConfig := make([]byte, userConfigSize)
+	copy(userConfig, w.conf.UserConfig)
+	w.mu.Unlock()
+	w.send(messages.LABEL_GET_WIDGET_PARAMS_REPLY, append(reply, userConfig...))
+}
+
+// Apply 'Set Widget Parameters' (label 4)
+func (w *Widget) setWidgetParameters(payload []byte) {
+	if len(payload) < 5 {
+		w.printf(1, "Ignoring set widget parameters request with payload %v", payload)
+		return
+	}
+	userConfigSize := int(payload[0]) + 256*int(payload[1])
+	if len(payload) < 5+userConfigSize {
+		w.printf(1, "Ignoring set widget parameters request with payload %v", payload)
+		return
+	}
+	w.mu.Lock()
+	w.conf.BreakTime = payload[2]
+	w.conf.MABTime = payload[3]
+	w.conf.OutputRate = payload[4]
+	// Only the given bytes are overwritten
+	userConfig := append([]byte{}, payload[5:5+userConfigSize]...)
+	if len(w.conf.UserConfig) > userConfigSize {
+		userConfig = append(userConfig, w.conf.UserConfig[userConfigSize:]...)
+	}
+	w.conf.UserConfig = userConfig
+	w.mu.Unlock()
+}
+
+// Reply to 'Get Widget Serial Number' (label 10)
+func (w *Widget) replySerialNumber() {
+	w.mu.Lock()
+	serial := toBCD(w.conf.SerialNumber)
+	w.mu.Unlock()
+	w.send(messages.LABEL_WIDGET_GET_SERIAL_NUMBER_REPLY, serial)
+}
+
+// Apply 'Receive DMX on Change' (label 8)
+func (w *Widget) setReceiveMode(payload []byte) {
+	if len(payload) < 1 || payload[0] > 1 {
+		w.printf(1, "Ignoring receive DMX on change request with payload %v", payload)
+		return
+	}
+	w.mu.Lock()
+	w.receiveOnChange = payload[0] == 1
+	w.input = make([]byte, DMX_PACKET_SIZE)
+	w.mu.Unlock()
+}
+
+// Send DMX (label 6) to all widgets on the DMX line
+func (w *Widget) output(packet []byte) {
+	if len(packet) > DMX_PACKET_SIZE {
+		w.printf(1, "Ignoring DMX packet of size %d", len(packet))
+		return
+	}
+	w.mu.Lock()
+	line := w.line
+	w.mu.Unlock()
+	line.transmit(w, 1, packet)
+}
+
+/*
+Send an RDM packet (label 7) to the responders on the DMX line.
+
+The response is passed on as 'Received DMX Packet' (label 5), if the widget is in 'send always'-mode.
+Simultaneous responses collide, resulting in a corrupted response.
+*/
+func (w *Widget) sendRDM(packet []byte) {
+	response := w.askResponders(packet)
+	w.mu.Lock()
+	receiveOnChange := w.receiveOnChange
+	w.mu.Unlock()
+	if response == nil || receiveOnChange {
+		return
+	}
+	w.send(messages.LABEL_RECEIVED_DMX_PACKET, append([]byte{0}, response...))
+}
+
+/*
+Send an RDM discovery request (label 11) to the responders on the DMX line.
+
+The response is passed on as 'Received DMX Packet' (label 5), if the widget is in 'send always'-mode.
+If no responder answered, only the receive status is passed on.
+*/
+func (w *Widget) sendRDMDiscovery(packet []byte) {
+	response := w.askResponders(packet)
+	w.mu.Lock()
+	receiveOnChange := w.receiveOnChange
+	w.mu.Unlock()
+	if receiveOnChange {
+		return
+	}
+	w.send(messages.LABEL_RECEIVED_DMX_PACKET, append([]byte{0}, response...))
+}
+
+// Pass the packet to all responders on the DMX line, returns their combined responses or nil if none responded
+func (w *Widget) askResponders(packet []byte) []byte {
+	w.mu.Lock()
+	line := w.line
+	w.mu.Unlock()
+	line.mu.Lock()
+	responders := line.responders
+	line.mu.Unlock()
+	var combined []byte
+	for _, responder := range responders {
+		response := responder.HandleRDM(packet)
+		if response == nil {
+			continue
+		}
+		if combined == nil {
+			combined = response
+			continue
+		}
+		// Collision, the line is driven by multiple responders at once
+		for i := range response {
+			if i < len(combined) {
+				combined[i] |= response[i]
+			} else {
+				combined = append(combined, response[i])
+			}
+		}
+	}
+	return combined
+}
+
+/*
+Receive a DMX packet (beginning with the start code) on the DMX port.
+
+Sends a 'Received DMX Packet' (label 5) or 'Received DMX Change Of State Packet's (label 9) to the host, depending on the receive mode.
+*/
+func (w *Widget) receive(packet []byte) {
+	w.mu.Lock()
+	if !w.receiveOnChange {
+		copy(w.input, packet)
+		w.mu.Unlock()
+		w.send(messages.LABEL_RECEIVED_DMX_PACKET, append([]byte{0}, packet...))
+		return
+	}
+	changes := changeOfStatePackets(w.input, packet)
+	copy(w.input, packet)
+	w.mu.Unlock()
+	for _, change := range changes {
+		w.send(messages.LABEL_RECEIVED_DMX_CHANGE_OF_STATE_PACKET, change)
+	}
+}
+
+/*
+Create the payloads of 'Received DMX Change Of State Packet's describing the difference between the old and new packet.
+
+Each payload covers up to 40 bytes, beginning at a multiple of 8.
+*/
+func changeOfStatePackets(old []byte, packet []byte) [][]byte {
+	payloads := make([][]byte, 0)
+	for i := 0; i < len(packet); i++ {
+		if old[i] == packet[i] {
+			continue
+		}
+		startChangedByteNumber := i / 8
+		payload := make([]byte, 6, 6+CHANGE_OF_STATE_BLOCK_SIZE)
+		payload[0] = byte(startChangedByteNumber)
+		blockStart := startChangedByteNumber * 8
+		for bit := 0; bit < CHANGE_OF_STATE_BLOCK_SIZE && blockStart+bit < len(packet); bit++ {
+			index := blockStart + bit
+			if old[index] != packet[index] {
+				payload[1+bit/8] |= 1 << (bit % 8)
+				payload = append(payload, packet[index])
+			}
+		}
+		payloads = append(payloads, payload)
+		i = blockStart + CHANGE_OF_STATE_BLOCK_SIZE - 1
+	}
+	return payloads
+}
+
+// Send a message to the host, if connected
+func (w *Widget) send(label byte, payload []byte) {
+	msg := messages.NewEnttecDMXUSBProApplicationMessageWithLabels(w.labels, label, payload)
+	packet, err := msg.ToBytes()
+	if err != nil {
+		w.printf(1, "Could not send \tlabel=%v: %v", label, err)
+		return
+	}
+	w.hostMu.Lock()
+	defer w.hostMu.Unlock()
+	if w.host == nil {
+		return
+	}
+	w.printf(1, "Sending \tlabel=%v \tdata=%v", label, payload)
+	if _, err := w.host.Write(packet); err != nil {
+		w.printf(1, "Could not send \tlabel=%v: %v", label, err)
+	}
+}
+
+// Encode the number as 4 BCD bytes, least significant byte first
+func toBCD(number uint32) []byte {
+	out := make([]byte, 4)
+	for i := 0; i < 4; i++ {
+		low := number % 10
+		number /= 10
+		high := number % 10
+		number /= 10
+		out[i] = byte(high<<4 | low)
+	}
+	return out
+}
+
+/*
+Set log verbosity
+
+0 = no logging
+
+1 = message logging
+*/
+func (w *Widget) SetLogVerbosity(verbosity uint8) {
+	if verbosity > 1 {
+		log.Panicf(EMULATOR_LOG_PREFIX+": invalid value, only 0 and 1 are allowed, but got '%d'", verbosity)
+	}
+	w.logVerbosity = verbosity
+}
+
+func (w *Widget) printf(level uint8, format string, v ...any) {
+	if w.logVerbosity == level {
+		log.Printf(EMULATOR_LOG_PREFIX+"(%d): "+format, append([]any{w.conf.SerialNumber}, v...)...)
+	}
+}
+
+func errorf(format string, v ...any) error {
+	return fmt.Errorf(EMULATOR_LOG_PREFIX+": "+format, v...)
+}
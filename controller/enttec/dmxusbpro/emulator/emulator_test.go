@@ -0,0 +1,315 @@
+package emulator
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/H3rby7/usbdmx-golang/controller/enttec/dmxusbpro/messages"
+	"github.com/H3rby7/usbdmx-golang/rdm"
+	"github.com/tarm/serial"
+)
+
+// Host side of a connection to an emulated widget
+type testHost struct {
+	t    *testing.T
+	conn io.ReadWriteCloser
+	msgs chan messages.EnttecDMXUSBProApplicationMessage
+	// Standard labels and the ones of 'DefaultMk2Config'
+	labels messages.LabelSet
+}
+
+// Serve the widget on an in-memory pipe and return the host side
+func serveOnPipe(t *testing.T, w *Widget) *testHost {
+	host, device := NewPipe()
+	go w.Serve(device)
+	t.Cleanup(func() { host.Close() })
+	return newTestHost(t, host)
+}
+
+func newTestHost(t *testing.T, conn io.ReadWriteCloser) *testHost {
+	h := &testHost{t: t, conn: conn, msgs: make(chan messages.EnttecDMXUSBProApplicationMessage, 16)}
+	h.labels = DefaultMk2Config().Mk2Labels.ToLabelSet()
+	go func() {
+		r := bufio.NewReader(conn)
+		for {
+			msg, err := readMessage(r, h.labels)
+			if err != nil {
+				close(h.msgs)
+				return
+			}
+			h.msgs <- msg
+		}
+	}()
+	return h
+}
+
+func (h *testHost) send(label byte, payload []byte) {
+	msg := messages.NewEnttecDMXUSBProApplicationMessageWithLabels(h.labels, label, payload)
+	packet, _ := msg.ToBytes()
+	if _, err := h.conn.Write(packet); err != nil {
+		h.t.Fatalf("expected no error on write, but got %v", err)
+	}
+}
+
+func (h *testHost) receive(expectedLabel byte) []byte {
+	select {
+	case msg := <-h.msgs:
+		if msg.GetLabel() != expectedLabel {
+			h.t.Fatalf("expected label to be %d, but was %d", expectedLabel, msg.GetLabel())
+		}
+		return msg.GetPayload()
+	case <-time.After(time.Second):
+		h.t.Fatalf("expected a message with label %d", expectedLabel)
+	}
+	return nil
+}
+
+func (h *testHost) expectSilence() {
+	select {
+	case msg := <-h.msgs:
+		h.t.Errorf("expected no message, but got label=%d data=%v", msg.GetLabel(), msg.GetPayload())
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestToBCD(t *testing.T) {
+	result := toBCD(12345678)
+	expected := []byte{0x78, 0x56, 0x34, 0x12}
+	if !bytes.Equal(result, expected) {
+		t.Errorf("expected BCD to be %X, but was %X", expected, result)
+	}
+}
+
+func TestSerialNumberReply(t *testing.T) {
+	conf := DefaultConfig()
+	conf.SerialNumber = 2001234
+	h := serveOnPipe(t, NewWidget(conf))
+	h.send(messages.LABEL_WIDGET_GET_SERIAL_NUMBER_REQUEST, []byte{})
+	payload := h.receive(messages.LABEL_WIDGET_GET_SERIAL_NUMBER_REPLY)
+	expected := []byte{0x34, 0x12, 0x00, 0x02}
+	if !bytes.Equal(payload, expected) {
+		t.Errorf("expected serial number to be %X, but was %X", expected, payload)
+	}
+}
+
+func TestSetAndGetWidgetParameters(t *testing.T) {
+	h := serveOnPipe(t, NewWidget(DefaultConfig()))
+	h.send(messages.LABEL_SET_WIDGET_PARAMS_REQUEST, []byte{2, 0, 20, 5, 30, 69, 96})
+	h.send(messages.LABEL_GET_WIDGET_PARAMS_REQUEST, []byte{3, 0})
+	payload := h.receive(messages.LABEL_GET_WIDGET_PARAMS_REPLY)
+	expected := []byte{44, 1, 20, 5, 30, 69, 96, 0}
+	if !bytes.Equal(payload, expected) {
+		t.Errorf("expected parameters to be %v, but were %v", expected, payload)
+	}
+}
+
+func TestLoopbackSendAlways(t *testing.T) {
+	h := serveOnPipe(t, NewWidget(DefaultConfig()))
+	h.send(messages.LABEL_RECEIVE_DMX_ON_CHANGE, []byte{0})
+	h.send(messages.LABEL_OUTPUT_ONLY_SEND_DMX_PACKET_REQUEST, []byte{0, 69, 96})
+	payload := h.receive(messages.LABEL_RECEIVED_DMX_PACKET)
+	expected := []byte{0, 0, 69, 96}
+	if !bytes.Equal(payload, expected) {
+		t.Errorf("expected received DMX packet to be %v, but was %v", expected, payload)
+	}
+}
+
+func TestLoopbackChangesOnly(t *testing.T) {
+	h := serveOnPipe(t, NewWidget(DefaultConfig()))
+	h.send(messages.LABEL_RECEIVE_DMX_ON_CHANGE, []byte{1})
+	frame := make([]byte, DMX_PACKET_SIZE)
+	frame[1] = 1
+	frame[47] = 47
+	frame[48] = 48
+	frame[512] = 255
+	h.send(messages.LABEL_OUTPUT_ONLY_SEND_DMX_PACKET_REQUEST, frame)
+	changes := map[int]byte{}
+	for i := 0; i < 3; i++ {
+		msg := messages.NewEnttecDMXUSBProApplicationMessage(messages.LABEL_RECEIVED_DMX_CHANGE_OF_STATE_PACKET, h.receive(messages.LABEL_RECEIVED_DMX_CHANGE_OF_STATE_PACKET))
+		cs, err := messages.ToChangeSet(msg)
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		for k, v := range cs {
+			changes[k] = v
+		}
+	}
+	expected := map[int]byte{1: 1, 47: 47, 48: 48, 512: 255}
+	if len(changes) != len(expected) {
+		t.Errorf("expected changes to be %v, but were %v", expected, changes)
+	}
+	for k, v := range expected {
+		if changes[k] != v {
+			t.Errorf("expected channel[%d] to be %d, but was %d", k, v, changes[k])
+		}
+	}
+	// Sending the same frame again is no change
+	h.send(messages.LABEL_OUTPUT_ONLY_SEND_DMX_PACKET_REQUEST, frame)
+	h.expectSilence()
+}
+
+func TestLinkedWidgets(t *testing.T) {
+	writer := NewWidget(DefaultConfig())
+	reader := NewWidget(DefaultConfig())
+	Link(writer, reader)
+	w := serveOnPipe(t, writer)
+	r := serveOnPipe(t, reader)
+	r.send(messages.LABEL_RECEIVE_DMX_ON_CHANGE, []byte{0})
+	// Wait until the reader processed its receive mode
+	r.send(messages.LABEL_WIDGET_GET_SERIAL_NUMBER_REQUEST, []byte{})
+	r.receive(messages.LABEL_WIDGET_GET_SERIAL_NUMBER_REPLY)
+	w.send(messages.LABEL_OUTPUT_ONLY_SEND_DMX_PACKET_REQUEST, []byte{0, 69})
+	payload := r.receive(messages.LABEL_RECEIVED_DMX_PACKET)
+	expected := []byte{0, 0, 69}
+	if !bytes.Equal(payload, expected) {
+		t.Errorf("expected received DMX packet to be %v, but was %v", expected, payload)
+	}
+	w.expectSilence()
+}
+
+func TestServeOnPTY(t *testing.T) {
+	pty, err := OpenPTY()
+	if err != nil {
+		t.Skipf("pseudo terminals not available: %v", err)
+	}
+	conf := DefaultConfig()
+	conf.SerialNumber = 42
+	go NewWidget(conf).Serve(pty)
+	port, err := serial.OpenPort(&serial.Config{Name: pty.Name, Baud: 57600})
+	if err != nil {
+		t.Fatalf("expected no error opening %s, but got %v", pty.Name, err)
+	}
+	h := newTestHost(t, port)
+	// Closing the terminal first ends the blocking read of the serial port
+	defer func() {
+		pty.Close()
+		port.Close()
+	}()
+	h.send(messages.LABEL_WIDGET_GET_SERIAL_NUMBER_REQUEST, []byte{})
+	payload := h.receive(messages.LABEL_WIDGET_GET_SERIAL_NUMBER_REPLY)
+	expected := []byte{0x42, 0, 0, 0}
+	if !bytes.Equal(payload, expected) {
+		t.Errorf("expected serial number to be %X, but was %X", expected, payload)
+	}
+}
+
+func TestProgramFirmware(t *testing.T) {
+	conf := DefaultConfig()
+	conf.FlashSize = messages.FLASH_PAGE_SIZE
+	w := NewWidget(conf)
+	h := serveOnPipe(t, w)
+	page := bytes.Repeat([]byte{0x69}, messages.FLASH_PAGE_SIZE)
+	// Not in bootstrap yet
+	h.send(messages.LABEL_PROGRAM_FLASH_PAGE_REQUEST, page)
+	if reply := h.receive(messages.LABEL_PROGRAM_FLASH_PAGE_REPLY); string(reply) != "FALS" {
+		t.Errorf("expected reply to be 'FALS', but was '%s'", reply)
+	}
+	h.send(messages.LABEL_REPROGRAM_FIRMWARE_REQUEST, []byte{})
+	h.send(messages.LABEL_PROGRAM_FLASH_PAGE_REQUEST, page)
+	if reply := h.receive(messages.LABEL_PROGRAM_FLASH_PAGE_REPLY); string(reply) != "TRUE" {
+		t.Errorf("expected reply to be 'TRUE', but was '%s'", reply)
+	}
+	// Flash is full
+	h.send(messages.LABEL_PROGRAM_FLASH_PAGE_REQUEST, page)
+	if reply := h.receive(messages.LABEL_PROGRAM_FLASH_PAGE_REPLY); string(reply) != "FALS" {
+		t.Errorf("expected reply to be 'FALS', but was '%s'", reply)
+	}
+	if firmware := w.GetFirmware(); !bytes.Equal(firmware, page) {
+		t.Errorf("expected firmware to be %v, but was %v", page, firmware)
+	}
+	// Other requests are ignored in bootstrap
+	h.send(messages.LABEL_WIDGET_GET_SERIAL_NUMBER_REQUEST, []byte{})
+	h.expectSilence()
+}
+
+func newRDMRequest(destination rdm.UID, commandClass byte, pid uint16, data []byte) []byte {
+	request := rdm.Message{
+		Destination:       destination,
+		Source:            rdm.NewUID(0x454E, 1),
+		TransactionNumber: 1,
+		PortID:            1,
+		CommandClass:      commandClass,
+		PID:               pid,
+		ParameterData:     data,
+	}
+	raw, _ := request.ToBytes()
+	return raw
+}
+
+func TestRDMFixture(t *testing.T) {
+	w := NewWidget(DefaultConfig())
+	uid := rdm.NewUID(0x1234, 1)
+	fixture := NewFixture(DefaultFixtureConfig(uid))
+	w.AttachRDMResponder(fixture, NewFixture(DefaultFixtureConfig(rdm.NewUID(0x1234, 2))))
+	h := serveOnPipe(t, w)
+	h.send(messages.LABEL_SEND_RDM_PACKET_REQUEST, newRDMRequest(uid, rdm.SET_COMMAND, rdm.PID_DMX_START_ADDRESS, []byte{0, 69}))
+	payload := h.receive(messages.LABEL_RECEIVED_DMX_PACKET)
+	response, err := rdm.FromBytes(payload[1:])
+	if err != nil {
+		t.Fatalf("expected a valid RDM response, but got %v", err)
+	}
+	if response.Source != uid || response.GetResponseType() != rdm.RESPONSE_TYPE_ACK {
+		t.Errorf("expected ACK from %v, but was response type %d from %v", uid, response.GetResponseType(), response.Source)
+	}
+	if address := fixture.GetConfig().StartAddress; address != 69 {
+		t.Errorf("expected start address to be %d, but was %d", 69, address)
+	}
+	// Broadcasts are applied without response
+	h.send(messages.LABEL_SEND_RDM_PACKET_REQUEST, newRDMRequest(rdm.BROADCAST_UID, rdm.SET_COMMAND, rdm.PID_DEVICE_LABEL, []byte("moving head")))
+	h.expectSilence()
+	if label := fixture.GetConfig().Label; label != "moving head" {
+		t.Errorf("expected label to be 'moving head', but was '%s'", label)
+	}
+	// Unknown PIDs are refused
+	h.send(messages.LABEL_SEND_RDM_PACKET_REQUEST, newRDMRequest(uid, rdm.GET_COMMAND, 0x8000, nil))
+	response, _ = rdm.FromBytes(h.receive(messages.LABEL_RECEIVED_DMX_PACKET)[1:])
+	if response.GetResponseType() != rdm.RESPONSE_TYPE_NACK_REASON || !bytes.Equal(response.ParameterData, []byte{0, rdm.NACK_REASON_UNKNOWN_PID}) {
+		t.Errorf("expected NACK for unknown PID, but was response type %d with %v", response.GetResponseType(), response.ParameterData)
+	}
+}
+
+func TestRDMDiscoveryFixture(t *testing.T) {
+	w := NewWidget(DefaultConfig())
+	uid := rdm.NewUID(0x1234, 1)
+	w.AttachRDMResponder(NewFixture(DefaultFixtureConfig(uid)))
+	h := serveOnPipe(t, w)
+	branch := rdm.NewDiscoverUniqueBranchData(rdm.MINIMUM_UID, rdm.MAXIMUM_UID)
+	h.send(messages.LABEL_SEND_RDM_DISCOVERY_REQUEST, newRDMRequest(rdm.BROADCAST_UID, rdm.DISCOVERY_COMMAND, rdm.PID_DISC_UNIQUE_BRANCH, branch))
+	payload := h.receive(messages.LABEL_RECEIVED_DMX_PACKET)
+	if found, err := rdm.DecodeDiscoveryResponse(payload[1:]); err != nil || found != uid {
+		t.Fatalf("expected discovery response of %v, but got %v and %v", uid, found, err)
+	}
+	h.send(messages.LABEL_SEND_RDM_PACKET_REQUEST, newRDMRequest(uid, rdm.DISCOVERY_COMMAND, rdm.PID_DISC_MUTE, nil))
+	response, err := rdm.FromBytes(h.receive(messages.LABEL_RECEIVED_DMX_PACKET)[1:])
+	if err != nil || response.CommandClass != rdm.DISCOVERY_COMMAND_RESPONSE {
+		t.Fatalf("expected mute to be acknowledged, but got %v and %v", response, err)
+	}
+	// Muted fixtures do not respond, only the receive status is passed on
+	h.send(messages.LABEL_SEND_RDM_DISCOVERY_REQUEST, newRDMRequest(rdm.BROADCAST_UID, rdm.DISCOVERY_COMMAND, rdm.PID_DISC_UNIQUE_BRANCH, branch))
+	if payload := h.receive(messages.LABEL_RECEIVED_DMX_PACKET); len(payload) != 1 {
+		t.Errorf("expected only the receive status, but got %v", payload)
+	}
+}
+
+func TestPipeReadTimeout(t *testing.T) {
+	host, device := NewPipeWithReadTimeout(10 * time.Millisecond)
+	buf := make([]byte, 8)
+	start := time.Now()
+	if n, err := host.Read(buf); n != 0 || err != nil {
+		t.Errorf("expected 0 bytes and no error after the timeout, but got %d and %v", n, err)
+	}
+	if elapsed := time.Since(start); elapsed < 10*time.Millisecond {
+		t.Errorf("expected the read to wait for the timeout, but returned after %v", elapsed)
+	}
+	device.Write([]byte{1, 2})
+	if n, err := host.Read(buf); n != 2 || err != nil {
+		t.Errorf("expected 2 bytes, but got %d and %v", n, err)
+	}
+	device.Close()
+	if _, err := host.Read(buf); err == nil {
+		t.Errorf("expected an error after closing")
+	}
+}
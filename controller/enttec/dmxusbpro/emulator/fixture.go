@@ -0,0 +1,242 @@
+package emulator
+
+import (
+	"sync"
+
+	"github.com/H3rby7/usbdmx-golang/rdm"
+)
+
+// Configuration of an emulated fixture
+type FixtureConfig struct {
+	// Unique ID of the fixture
+	UID rdm.UID
+	// Model ID, as reported by DEVICE_INFO
+	Model uint16
+	// Product category, as reported by DEVICE_INFO
+	ProductCategory uint16
+	// Software version ID, as reported by DEVICE_INFO
+	SoftwareVersion uint32
+	// Description of the software version
+	SoftwareVersionLabel string
+	// Number of DMX channels used
+	Footprint uint16
+	// Selected DMX personality, counting from 1
+	Personality byte
+	// Number of DMX personalities
+	PersonalityCount byte
+	// First DMX channel used
+	StartAddress uint16
+	// User defined name
+	Label string
+	// Whether the fixture identifies itself
+	Identify bool
+	// Hours the lamp was on
+	LampHours uint32
+	// Present value of each sensor
+	Sensors []int16
+}
+
+// Returns a configuration resembling a factory new fixture with the given UID
+func DefaultFixtureConfig(uid rdm.UID) FixtureConfig {
+	return FixtureConfig{
+		UID:                  uid,
+		Model:                1,
+		ProductCategory:      0x0100, // Fixture
+		SoftwareVersion:      1,
+		SoftwareVersionLabel: "1.0",
+		Footprint:            1,
+		Personality:          1,
+		PersonalityCount:     1,
+		StartAddress:         1,
+		Label:                "",
+	}
+}
+
+// Emulated RDM fixture, attach it to a widget's DMX line with 'AttachRDMResponder'
+type Fixture struct {
+	mu   sync.Mutex
+	conf FixtureConfig
+	// Muted fixtures do not respond to DISC_UNIQUE_BRANCH
+	muted bool
+}
+
+// Helper function for creating a new emulated fixture
+func NewFixture(conf FixtureConfig) *Fixture {
+	return &Fixture{conf: conf}
+}
+
+// Returns the current configuration, reflecting SET requests
+func (f *Fixture) GetConfig() FixtureConfig {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.conf
+}
+
+/*
+Handle an RDM request beginning with the start code.
+
+Requests not addressed to the fixture and broadcasts are not responded to, though broadcasts are applied.
+DISC_UNIQUE_BRANCH is the exception, it is responded to with the encoded UID, see 'rdm.EncodeDiscoveryResponse'.
+*/
+func (f *Fixture) HandleRDM(packet []byte) []byte {
+	request, err := rdm.FromBytes(packet)
+	if err != nil || request.IsResponse() {
+		return nil
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	addressed := request.Destination == f.conf.UID
+	broadcast := request.Destination == rdm.BROADCAST_UID || request.Destination == rdm.ManufacturerBroadcastUID(f.conf.UID.GetManufacturer())
+	if !addressed && !broadcast {
+		return nil
+	}
+	if request.CommandClass == rdm.DISCOVERY_COMMAND {
+		return f.handleDiscovery(request, addressed)
+	}
+	responseType, data := f.handle(request)
+	if !addressed {
+		return nil
+	}
+	response := rdm.NewResponse(request, responseType, data)
+	response.Source = f.conf.UID
+	raw, _ := response.ToBytes()
+	return raw
+}
+
+// Handle the request, 'mu' must be held
+func (f *Fixture) handle(request rdm.Message) (responseType byte, data []byte) {
+	if request.SubDevice != rdm.ROOT_DEVICE {
+		return nack(rdm.NACK_REASON_SUB_DEVICE_OUT_OF_RANGE)
+	}
+	get := request.CommandClass == rdm.GET_COMMAND
+	set := request.CommandClass == rdm.SET_COMMAND
+	switch {
+	case get && request.PID == rdm.PID_SUPPORTED_PARAMETERS:
+		return rdm.RESPONSE_TYPE_ACK, supportedParameters()
+	case get && request.PID == rdm.PID_DEVICE_INFO:
+		return rdm.RESPONSE_TYPE_ACK, f.deviceInfo().ToBytes()
+	case get && request.PID == rdm.PID_SOFTWARE_VERSION_LABEL:
+		return rdm.RESPONSE_TYPE_ACK, []byte(f.conf.SoftwareVersionLabel)
+	case get && request.PID == rdm.PID_DEVICE_LABEL:
+		return rdm.RESPONSE_TYPE_ACK, []byte(f.conf.Label)
+	case set && request.PID == rdm.PID_DEVICE_LABEL:
+		if len(request.ParameterData) > rdm.MAXIMUM_LABEL_LENGTH {
+			return nack(rdm.NACK_REASON_FORMAT_ERROR)
+		}
+		f.conf.Label = string(request.ParameterData)
+		return rdm.RESPONSE_TYPE_ACK, nil
+	case get && request.PID == rdm.PID_DMX_PERSONALITY:
+		return rdm.RESPONSE_TYPE_ACK, []byte{f.conf.Personality, f.conf.PersonalityCount}
+	case set && request.PID == rdm.PID_DMX_PERSONALITY:
+		if len(request.ParameterData) != 1 {
+			return nack(rdm.NACK_REASON_FORMAT_ERROR)
+		}
+		if request.ParameterData[0] < 1 || request.ParameterData[0] > f.conf.PersonalityCount {
+			return nack(rdm.NACK_REASON_DATA_OUT_OF_RANGE)
+		}
+		f.conf.Personality = request.ParameterData[0]
+		return rdm.RESPONSE_TYPE_ACK, nil
+	case get && request.PID == rdm.PID_DMX_START_ADDRESS:
+		return rdm.RESPONSE_TYPE_ACK, []byte{byte(f.conf.StartAddress >> 8), byte(f.conf.StartAddress)}
+	case set && request.PID == rdm.PID_DMX_START_ADDRESS:
+		if len(request.ParameterData) != 2 {
+			return nack(rdm.NACK_REASON_FORMAT_ERROR)
+		}
+		address := uint16(request.ParameterData[0])<<8 | uint16(request.ParameterData[1])
+		if address < rdm.MINIMUM_DMX_START_ADDRESS || address > rdm.MAXIMUM_DMX_START_ADDRESS {
+			return nack(rdm.NACK_REASON_DATA_OUT_OF_RANGE)
+		}
+		f.conf.StartAddress = address
+		return rdm.RESPONSE_TYPE_ACK, nil
+	case get && request.PID == rdm.PID_SENSOR_VALUE:
+		if len(request.ParameterData) != 1 {
+			return nack(rdm.NACK_REASON_FORMAT_ERROR)
+		}
+		sensor := int(request.ParameterData[0])
+		if sensor >= len(f.conf.Sensors) {
+			return nack(rdm.NACK_REASON_DATA_OUT_OF_RANGE)
+		}
+		// Lowest, highest and recorded values are not tracked
+		value := uint16(f.conf.Sensors[sensor])
+		return rdm.RESPONSE_TYPE_ACK, []byte{byte(sensor), byte(value >> 8), byte(value), 0, 0, 0, 0, 0, 0}
+	case get && request.PID == rdm.PID_LAMP_HOURS:
+		h := f.conf.LampHours
+		return rdm.RESPONSE_TYPE_ACK, []byte{byte(h >> 24), byte(h >> 16), byte(h >> 8), byte(h)}
+	case get && request.PID == rdm.PID_IDENTIFY_DEVICE:
+		return rdm.RESPONSE_TYPE_ACK, []byte{boolToByte(f.conf.Identify)}
+	case set && request.PID == rdm.PID_IDENTIFY_DEVICE:
+		if len(request.ParameterData) != 1 || request.ParameterData[0] > 1 {
+			return nack(rdm.NACK_REASON_FORMAT_ERROR)
+		}
+		f.conf.Identify = request.ParameterData[0] == 1
+		return rdm.RESPONSE_TYPE_ACK, nil
+	}
+	return nack(rdm.NACK_REASON_UNKNOWN_PID)
+}
+
+/*
+Handle a discovery request, 'mu' must be held.
+
+Returns the response to DISC_UNIQUE_BRANCH, if within the range and not muted.
+*/
+func (f *Fixture) handleDiscovery(request rdm.Message, addressed bool) []byte {
+	switch request.PID {
+	case rdm.PID_DISC_UNIQUE_BRANCH:
+		lower, upper, err := rdm.ToDiscoverUniqueBranchRange(request.ParameterData)
+		if err != nil || f.muted || f.conf.UID < lower || f.conf.UID > upper {
+			return nil
+		}
+		return rdm.EncodeDiscoveryResponse(f.conf.UID)
+	case rdm.PID_DISC_MUTE:
+		f.muted = true
+	case rdm.PID_DISC_UN_MUTE:
+		f.muted = false
+	default:
+		return nil
+	}
+	if !addressed {
+		return nil
+	}
+	// Control field, no flags set
+	response := rdm.NewResponse(request, rdm.RESPONSE_TYPE_ACK, []byte{0, 0})
+	response.Source = f.conf.UID
+	raw, _ := response.ToBytes()
+	return raw
+}
+
+// Device info as reported by DEVICE_INFO, 'mu' must be held
+func (f *Fixture) deviceInfo() rdm.DeviceInfo {
+	c := f.conf
+	return rdm.DeviceInfo{
+		ProtocolVersion:    0x0100,
+		Model:              c.Model,
+		ProductCategory:    c.ProductCategory,
+		SoftwareVersion:    c.SoftwareVersion,
+		Footprint:          c.Footprint,
+		CurrentPersonality: c.Personality,
+		PersonalityCount:   c.PersonalityCount,
+		StartAddress:       c.StartAddress,
+		SensorCount:        byte(len(c.Sensors)),
+	}
+}
+
+// Optional PIDs answered by the fixture, as reported by SUPPORTED_PARAMETERS
+func supportedParameters() []byte {
+	pids := []uint16{rdm.PID_DEVICE_LABEL, rdm.PID_DMX_PERSONALITY, rdm.PID_SENSOR_VALUE, rdm.PID_LAMP_HOURS}
+	data := make([]byte, 0, 2*len(pids))
+	for _, pid := range pids {
+		data = append(data, byte(pid>>8), byte(pid))
+	}
+	return data
+}
+
+func boolToByte(b bool) byte {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func nack(reason uint16) (byte, []byte) {
+	return rdm.RESPONSE_TYPE_NACK_REASON, []byte{byte(reason >> 8), byte(reason)}
+}
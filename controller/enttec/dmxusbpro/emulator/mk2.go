@@ -0,0 +1,165 @@
+package emulator
+
+import (
+	"github.com/H3rby7/usbdmx-golang/controller/enttec/dmxusbpro/messages"
+)
+
+/*
+Returns a configuration resembling a factory new DMX USB Pro Mk2.
+
+The labels and the API key are made up for emulation, real ones are distributed by Enttec.
+*/
+func DefaultMk2Config() Config {
+	conf := DefaultConfig()
+	conf.Mk2Labels = messages.Mk2Labels{
+		SetAPIKey:                     200,
+		SetPortAssignment:             201,
+		SendDMXPort2:                  202,
+		ReceiveDMXOnChangePort2:       203,
+		ReceivedDMXPort2:              204,
+		ReceivedDMXChangeOfStatePort2: 205,
+	}
+	conf.APIKey = 0xC0FFEE42
+	return conf
+}
+
+// Returns whether the Mk2 API is emulated
+func (c Config) IsMk2() bool {
+	return c.Mk2Labels != messages.Mk2Labels{}
+}
+
+// Returns whether the host activated the Mk2 API using the right key
+func (w *Widget) IsMk2Activated() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.mk2Activated
+}
+
+/*
+React to a message with a Mk2 label, returns false if the label is not one of them.
+
+Messages other than the API key are ignored until the API is activated.
+*/
+func (w *Widget) handleMk2(msg messages.EnttecDMXUSBProApplicationMessage) bool {
+	if !w.conf.IsMk2() {
+		return false
+	}
+	labels := w.conf.Mk2Labels
+	payload := msg.GetPayload()
+	if msg.GetLabel() == labels.SetAPIKey {
+		w.setAPIKey(msg)
+		return true
+	}
+	switch msg.GetLabel() {
+	case labels.SetPortAssignment, labels.SendDMXPort2, labels.ReceiveDMXOnChangePort2:
+	default:
+		return false
+	}
+	if !w.IsMk2Activated() {
+		w.printf(1, "Ignoring before activating the Mk2 API \tlabel=%v", msg.GetLabel())
+		return true
+	}
+	switch msg.GetLabel() {
+	case labels.SetPortAssignment:
+		w.setPortAssignment(payload)
+	case labels.SendDMXPort2:
+		w.outputPort2(payload)
+	case labels.ReceiveDMXOnChangePort2:
+		w.setReceiveModePort2(payload)
+	}
+	return true
+}
+
+// Activate the Mk2 API, a wrong key deactivates it
+func (w *Widget) setAPIKey(msg messages.EnttecDMXUSBProApplicationMessage) {
+	key, err := messages.ToMk2APIKey(msg)
+	if err != nil {
+		w.printf(1, "Ignoring API key, %v", err)
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.mk2Activated = key == w.conf.APIKey
+}
+
+// Apply the port assignment, one byte per port
+func (w *Widget) setPortAssignment(payload []byte) {
+	if len(payload) != 2 || payload[0] > messages.MK2_PORT_DMX || payload[1] > messages.MK2_PORT_DMX {
+		w.printf(1, "Ignoring port assignment with payload %v", payload)
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.portAssignment = [2]byte{payload[0], payload[1]}
+}
+
+// Apply 'Receive DMX on Change' for port 2
+func (w *Widget) setReceiveModePort2(payload []byte) {
+	if len(payload) < 1 || payload[0] > 1 {
+		w.printf(1, "Ignoring receive DMX on change request for port 2 with payload %v", payload)
+		return
+	}
+	w.mu.Lock()
+	w.port2ReceiveOnChange = payload[0] == 1
+	w.port2Input = make([]byte, DMX_PACKET_SIZE)
+	w.mu.Unlock()
+}
+
+/*
+Connect the second DMX port of the Mk2 to the DMX ports of the given widgets, like 'Link' does for the first port.
+
+A second port that has not been linked receives its own output (loopback).
+*/
+func LinkPort2(mk2 *Widget, widgets ...*Widget) {
+	line := &dmxLine{widgets: append([]*Widget{}, widgets...), port2Widgets: []*Widget{mk2}}
+	for _, w := range widgets {
+		w.mu.Lock()
+		w.line.mu.Lock()
+		line.responders = append(line.responders, w.line.responders...)
+		w.line.mu.Unlock()
+		w.line = line
+		w.mu.Unlock()
+	}
+	mk2.mu.Lock()
+	mk2.port2Line = line
+	mk2.mu.Unlock()
+}
+
+// Send DMX out of port 2 to all widgets on its DMX line, if assigned
+func (w *Widget) outputPort2(packet []byte) {
+	if len(packet) > DMX_PACKET_SIZE {
+		w.printf(1, "Ignoring DMX packet of size %d for port 2", len(packet))
+		return
+	}
+	w.mu.Lock()
+	assigned := w.portAssignment[1] == messages.MK2_PORT_DMX
+	line := w.port2Line
+	w.mu.Unlock()
+	if !assigned {
+		w.printf(1, "Ignoring DMX packet for unassigned port 2")
+		return
+	}
+	line.transmit(w, 2, packet)
+}
+
+// Receive a DMX packet (beginning with the start code) on port 2, like 'receive' does for port 1, if assigned
+func (w *Widget) receivePort2(packet []byte) {
+	labels := w.conf.Mk2Labels
+	w.mu.Lock()
+	if w.portAssignment[1] != messages.MK2_PORT_DMX {
+		w.mu.Unlock()
+		return
+	}
+	if !w.port2ReceiveOnChange {
+		copy(w.port2Input, packet)
+		w.mu.Unlock()
+		w.send(labels.ReceivedDMXPort2, append([]byte{0}, packet...))
+		return
+	}
+	changes := changeOfStatePackets(w.port2Input, packet)
+	copy(w.port2Input, packet)
+	w.mu.Unlock()
+	for _, change := range changes {
+		w.send(labels.ReceivedDMXChangeOfStatePort2, change)
+	}
+}
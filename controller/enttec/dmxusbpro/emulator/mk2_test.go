@@ -0,0 +1,72 @@
+package emulator
+
+import (
+	"testing"
+
+	"github.com/H3rby7/usbdmx-golang/controller/enttec/dmxusbpro/messages"
+)
+
+// Serve a Mk2 on a pipe, activate its API and assign both ports
+func serveActivatedMk2(t *testing.T) (*Widget, *testHost, messages.Mk2Labels) {
+	conf := DefaultMk2Config()
+	w := NewWidget(conf)
+	h := serveOnPipe(t, w)
+	h.send(conf.Mk2Labels.SetAPIKey, messages.NewMk2APIKeyPayload(conf.APIKey))
+	h.send(conf.Mk2Labels.SetPortAssignment, []byte{messages.MK2_PORT_DMX, messages.MK2_PORT_DMX})
+	return w, h, conf.Mk2Labels
+}
+
+func TestMk2IgnoresPort2BeforeActivation(t *testing.T) {
+	conf := DefaultMk2Config()
+	w := NewWidget(conf)
+	h := serveOnPipe(t, w)
+	h.send(conf.Mk2Labels.SetAPIKey, messages.NewMk2APIKeyPayload(conf.APIKey+1))
+	h.send(conf.Mk2Labels.SetPortAssignment, []byte{messages.MK2_PORT_DMX, messages.MK2_PORT_DMX})
+	h.send(conf.Mk2Labels.SendDMXPort2, []byte{0, 1, 2})
+	h.expectSilence()
+	if w.IsMk2Activated() {
+		t.Errorf("expected the Mk2 API to stay inactive using a wrong key")
+	}
+}
+
+func TestMk2Port2Loopback(t *testing.T) {
+	w, h, labels := serveActivatedMk2(t)
+	h.send(labels.SendDMXPort2, []byte{0, 1, 2})
+	payload := h.receive(labels.ReceivedDMXPort2)
+	expected := []byte{0, 0, 1, 2}
+	for i := range expected {
+		if payload[i] != expected[i] {
+			t.Errorf("expected byte[%d] to be %d, but was %d", i, expected[i], payload[i])
+		}
+	}
+	if !w.IsMk2Activated() {
+		t.Errorf("expected the Mk2 API to be active")
+	}
+}
+
+func TestMk2PortsAreIndependent(t *testing.T) {
+	_, h, labels := serveActivatedMk2(t)
+	h.send(labels.ReceiveDMXOnChangePort2, []byte{1})
+	h.send(messages.LABEL_RECEIVE_DMX_ON_CHANGE, []byte{1})
+	h.send(labels.SendDMXPort2, []byte{0, 0, 7})
+	change := h.receive(labels.ReceivedDMXChangeOfStatePort2)
+	cs, err := messages.ToChangeSet(messages.NewEnttecDMXUSBProApplicationMessage(messages.LABEL_RECEIVED_DMX_CHANGE_OF_STATE_PACKET, change))
+	if err != nil {
+		t.Fatalf("did not expect error, but got '%v'", err)
+	}
+	if len(cs) != 1 || cs[2] != 7 {
+		t.Errorf("expected only channel 2 to change to 7 on port 2, but got %v", cs)
+	}
+	// Port 1 did not receive anything
+	h.expectSilence()
+	h.send(messages.LABEL_OUTPUT_ONLY_SEND_DMX_PACKET_REQUEST, []byte{0, 5})
+	h.receive(messages.LABEL_RECEIVED_DMX_CHANGE_OF_STATE_PACKET)
+	h.expectSilence()
+}
+
+func TestMk2UnassignedPort2(t *testing.T) {
+	_, h, labels := serveActivatedMk2(t)
+	h.send(labels.SetPortAssignment, []byte{messages.MK2_PORT_DMX, messages.MK2_PORT_DISABLED})
+	h.send(labels.SendDMXPort2, []byte{0, 1})
+	h.expectSilence()
+}
@@ -0,0 +1,118 @@
+package emulator
+
+import (
+	"bytes"
+	"io"
+	"sync"
+	"time"
+)
+
+// Buffered, one directional in-memory pipe
+type halfPipe struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	buf    bytes.Buffer
+	closed bool
+}
+
+func newHalfPipe() *halfPipe {
+	p := &halfPipe{}
+	p.cond = sync.NewCond(&p.mu)
+	return p
+}
+
+// Never blocks, as the data is buffered
+func (p *halfPipe) write(b []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return 0, io.ErrClosedPipe
+	}
+	n, err := p.buf.Write(b)
+	p.cond.Broadcast()
+	return n, err
+}
+
+// Blocks until data is available, the pipe is closed or the timeout passed, 0 waits forever
+func (p *halfPipe) read(b []byte, timeout time.Duration) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	timedOut := false
+	if timeout > 0 && p.buf.Len() == 0 && !p.closed {
+		timer := time.AfterFunc(timeout, func() {
+			p.mu.Lock()
+			defer p.mu.Unlock()
+			timedOut = true
+			p.cond.Broadcast()
+		})
+		defer timer.Stop()
+	}
+	for p.buf.Len() == 0 && !p.closed && !timedOut {
+		p.cond.Wait()
+	}
+	if p.buf.Len() == 0 {
+		if p.closed {
+			return 0, io.EOF
+		}
+		return 0, nil
+	}
+	return p.buf.Read(b)
+}
+
+func (p *halfPipe) close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.closed = true
+	p.cond.Broadcast()
+}
+
+// One end of an in-memory connection
+type pipeEnd struct {
+	r *halfPipe
+	w *halfPipe
+	// Reads return 0 bytes after this long, 0 waits forever
+	readTimeout time.Duration
+}
+
+func (e *pipeEnd) Read(b []byte) (int, error) {
+	return e.r.read(b, e.readTimeout)
+}
+
+func (e *pipeEnd) Write(b []byte) (int, error) {
+	return e.w.write(b)
+}
+
+// Closing either end closes the whole connection
+func (e *pipeEnd) Close() error {
+	e.r.close()
+	e.w.close()
+	return nil
+}
+
+/*
+Create a buffered, in-memory connection to use instead of a serial port.
+
+Writes never block. Reads block until data is available or either end is closed.
+
+Example useage:
+
+	host, device := emulator.NewPipe()
+	go emulator.NewWidget(emulator.DefaultConfig()).Serve(device)
+	// use 'host' as Transport for the controller
+*/
+func NewPipe() (host io.ReadWriteCloser, device io.ReadWriteCloser) {
+	return NewPipeWithReadTimeout(0)
+}
+
+/*
+Like 'NewPipe', but reads of the host return 0 bytes if no data arrived within the timeout, like a serial port does.
+
+Use it for code expecting a read timeout, e.g. to stop a routine reading the host.
+*/
+func NewPipeWithReadTimeout(timeout time.Duration) (host io.ReadWriteCloser, device io.ReadWriteCloser) {
+	toDevice := newHalfPipe()
+	toHost := newHalfPipe()
+	host = &pipeEnd{r: toHost, w: toDevice, readTimeout: timeout}
+	device = &pipeEnd{r: toDevice, w: toHost}
+	return
+}
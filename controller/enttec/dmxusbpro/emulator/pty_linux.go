@@ -0,0 +1,105 @@
+//go:build linux
+
+package emulator
+
+import (
+	"fmt"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// Pseudo terminal, clients open 'Name' as if it was the serial port of a widget
+type PTY struct {
+	// Path of the terminal to be opened by clients, e.g. "/dev/pts/3"
+	Name string
+	// Side of the terminal used by the emulator
+	master *os.File
+	// Kept open, so reading from master does not fail while no client is connected
+	slave *os.File
+}
+
+// Open a new pseudo terminal in raw mode
+func OpenPTY() (*PTY, error) {
+	master, err := os.OpenFile("/dev/ptmx", os.O_RDWR|unix.O_NOCTTY, 0)
+	if err != nil {
+		return nil, errorf("could not open pseudo terminal: %v", err)
+	}
+	if err := control(master, func(fd int) error {
+		return unix.IoctlSetPointerInt(fd, unix.TIOCSPTLCK, 0)
+	}); err != nil {
+		master.Close()
+		return nil, errorf("could not unlock pseudo terminal: %v", err)
+	}
+	var number int
+	if err := control(master, func(fd int) (err error) {
+		number, err = unix.IoctlGetInt(fd, unix.TIOCGPTN)
+		return
+	}); err != nil {
+		master.Close()
+		return nil, errorf("could not get pseudo terminal number: %v", err)
+	}
+	name := fmt.Sprintf("/dev/pts/%d", number)
+	slave, err := os.OpenFile(name, os.O_RDWR|unix.O_NOCTTY, 0)
+	if err != nil {
+		master.Close()
+		return nil, errorf("could not open %s: %v", name, err)
+	}
+	if err := control(slave, makeRaw); err != nil {
+		slave.Close()
+		master.Close()
+		return nil, errorf("could not set %s to raw mode: %v", name, err)
+	}
+	return &PTY{Name: name, master: master, slave: slave}, nil
+}
+
+/*
+Run the function with the file descriptor of the file.
+
+Unlike 'os.File.Fd()' this keeps the file in non-blocking mode, so that closing it interrupts pending reads.
+*/
+func control(f *os.File, fn func(fd int) error) error {
+	conn, err := f.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var fnErr error
+	if err := conn.Control(func(fd uintptr) {
+		fnErr = fn(int(fd))
+	}); err != nil {
+		return err
+	}
+	return fnErr
+}
+
+// Disable any processing of the terminal data, like cfmakeraw(3)
+func makeRaw(fd int) error {
+	t, err := unix.IoctlGetTermios(fd, unix.TCGETS)
+	if err != nil {
+		return err
+	}
+	t.Iflag &^= unix.IGNBRK | unix.BRKINT | unix.PARMRK | unix.ISTRIP | unix.INLCR | unix.IGNCR | unix.ICRNL | unix.IXON
+	t.Oflag &^= unix.OPOST
+	t.Lflag &^= unix.ECHO | unix.ECHONL | unix.ICANON | unix.ISIG | unix.IEXTEN
+	t.Cflag &^= unix.CSIZE | unix.PARENB
+	t.Cflag |= unix.CS8
+	t.Cc[unix.VMIN] = 1
+	t.Cc[unix.VTIME] = 0
+	return unix.IoctlSetTermios(fd, unix.TCSETS, t)
+}
+
+// Read data written by the client
+func (p *PTY) Read(buf []byte) (int, error) {
+	return p.master.Read(buf)
+}
+
+// Write data to be read by the client
+func (p *PTY) Write(buf []byte) (int, error) {
+	return p.master.Write(buf)
+}
+
+// Close the pseudo terminal
+func (p *PTY) Close() error {
+	p.slave.Close()
+	return p.master.Close()
+}
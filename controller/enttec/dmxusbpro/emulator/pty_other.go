@@ -0,0 +1,26 @@
+//go:build !linux
+
+package emulator
+
+// Pseudo terminal, clients open 'Name' as if it was the serial port of a widget
+type PTY struct {
+	// Path of the terminal to be opened by clients, e.g. "/dev/pts/3"
+	Name string
+}
+
+// Pseudo terminals are only supported on linux
+func OpenPTY() (*PTY, error) {
+	return nil, errorf("pseudo terminals are only supported on linux")
+}
+
+func (p *PTY) Read(buf []byte) (int, error) {
+	return 0, errorf("pseudo terminals are only supported on linux")
+}
+
+func (p *PTY) Write(buf []byte) (int, error) {
+	return 0, errorf("pseudo terminals are only supported on linux")
+}
+
+func (p *PTY) Close() error {
+	return nil
+}
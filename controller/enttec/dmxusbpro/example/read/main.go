@@ -1,9 +1,9 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"log"
-	"os"
 	"os/signal"
 	"syscall"
 
@@ -12,25 +12,6 @@ import (
 	"github.com/tarm/serial"
 )
 
-var controller *dmxusbpro.EnttecDMXUSBProController
-
-func handleCancel() {
-	c := make(chan os.Signal, 1)
-	signal.Notify(c,
-		// https://www.gnu.org/software/libc/manual/html_node/Termination-Signals.html
-		syscall.SIGTERM, // "the normal way to politely ask a program to terminate"
-		syscall.SIGINT,  // Ctrl+C
-		syscall.SIGQUIT, // Ctrl-\
-		syscall.SIGHUP,  // "terminal is disconnected"
-	)
-	go func() {
-		for range c {
-			controller.Disconnect()
-			break
-		}
-	}()
-}
-
 func main() {
 	baud := flag.Int("baud", 57600, "Baudrate for the device")
 	name := flag.String("name", "", "Input interface (e.g. COM4 OR /dev/tty.usbserial)")
@@ -40,20 +21,42 @@ func main() {
 	config := &serial.Config{Name: *name, Baud: *baud}
 
 	// Create a controller and connect to it
-	controller = dmxusbpro.NewEnttecDMXUSBProController(config, 16, false)
+	controller := dmxusbpro.NewEnttecDMXUSBProController(config, 16, false)
 	if err := controller.Connect(); err != nil {
 		log.Fatalf("Failed to connect DMX Controller: %s", err)
 	}
-	handleCancel()
+	defer controller.Disconnect()
+
+	// Stop reading on cancel
+	ctx, stop := signal.NotifyContext(context.Background(),
+		// https://www.gnu.org/software/libc/manual/html_node/Termination-Signals.html
+		syscall.SIGTERM, // "the normal way to politely ask a program to terminate"
+		syscall.SIGINT,  // Ctrl+C
+		syscall.SIGQUIT, // Ctrl-\
+		syscall.SIGHUP,  // "terminal is disconnected"
+	)
+	defer stop()
+
 	controller.SwitchReadMode(1)
 	c := make(chan messages.EnttecDMXUSBProApplicationMessage)
-	go controller.OnDMXChange(c, 30)
+	go func() {
+		if err := controller.OnDMXChangeContext(ctx, c, nil); err != nil {
+			log.Printf("Stopped reading: %s", err)
+		}
+	}()
+	// Mirror the absolute state of the universe from the change sets
+	universe := dmxusbpro.NewUniverse()
+	changes := make(chan map[int]byte, 8)
+	universe.Subscribe(changes)
+	go func() {
+		for changed := range changes {
+			log.Printf("Changed channels \t%v", changed)
+		}
+	}()
 	for msg := range c {
-		cs, err := messages.ToChangeSet(msg)
-		if err != nil {
-			log.Printf("Could not convert to changeset, but read \tlabel=%v \tdata=%v", msg.GetLabel(), msg.GetPayload())
-		} else {
-			log.Printf("Changeset is \t%v", cs)
+		if err := universe.Apply(msg); err != nil {
+			log.Printf("Could not apply, but read \tlabel=%v \tdata=%v", msg.GetLabel(), msg.GetPayload())
 		}
 	}
+	log.Printf("Finished.")
 }
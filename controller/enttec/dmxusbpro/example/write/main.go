@@ -10,12 +10,17 @@ import (
 
 	usbdmxgolang "github.com/H3rby7/usbdmx-golang"
 	"github.com/H3rby7/usbdmx-golang/controller/enttec/dmxusbpro"
+	_ "github.com/H3rby7/usbdmx-golang/controller/enttec/opendmx"
+	_ "github.com/H3rby7/usbdmx-golang/controller/virtual"
 	"github.com/tarm/serial"
 )
 
 var controller usbdmxgolang.DMXController
 var isRunning bool
 
+// Set if the controller sends the stage by itself
+var refreshing bool
+
 func handleCancel() {
 	c := make(chan os.Signal, 1)
 	signal.Notify(c,
@@ -41,19 +46,38 @@ func handleCancel() {
 func main() {
 	baud := flag.Int("baud", 57600, "Baudrate for the device")
 	name := flag.String("name", "", "Input interface (e.g. COM4 OR /dev/tty.usbserial)")
+	uri := flag.String("uri", "", "Controller URI, used instead of --name and --baud (e.g. enttec-open:///dev/ttyUSB0?channels=16)")
 	flag.Parse()
 
-	// Create a configuration from our flags
-	config := &serial.Config{Name: *name, Baud: *baud}
+	var pro *dmxusbpro.EnttecDMXUSBProController
+	if *uri != "" {
+		// Let the URI select the controller
+		var err error
+		if controller, err = usbdmxgolang.Open(*uri); err != nil {
+			log.Fatalf("Failed to open DMX Controller: %s", err)
+		}
+	} else {
+		// Create a configuration from our flags
+		config := &serial.Config{Name: *name, Baud: *baud}
+		pro = dmxusbpro.NewEnttecDMXUSBProController(config, 16, true)
+		controller = pro
+	}
 
-	// Create a controller and connect to it
-	controller = dmxusbpro.NewEnttecDMXUSBProController(config, 16, true)
+	// Connect to the controller
 	if err := controller.Connect(); err != nil {
 		log.Fatalf("Failed to connect DMX Controller: %s", err)
 	}
 	isRunning = true
 	handleCancel()
 
+	if pro != nil {
+		// Let the controller send the stage, so staging is enough
+		if err := pro.StartRefresh(dmxusbpro.DefaultRefreshConfig()); err != nil {
+			log.Fatalf("Failed to start refreshing: %s", err)
+		}
+		refreshing = true
+	}
+
 	// Open shutter
 	controller.Stage(10, 255)
 	// Open dimmer
@@ -85,8 +109,10 @@ func main() {
 
 		log.Printf("CHAN %d -> %d \t CHAN %d -> %d \t CHAN %d -> %d", rgbStartChannel, r, rgbStartChannel+1, g, rgbStartChannel+2, b)
 
-		if err := controller.Commit(); err != nil {
-			log.Fatalf("Failed to commit output: %s", err)
+		if !refreshing {
+			if err := controller.Commit(); err != nil {
+				log.Fatalf("Failed to commit output: %s", err)
+			}
 		}
 
 		time.Sleep(time.Second * 2)
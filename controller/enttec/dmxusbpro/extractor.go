@@ -12,6 +12,8 @@ Returns:
 * found messages
 
 * unUsedBytes (any bytes AFTER the last detected message that have not yet been used)
+
+Deprecated: Tries every pair of delimiters, use 'Decoder' to find messages by their declared length instead.
 */
 func Extract(serialData []byte) (msgs []messages.EnttecDMXUSBProApplicationMessage, unUsedBytes []byte) {
 	// List of extracted messages
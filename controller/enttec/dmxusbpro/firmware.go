@@ -0,0 +1,100 @@
+package dmxusbpro
+
+import (
+	"context"
+	"time"
+
+	"github.com/H3rby7/usbdmx-golang/controller/enttec/dmxusbpro/messages"
+)
+
+// Time the widget needs to start the bootstrap, before flash pages can be programmed
+const FIRMWARE_BOOTSTRAP_DELAY = 500 * time.Millisecond
+
+// Progress of a firmware update
+type FirmwareProgress struct {
+	// Number of flash pages programmed so far
+	Page int
+	// Total number of flash pages
+	Pages int
+}
+
+/*
+Program the firmware binary into the widget.
+
+Runs the widget bootstrap (label 1) and programs the firmware in pages of 64 bytes (label 2), the last page is padded with 0xFF.
+Stops at the first page the widget rejects.
+
+Progress is sent to 'progress' after every page, unless it is nil. Notifications are dropped if the channel is not ready, so it should be buffered.
+
+Cancelling the context stops between two pages. Once started, the widget stays in the bootstrap until a firmware update completes, so call again to start over.
+
+Do not use the controller otherwise while updating.
+
+Example useage:
+
+	firmware, err := os.ReadFile("dmx_usb_pro_v1_44.bin")
+	progress := make(chan dmxusbpro.FirmwareProgress, 8)
+	go func() {
+		for p := range progress { ... } // show progress
+	}()
+	err = controller.UpdateFirmware(ctx, firmware, progress)
+	close(progress)
+*/
+func (d *EnttecDMXUSBProController) UpdateFirmware(ctx context.Context, firmware []byte, progress chan<- FirmwareProgress) error {
+	if len(firmware) == 0 {
+		return d.errorf("firmware is empty")
+	}
+	pages := toFlashPages(firmware)
+	if err := ctx.Err(); err != nil {
+		return d.errorf("firmware update aborted before starting, %v", err)
+	}
+	d.printf(1, "Starting bootstrap to program %d flash pages", len(pages))
+	start := messages.NewEnttecDMXUSBProApplicationMessage(messages.LABEL_REPROGRAM_FIRMWARE_REQUEST, []byte{})
+	if err := d.writeMessage(start); err != nil {
+		return err
+	}
+	select {
+	case <-time.After(FIRMWARE_BOOTSTRAP_DELAY):
+	case <-ctx.Done():
+		return d.errorf("firmware update aborted before programming, widget stays in bootstrap, %v", ctx.Err())
+	}
+	for i, page := range pages {
+		if err := ctx.Err(); err != nil {
+			return d.errorf("firmware update aborted after %d of %d pages, widget stays in bootstrap, %v", i, len(pages), err)
+		}
+		msg := messages.NewEnttecDMXUSBProApplicationMessage(messages.LABEL_PROGRAM_FLASH_PAGE_REQUEST, page)
+		reply, err := d.request(msg, messages.LABEL_PROGRAM_FLASH_PAGE_REPLY)
+		if err != nil {
+			return d.errorf("programming page %d of %d failed, %v", i+1, len(pages), err)
+		}
+		ok, err := messages.ToFlashPageResult(reply)
+		if err != nil {
+			return d.errorf("programming page %d of %d failed, %v", i+1, len(pages), err)
+		}
+		if !ok {
+			return d.errorf("widget rejected page %d of %d", i+1, len(pages))
+		}
+		if progress != nil {
+			select {
+			case progress <- FirmwareProgress{Page: i + 1, Pages: len(pages)}:
+			default:
+			}
+		}
+	}
+	d.printf(1, "Programmed %d flash pages", len(pages))
+	return nil
+}
+
+// Split the firmware into flash pages, padding the last one with 0xFF
+func toFlashPages(firmware []byte) [][]byte {
+	pages := make([][]byte, 0, (len(firmware)+messages.FLASH_PAGE_SIZE-1)/messages.FLASH_PAGE_SIZE)
+	for i := 0; i < len(firmware); i += messages.FLASH_PAGE_SIZE {
+		page := make([]byte, messages.FLASH_PAGE_SIZE)
+		n := copy(page, firmware[i:])
+		for j := n; j < len(page); j++ {
+			page[j] = 0xFF
+		}
+		pages = append(pages, page)
+	}
+	return pages
+}
@@ -0,0 +1,70 @@
+package dmxusbpro
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/H3rby7/usbdmx-golang/controller/enttec/dmxusbpro/emulator"
+	"github.com/H3rby7/usbdmx-golang/controller/enttec/dmxusbpro/messages"
+)
+
+func newTestFirmware(size int) []byte {
+	firmware := make([]byte, size)
+	for i := range firmware {
+		firmware[i] = byte(i)
+	}
+	return firmware
+}
+
+func TestUpdateFirmware(t *testing.T) {
+	widget := emulator.NewWidget(emulator.DefaultConfig())
+	host, device := emulator.NewPipe()
+	go widget.Serve(device)
+	d := newFakeController(t, host, true)
+	defer d.Disconnect()
+	firmware := newTestFirmware(150)
+	progress := make(chan FirmwareProgress, 8)
+	if err := d.UpdateFirmware(context.Background(), firmware, progress); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	expected := append(append([]byte{}, firmware...), bytes.Repeat([]byte{0xFF}, 3*messages.FLASH_PAGE_SIZE-150)...)
+	if programmed := widget.GetFirmware(); !bytes.Equal(programmed, expected) {
+		t.Errorf("expected programmed firmware to be %v, but was %v", expected, programmed)
+	}
+	for page := 1; page <= 3; page++ {
+		if p := <-progress; p.Page != page || p.Pages != 3 {
+			t.Errorf("expected progress to be page %d of 3, but was %d of %d", page, p.Page, p.Pages)
+		}
+	}
+}
+
+func TestUpdateFirmwareFailedPage(t *testing.T) {
+	conf := emulator.DefaultConfig()
+	conf.FlashSize = 2 * messages.FLASH_PAGE_SIZE
+	widget := emulator.NewWidget(conf)
+	host, device := emulator.NewPipe()
+	go widget.Serve(device)
+	d := newFakeController(t, host, true)
+	defer d.Disconnect()
+	progress := make(chan FirmwareProgress, 8)
+	if err := d.UpdateFirmware(context.Background(), newTestFirmware(4*messages.FLASH_PAGE_SIZE), progress); err == nil {
+		t.Fatalf("expected an error, as the third page does not fit")
+	}
+	if len(progress) != 2 {
+		t.Errorf("expected progress for 2 pages, but got %d", len(progress))
+	}
+}
+
+func TestUpdateFirmwareAbortsBeforeStarting(t *testing.T) {
+	out := &bytes.Buffer{}
+	d := newFakeController(t, &fakeTransport{w: out}, true)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := d.UpdateFirmware(ctx, newTestFirmware(10), nil); err == nil {
+		t.Errorf("expected an error, as the context is done")
+	}
+	if out.Len() != 0 {
+		t.Errorf("expected the bootstrap not to be started, but %v was written", out.Bytes())
+	}
+}
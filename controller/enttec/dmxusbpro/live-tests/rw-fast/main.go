@@ -21,7 +21,7 @@ func main() {
 	baud := flag.Int("baud", 57600, "Baudrate for the devices")
 	readerName := flag.String("reader", "", "Input interface (e.g. COM4 OR /dev/tty1.usbserial)")
 	writerName := flag.String("writer", "", "Output interface (e.g. COM5 OR /dev/tty2.usbserial)")
-	readInterval := flag.Int("read-interval", 30, "Interval between reads in MS")
+	readInterval := flag.Int("read-interval", 0, "Pause between reads in millis, 0 reads as soon as data arrives")
 	writeInterval := flag.Int("write-interval", 30, "Interval between writes in MS")
 	flag.Parse()
 	go read(&serial.Config{Name: *readerName, Baud: *baud}, *readInterval)
@@ -57,7 +57,8 @@ func read(config *serial.Config, interval int) {
 	}
 	readController.SwitchReadMode(1)
 	c := make(chan messages.EnttecDMXUSBProApplicationMessage)
-	go readController.OnDMXChange(c, interval)
+	readController.SetReadInterval(time.Millisecond * time.Duration(interval))
+	go readController.OnDMXChange(c)
 	for msg := range c {
 		cs, err := messages.ToChangeSet(msg)
 		if err != nil {
@@ -25,7 +25,7 @@ func main() {
 	baud := flag.Int("baud", 57600, "Baudrate for the devices")
 	readerName := flag.String("reader", "", "Input interface (e.g. COM4 OR /dev/tty1.usbserial)")
 	writerName := flag.String("writer", "", "Output interface (e.g. COM5 OR /dev/tty2.usbserial)")
-	readInterval := flag.Int("read-interval", 30, "Interval between reads in millis")
+	readInterval := flag.Int("read-interval", 0, "Pause between reads in millis, 0 reads as soon as data arrives")
 	writeInterval := flag.Int("write-interval", 30, "Interval between writes in millis")
 	changesOnly := flag.Bool("changes-only", false, "Read, using changes only")
 	flag.Parse()
@@ -67,7 +67,8 @@ func read(config *serial.Config, interval int, changesOnly bool) {
 		readController.SwitchReadMode(0)
 	}
 	c := make(chan messages.EnttecDMXUSBProApplicationMessage)
-	go readController.OnDMXChange(c, interval)
+	readController.SetReadInterval(time.Millisecond * time.Duration(interval))
+	go readController.OnDMXChange(c)
 	for msg := range c {
 		if changesOnly {
 			cs, err := messages.ToChangeSet(msg)
@@ -77,11 +78,13 @@ func read(config *serial.Config, interval int, changesOnly bool) {
 				log.Printf("READER\tChangeset is:\t%v", cs)
 			}
 		} else {
-			arr, err := messages.ToDMXArray(msg)
+			packet, err := messages.ToReceivedDMXPacket(msg)
 			if err != nil {
-				log.Printf("READER\tCould not convert to array, but read \tlabel=%v \tdata=%v", msg.GetLabel(), msg.GetPayload())
+				log.Printf("READER\tCould not convert to packet, but read \tlabel=%v \tdata=%v", msg.GetLabel(), msg.GetPayload())
+			} else if !packet.Status.IsOK() || !packet.IsDMX() {
+				log.Printf("READER\tSkipping packet \tstart-code=%X \toverflow=%v \toverrun=%v", packet.StartCode, packet.Status.IsQueueOverflow(), packet.Status.IsOverrun())
 			} else {
-				log.Printf("READER\tDMX values are:\t%v", arr)
+				log.Printf("READER\tDMX values are:\t%v", packet.Data)
 			}
 		}
 	}
@@ -46,3 +46,34 @@ const (
 	// Biggest possible label-index to identify the message type
 	BIGGEST_LABEL_INDEX = 11
 )
+
+/*
+Labels accepted when creating or decoding messages.
+
+The zero value accepts the standard labels only, 'With' adds further labels, e.g. the ones distributed with an API key (see 'Mk2Labels').
+*/
+type LabelSet struct {
+	extra map[byte]bool
+}
+
+// Returns whether the label is within [SMALLEST_LABEL_INDEX..BIGGEST_LABEL_INDEX]
+func IsStandardLabel(label byte) bool {
+	return label >= SMALLEST_LABEL_INDEX && label <= BIGGEST_LABEL_INDEX
+}
+
+// Returns a copy of the set accepting the given labels too
+func (s LabelSet) With(labels ...byte) LabelSet {
+	extra := make(map[byte]bool, len(s.extra)+len(labels))
+	for label := range s.extra {
+		extra[label] = true
+	}
+	for _, label := range labels {
+		extra[label] = true
+	}
+	return LabelSet{extra: extra}
+}
+
+// Returns whether the label is accepted
+func (s LabelSet) Contains(label byte) bool {
+	return IsStandardLabel(label) || s.extra[label]
+}
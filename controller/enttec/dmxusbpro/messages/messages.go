@@ -43,10 +43,18 @@ type EnttecDMXUSBProApplicationMessage struct {
 
 // Helper function to create a new Message
 func NewEnttecDMXUSBProApplicationMessage(label byte, payload []byte) EnttecDMXUSBProApplicationMessage {
+	return NewEnttecDMXUSBProApplicationMessageWithLabels(LabelSet{}, label, payload)
+}
+
+// Helper function to create a new Message, with any label of the given set
+func NewEnttecDMXUSBProApplicationMessageWithLabels(labels LabelSet, label byte, payload []byte) EnttecDMXUSBProApplicationMessage {
 	dataLength := len(payload)
 	if dataLength > MAXIMUM_DATA_LENGTH {
 		log.Panicf("maximum data length [%d bytes] exceeded, actually was [%d]", MAXIMUM_DATA_LENGTH, dataLength)
 	}
+	if labels.Contains(label) {
+		return EnttecDMXUSBProApplicationMessage{label: label, payload: payload}
+	}
 	if label < SMALLEST_LABEL_INDEX {
 		log.Panicf("message label must be at least %d, but is %d", SMALLEST_LABEL_INDEX, label)
 	}
@@ -97,7 +105,12 @@ func (msg *EnttecDMXUSBProApplicationMessage) ToBytes() ([]byte, error) {
 
 // Create from the byte structure, if possible
 func FromBytes(raw []byte) (msg EnttecDMXUSBProApplicationMessage, err error) {
-	if err = validateSchema(raw); err != nil {
+	return FromBytesWithLabels(raw, LabelSet{})
+}
+
+// Create from the byte structure, if possible, accepting any label of the given set
+func FromBytesWithLabels(raw []byte, labels LabelSet) (msg EnttecDMXUSBProApplicationMessage, err error) {
+	if err = validateSchema(raw, labels); err != nil {
 		return
 	}
 	if err = validateSize(raw); err != nil {
@@ -117,7 +130,7 @@ func FromBytes(raw []byte) (msg EnttecDMXUSBProApplicationMessage, err error) {
 
 Return error if any validation fails, else nil.
 */
-func validateSchema(raw []byte) error {
+func validateSchema(raw []byte, labels LabelSet) error {
 	size := len(raw)
 	if size < NUM_BYTES_WRAPPER {
 		return fmt.Errorf("message of size %d bytes is too small - must be at least %d bytes", size, NUM_BYTES_WRAPPER)
@@ -132,6 +145,9 @@ func validateSchema(raw []byte) error {
 		return fmt.Errorf("message must end with %X, but is %X", MSG_DELIM_END, raw[size-1])
 	}
 	label := raw[MSG_LABEL_INDEX]
+	if labels.Contains(label) {
+		return nil
+	}
 	if label < SMALLEST_LABEL_INDEX {
 		return fmt.Errorf("message label must be at least %d, but is %d", SMALLEST_LABEL_INDEX, label)
 	}
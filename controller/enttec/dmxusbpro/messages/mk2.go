@@ -0,0 +1,76 @@
+package messages
+
+import "fmt"
+
+// Number of bytes of the key activating the Mk2 API
+const MK2_API_KEY_SIZE = 4
+
+const (
+	// Port assignment of a port that neither sends nor receives
+	MK2_PORT_DISABLED = 0
+	// Port assignment of a port sending or receiving DMX
+	MK2_PORT_DMX = 1
+)
+
+/*
+Labels of the DMX USB Pro Mk2 API, used for its second DMX port.
+
+Enttec distributes them along with the API key, so they must be configured instead of being constants.
+The standard labels (see 'labels.go') keep working on the first port.
+*/
+type Mk2Labels struct {
+	// Activates the Mk2 API, payload is the API key (see 'NewMk2APIKeyPayload')
+	SetAPIKey byte
+	// Enables or disables the ports, payload is one 'MK2_PORT_...' byte per port
+	SetPortAssignment byte
+	// Like 'LABEL_OUTPUT_ONLY_SEND_DMX_PACKET_REQUEST', for port 2
+	SendDMXPort2 byte
+	// Like 'LABEL_RECEIVE_DMX_ON_CHANGE', for port 2
+	ReceiveDMXOnChangePort2 byte
+	// Like 'LABEL_RECEIVED_DMX_PACKET', for port 2
+	ReceivedDMXPort2 byte
+	// Like 'LABEL_RECEIVED_DMX_CHANGE_OF_STATE_PACKET', for port 2
+	ReceivedDMXChangeOfStatePort2 byte
+}
+
+// Returns the labels in order of declaration
+func (l Mk2Labels) all() []byte {
+	return []byte{l.SetAPIKey, l.SetPortAssignment, l.SendDMXPort2, l.ReceiveDMXOnChangePort2, l.ReceivedDMXPort2, l.ReceivedDMXChangeOfStatePort2}
+}
+
+// Return an error if a label is not set, is a standard label or is used twice
+func (l Mk2Labels) Validate() error {
+	seen := make(map[byte]bool)
+	for _, label := range l.all() {
+		if label == 0 {
+			return fmt.Errorf("all Mk2 labels must be set, but one is 0")
+		}
+		if IsStandardLabel(label) {
+			return fmt.Errorf("Mk2 label %d collides with a standard label", label)
+		}
+		if seen[label] {
+			return fmt.Errorf("Mk2 label %d is used twice", label)
+		}
+		seen[label] = true
+	}
+	return nil
+}
+
+// Returns the standard labels and the Mk2 labels
+func (l Mk2Labels) ToLabelSet() LabelSet {
+	return LabelSet{}.With(l.all()...)
+}
+
+// Payload of the 'SetAPIKey' message, least significant byte first
+func NewMk2APIKeyPayload(key uint32) []byte {
+	return []byte{byte(key & 0xFF), byte(key >> 8 & 0xFF), byte(key >> 16 & 0xFF), byte(key >> 24 & 0xFF)}
+}
+
+// Extract the API key from the payload of the 'SetAPIKey' message
+func ToMk2APIKey(msg EnttecDMXUSBProApplicationMessage) (uint32, error) {
+	payload := msg.GetPayload()
+	if len(payload) != MK2_API_KEY_SIZE {
+		return 0, fmt.Errorf("API key must be %d bytes, but was %d", MK2_API_KEY_SIZE, len(payload))
+	}
+	return uint32(payload[0]) | uint32(payload[1])<<8 | uint32(payload[2])<<16 | uint32(payload[3])<<24, nil
+}
@@ -0,0 +1,82 @@
+package messages
+
+import "testing"
+
+var testMk2Labels = Mk2Labels{
+	SetAPIKey:                     200,
+	SetPortAssignment:             201,
+	SendDMXPort2:                  202,
+	ReceiveDMXOnChangePort2:       203,
+	ReceivedDMXPort2:              204,
+	ReceivedDMXChangeOfStatePort2: 205,
+}
+
+func TestFromBytesWithLabels(t *testing.T) {
+	labels := testMk2Labels.ToLabelSet()
+	input := []byte{0x7E, 204, 1, 0, 42, 0xE7}
+	result, err := FromBytesWithLabels(input, labels)
+	if err != nil {
+		t.Errorf("did not expect error, but got '%v'", err)
+	}
+	if result.GetLabel() != 204 {
+		t.Errorf("expected result label to be %d, but got %d", 204, result.GetLabel())
+	}
+	if _, err := FromBytesWithLabels([]byte{0x7E, 206, 0, 0, 0xE7}, labels); err == nil {
+		t.Errorf("expected error, because label 206 is not in the set")
+	}
+	if _, err := FromBytesWithLabels([]byte{0x7E, 6, 0, 0, 0xE7}, labels); err != nil {
+		t.Errorf("expected standard labels to be accepted, but got '%v'", err)
+	}
+}
+
+func TestLabelSetWithDoesNotModify(t *testing.T) {
+	base := LabelSet{}.With(100)
+	extended := base.With(101)
+	if base.Contains(101) {
+		t.Errorf("expected 'With' to leave the original set unchanged")
+	}
+	if !extended.Contains(100) || !extended.Contains(101) {
+		t.Errorf("expected extended set to contain 100 and 101")
+	}
+}
+
+func TestMk2LabelsValidate(t *testing.T) {
+	if err := testMk2Labels.Validate(); err != nil {
+		t.Errorf("did not expect error, but got '%v'", err)
+	}
+	unset := testMk2Labels
+	unset.SendDMXPort2 = 0
+	if err := unset.Validate(); err == nil {
+		t.Errorf("expected error, because a label is not set")
+	}
+	standard := testMk2Labels
+	standard.SendDMXPort2 = LABEL_OUTPUT_ONLY_SEND_DMX_PACKET_REQUEST
+	if err := standard.Validate(); err == nil {
+		t.Errorf("expected error, because a label collides with a standard label")
+	}
+	twice := testMk2Labels
+	twice.ReceivedDMXPort2 = twice.SendDMXPort2
+	if err := twice.Validate(); err == nil {
+		t.Errorf("expected error, because a label is used twice")
+	}
+}
+
+func TestMk2APIKey(t *testing.T) {
+	payload := NewMk2APIKeyPayload(0x12345678)
+	expected := []byte{0x78, 0x56, 0x34, 0x12}
+	for i := range expected {
+		if payload[i] != expected[i] {
+			t.Errorf("expected byte[%d] to be %X, but was %X", i, expected[i], payload[i])
+		}
+	}
+	key, err := ToMk2APIKey(NewEnttecDMXUSBProApplicationMessageWithLabels(testMk2Labels.ToLabelSet(), testMk2Labels.SetAPIKey, payload))
+	if err != nil {
+		t.Errorf("did not expect error, but got '%v'", err)
+	}
+	if key != 0x12345678 {
+		t.Errorf("expected key to be %X, but was %X", 0x12345678, key)
+	}
+	if _, err := ToMk2APIKey(NewEnttecDMXUSBProApplicationMessage(LABEL_RECEIVE_DMX_ON_CHANGE, []byte{1})); err == nil {
+		t.Errorf("expected error, because the payload is too short")
+	}
+}
@@ -2,6 +2,7 @@ package messages
 
 import (
 	"fmt"
+	"time"
 )
 
 /*
@@ -30,8 +31,12 @@ func ToChangeSet(msg EnttecDMXUSBProApplicationMessage) (map[int]byte, error) {
 	changedBitArray := bytesToBools(msg.payload[1:6])
 	changedDMXDataArray := msg.payload[6:]
 	changedByteIndex := 0
-	for bitArrayIndex := 0; bitArrayIndex < 39; bitArrayIndex++ {
+	// The 5 bytes of the changed bit array cover 40 channels, the pseudo-code in the API docs stops at 39
+	for bitArrayIndex := 0; bitArrayIndex < 40; bitArrayIndex++ {
 		if changedBitArray[bitArrayIndex] {
+			if changedByteIndex >= len(changedDMXDataArray) {
+				return nil, fmt.Errorf("changed bit array announces more than the '%d' contained bytes", len(changedDMXDataArray))
+			}
 			m[startChangedByteNumber*8+bitArrayIndex] = changedDMXDataArray[changedByteIndex]
 			changedByteIndex++
 		}
@@ -40,6 +45,88 @@ func ToChangeSet(msg EnttecDMXUSBProApplicationMessage) (map[int]byte, error) {
 	return m, nil
 }
 
+const (
+	// Bit of the DMX receive status, set if the widget receive queue overflowed
+	RECEIVE_STATUS_QUEUE_OVERFLOW = 0x01
+	// Bit of the DMX receive status, set if a widget receive overrun occurred
+	RECEIVE_STATUS_OVERRUN = 0x02
+)
+
+const (
+	// Start code of DMX packets carrying dimmer levels
+	DMX_START_CODE = 0x00
+	// Start code of ASCII text packets
+	TEXT_PACKET_START_CODE = 0x17
+	// Start code of system information packets
+	SYSTEM_INFORMATION_PACKET_START_CODE = 0xCF
+)
+
+// DMX receive status, the first byte of a 'Received DMX Packet'
+type ReceiveStatus byte
+
+// Whether the widget receive queue overflowed, so packets were lost before this one
+func (s ReceiveStatus) IsQueueOverflow() bool {
+	return s&RECEIVE_STATUS_QUEUE_OVERFLOW != 0
+}
+
+// Whether a widget receive overrun occurred, so the packet may be incomplete
+func (s ReceiveStatus) IsOverrun() bool {
+	return s&RECEIVE_STATUS_OVERRUN != 0
+}
+
+// Whether no error bit is set
+func (s ReceiveStatus) IsOK() bool {
+	return s == 0
+}
+
+// Packet received on the DMX port, of any start code
+type ReceivedDMXPacket struct {
+	// Receive status reported by the widget
+	Status ReceiveStatus
+	// Whether the packet has a start code, the widget may only report the status after an overflow or overrun
+	HasStartCode bool
+	// First byte of the packet, e.g. 'DMX_START_CODE' or 'RDM_START_CODE'
+	StartCode byte
+	// Slots following the start code
+	Data []byte
+}
+
+// Whether the packet carries dimmer levels (start code 0)
+func (p ReceivedDMXPacket) IsDMX() bool {
+	return p.HasStartCode && p.StartCode == DMX_START_CODE
+}
+
+// Whether the packet is an RDM message (start code 0xCC)
+func (p ReceivedDMXPacket) IsRDM() bool {
+	return p.HasStartCode && p.StartCode == RDM_START_CODE
+}
+
+/*
+	Convert a message according to the 'Received DMX Packet' structure, keeping the status and any start code.
+
+Message must have label '5' and at least 1 byte
+
+0    - DMX receive status, see 'RECEIVE_STATUS_QUEUE_OVERFLOW' and 'RECEIVE_STATUS_OVERRUN'
+
+1    - Start code, missing if the widget only reports the status (e.g. after an overflow)
+
+2 - 513  - Received slots. Get Size from overall msg size.
+
+The data shares memory with the message.
+*/
+func ToReceivedDMXPacket(msg EnttecDMXUSBProApplicationMessage) (ReceivedDMXPacket, error) {
+	if msg.label != LABEL_RECEIVED_DMX_PACKET {
+		return ReceivedDMXPacket{}, fmt.Errorf("wrong label, expected '%d', but got '%d'", LABEL_RECEIVED_DMX_PACKET, msg.label)
+	}
+	if len(msg.payload) < 1 {
+		return ReceivedDMXPacket{}, fmt.Errorf("payload must be at least '%d' bytes, but was '%d'", 1, len(msg.payload))
+	}
+	if len(msg.payload) == 1 {
+		return ReceivedDMXPacket{Status: ReceiveStatus(msg.payload[0]), Data: []byte{}}, nil
+	}
+	return ReceivedDMXPacket{Status: ReceiveStatus(msg.payload[0]), HasStartCode: true, StartCode: msg.payload[1], Data: msg.payload[2:]}, nil
+}
+
 /*
 	Convert a message according to the 'Received DMX Packet' structure.
 
@@ -48,8 +135,39 @@ Message must have label '5' and at least 1 byte
 0    - DMX receive status Bit 0: 0=No error,1=Widget receive queue overflowed. Bit 1: 0=No error,1=Widget receive overrun occurred
 
 1 - 513  - Received DMX data beginning with the start code. Get Size from overall msg size.
+
+Packets with receive errors or a start code other than 0 are refused, use 'ToReceivedDMXPacket' to handle those.
 */
 func ToDMXArray(msg EnttecDMXUSBProApplicationMessage) ([]byte, error) {
+	packet, err := ToReceivedDMXPacket(msg)
+	if err != nil {
+		return nil, err
+	}
+	if !packet.Status.IsOK() {
+		return nil, fmt.Errorf("DMX receive status (payload[0]) should be '%d', but was '%d'", 0, packet.Status)
+	}
+	if !packet.HasStartCode {
+		return nil, fmt.Errorf("DMX packet has no start code")
+	}
+	if !packet.IsDMX() {
+		return nil, fmt.Errorf("DMX start byte (payload[1]) should be '%d', but was '%d'", DMX_START_CODE, packet.StartCode)
+	}
+	return msg.payload[1:], nil
+}
+
+// Start code of RDM packets, as opposed to '0' for DMX packets
+const RDM_START_CODE = 0xCC
+
+/*
+	Convert a message according to the 'Received DMX Packet' structure, containing an RDM packet.
+
+Message must have label '5' and at least 2 bytes
+
+0    - DMX receive status, see 'ToDMXArray'
+
+1 -  - Received RDM packet beginning with the start code '0xCC'.
+*/
+func ToRDMPacket(msg EnttecDMXUSBProApplicationMessage) ([]byte, error) {
 	if msg.label != LABEL_RECEIVED_DMX_PACKET {
 		return nil, fmt.Errorf("wrong label, expected '%d', but got '%d'", LABEL_RECEIVED_DMX_PACKET, msg.label)
 	}
@@ -59,12 +177,42 @@ func ToDMXArray(msg EnttecDMXUSBProApplicationMessage) ([]byte, error) {
 	if msg.payload[0] != 0 {
 		return nil, fmt.Errorf("DMX receive status (payload[0]) should be '%d', but was '%d'", 0, msg.payload[0])
 	}
-	if msg.payload[1] != 0 {
-		return nil, fmt.Errorf("DMX start byte (payload[1]) should be '%d', but was '%d'", 0, msg.payload[1])
+	if msg.payload[1] != RDM_START_CODE {
+		return nil, fmt.Errorf("RDM start byte (payload[1]) should be '%X', but was '%X'", RDM_START_CODE, msg.payload[1])
 	}
 	return msg.payload[1:], nil
 }
 
+const (
+	// Byte of the preamble an RDM discovery response begins with
+	RDM_DISCOVERY_PREAMBLE_BYTE = 0xFE
+	// Separates the preamble from the encoded UID of an RDM discovery response
+	RDM_DISCOVERY_PREAMBLE_SEPARATOR = 0xAA
+)
+
+/*
+	Convert a message according to the 'Received DMX Packet' structure, received after 'Send RDM Discovery Request'.
+
+Message must have label '5' and at least 1 byte
+
+0    - DMX receive status, usually an error if responses collided
+
+1 -  - Received RDM discovery response beginning with the preamble, empty if no responder answered.
+*/
+func ToRDMDiscoveryResponse(msg EnttecDMXUSBProApplicationMessage) ([]byte, error) {
+	if msg.label != LABEL_RECEIVED_DMX_PACKET {
+		return nil, fmt.Errorf("wrong label, expected '%d', but got '%d'", LABEL_RECEIVED_DMX_PACKET, msg.label)
+	}
+	if len(msg.payload) < 1 {
+		return nil, fmt.Errorf("payload must be at least '%d' bytes, but was '%d'", 1, len(msg.payload))
+	}
+	response := msg.payload[1:]
+	if len(response) > 0 && response[0] != RDM_DISCOVERY_PREAMBLE_BYTE && response[0] != RDM_DISCOVERY_PREAMBLE_SEPARATOR {
+		return nil, fmt.Errorf("RDM discovery response (payload[1]) should begin with '%X' or '%X', but was '%X'", RDM_DISCOVERY_PREAMBLE_BYTE, RDM_DISCOVERY_PREAMBLE_SEPARATOR, response[0])
+	}
+	return response, nil
+}
+
 // MSBs first
 func byteToBools(input byte) []bool {
 	out := make([]bool, 8)
@@ -84,3 +232,199 @@ func bytesToBools(input []byte) []bool {
 	}
 	return out
 }
+
+/*
+	Convert a message according to the 'Get Widget Serial Number Reply' structure.
+
+Message must have label '10' and 4 bytes
+
+0 - 3 - Widget serial number in BCD, least significant byte first
+
+Returns the serial number as printed on the widget case.
+*/
+func ToSerialNumber(msg EnttecDMXUSBProApplicationMessage) (uint32, error) {
+	if msg.label != LABEL_WIDGET_GET_SERIAL_NUMBER_REPLY {
+		return 0, fmt.Errorf("wrong label, expected '%d', but got '%d'", LABEL_WIDGET_GET_SERIAL_NUMBER_REPLY, msg.label)
+	}
+	if len(msg.payload) != 4 {
+		return 0, fmt.Errorf("payload must be '%d' bytes, but was '%d'", 4, len(msg.payload))
+	}
+	serialNumber := uint32(0)
+	for i := len(msg.payload) - 1; i >= 0; i-- {
+		high := msg.payload[i] >> 4
+		low := msg.payload[i] & 0x0F
+		if high > 9 || low > 9 {
+			return 0, fmt.Errorf("serial number byte[%d] '%X' is not BCD", i, msg.payload[i])
+		}
+		serialNumber = serialNumber*100 + uint32(high)*10 + uint32(low)
+	}
+	return serialNumber, nil
+}
+
+const (
+	// Duration of one unit of the break and Mark After Break times
+	WIDGET_TIME_UNIT_MICROSECONDS = 10.67
+	// Shortest DMX output break time in 10.67 microsecond units
+	MINIMUM_BREAK_TIME = 9
+	// Longest DMX output break time in 10.67 microsecond units
+	MAXIMUM_BREAK_TIME = 127
+	// Shortest DMX output Mark After Break time in 10.67 microsecond units
+	MINIMUM_MAB_TIME = 1
+	// Longest DMX output Mark After Break time in 10.67 microsecond units
+	MAXIMUM_MAB_TIME = 127
+	// Fastest DMX output rate in packets per second, 0 means as fast as possible
+	MAXIMUM_OUTPUT_RATE = 40
+	// Maximum number of user configuration bytes
+	MAXIMUM_USER_CONFIG_SIZE = 508
+)
+
+// Widget configuration as sent with the 'Get Widget Parameters Reply'
+type WidgetParameters struct {
+	// Firmware version, MSB is the major and LSB the minor version
+	FirmwareVersion uint16
+	// DMX output break time in 10.67 microsecond units
+	BreakTime byte
+	// DMX output Mark After Break time in 10.67 microsecond units
+	MABTime byte
+	// DMX output rate in packets per second, 0 means as fast as possible
+	OutputRate byte
+	// User defined configuration data
+	UserConfig []byte
+}
+
+// Returns the DMX output break time in microseconds
+func (p WidgetParameters) GetBreakTimeMicroseconds() float64 {
+	return float64(p.BreakTime) * WIDGET_TIME_UNIT_MICROSECONDS
+}
+
+// Returns the DMX output Mark After Break time in microseconds
+func (p WidgetParameters) GetMABTimeMicroseconds() float64 {
+	return float64(p.MABTime) * WIDGET_TIME_UNIT_MICROSECONDS
+}
+
+// Returns the DMX output rate in packets per second, 0 means as fast as possible
+func (p WidgetParameters) GetPacketsPerSecond() int {
+	return int(p.OutputRate)
+}
+
+// Returns the time between two DMX output packets, 0 means as fast as possible
+func (p WidgetParameters) GetOutputInterval() time.Duration {
+	if p.OutputRate == 0 {
+		return 0
+	}
+	return time.Second / time.Duration(p.OutputRate)
+}
+
+// Returns the firmware version as "major.minor"
+func (p WidgetParameters) GetFirmwareVersionString() string {
+	return fmt.Sprintf("%d.%d", p.FirmwareVersion>>8, p.FirmwareVersion&0xFF)
+}
+
+/*
+	Convert a message according to the 'Get Widget Parameters Reply' structure.
+
+Message must have label '3' and at least 5 bytes
+
+0 - Firmware version LSB
+
+1 - Firmware version MSB
+
+2 - DMX output break time in 10.67 microsecond units. Valid range is 9 to 127.
+
+3 - DMX output Mark After Break time in 10.67 microsecond units. Valid range is 1 to 127.
+
+4 - DMX output rate in packets per second. Valid range is 0 to 40, 0 sends as fast as possible.
+
+5 - ... - User defined configuration data
+*/
+func ToWidgetParameters(msg EnttecDMXUSBProApplicationMessage) (WidgetParameters, error) {
+	if msg.label != LABEL_GET_WIDGET_PARAMS_REPLY {
+		return WidgetParameters{}, fmt.Errorf("wrong label, expected '%d', but got '%d'", LABEL_GET_WIDGET_PARAMS_REPLY, msg.label)
+	}
+	if len(msg.payload) < 5 {
+		return WidgetParameters{}, fmt.Errorf("payload must be at least '%d' bytes, but was '%d'", 5, len(msg.payload))
+	}
+	return WidgetParameters{
+		FirmwareVersion: uint16(msg.payload[0]) + 256*uint16(msg.payload[1]),
+		BreakTime:       msg.payload[2],
+		MABTime:         msg.payload[3],
+		OutputRate:      msg.payload[4],
+		UserConfig:      msg.payload[5:],
+	}, nil
+}
+
+// Check the parameters are within the ranges accepted by the widget, the firmware version is ignored
+func (p WidgetParameters) Validate() error {
+	if p.BreakTime < MINIMUM_BREAK_TIME || p.BreakTime > MAXIMUM_BREAK_TIME {
+		return fmt.Errorf("break time %d out of range, must be between %d and %d", p.BreakTime, MINIMUM_BREAK_TIME, MAXIMUM_BREAK_TIME)
+	}
+	if p.MABTime < MINIMUM_MAB_TIME || p.MABTime > MAXIMUM_MAB_TIME {
+		return fmt.Errorf("MAB time %d out of range, must be between %d and %d", p.MABTime, MINIMUM_MAB_TIME, MAXIMUM_MAB_TIME)
+	}
+	if p.OutputRate > MAXIMUM_OUTPUT_RATE {
+		return fmt.Errorf("output rate %d out of range, must be between 0 and %d", p.OutputRate, MAXIMUM_OUTPUT_RATE)
+	}
+	if len(p.UserConfig) > MAXIMUM_USER_CONFIG_SIZE {
+		return fmt.Errorf("user config of %d bytes too big, must be at most %d bytes", len(p.UserConfig), MAXIMUM_USER_CONFIG_SIZE)
+	}
+	return nil
+}
+
+/*
+	Create a message according to the 'Set Widget Parameters Request' structure.
+
+Message has label '4'
+
+0 - User configuration size LSB
+
+1 - User configuration size MSB
+
+2 - DMX output break time in 10.67 microsecond units. Valid range is 9 to 127.
+
+3 - DMX output Mark After Break time in 10.67 microsecond units. Valid range is 1 to 127.
+
+4 - DMX output rate in packets per second. Valid range is 0 to 40.
+
+5 - ... - User configuration data
+*/
+func FromWidgetParameters(params WidgetParameters) (EnttecDMXUSBProApplicationMessage, error) {
+	if err := params.Validate(); err != nil {
+		return EnttecDMXUSBProApplicationMessage{}, err
+	}
+	size := len(params.UserConfig)
+	payload := make([]byte, 0, 5+size)
+	payload = append(payload, byte(size&0xFF), byte(size>>8&0xFF), params.BreakTime, params.MABTime, params.OutputRate)
+	payload = append(payload, params.UserConfig...)
+	return NewEnttecDMXUSBProApplicationMessage(LABEL_SET_WIDGET_PARAMS_REQUEST, payload), nil
+}
+
+const (
+	// Number of firmware bytes programmed with one 'Program Flash Page Request'
+	FLASH_PAGE_SIZE = 64
+	// Payload of the 'Program Flash Page Reply' on success
+	FLASH_PAGE_REPLY_SUCCESS = "TRUE"
+	// Payload of the 'Program Flash Page Reply' on failure
+	FLASH_PAGE_REPLY_FAILURE = "FALS"
+)
+
+/*
+	Convert a message according to the 'Program Flash Page Reply' structure.
+
+Message must have label '2' and 4 bytes
+
+0 - 3 - Success character array, "TRUE" or "FALS"
+
+Returns whether programming the page succeeded.
+*/
+func ToFlashPageResult(msg EnttecDMXUSBProApplicationMessage) (bool, error) {
+	if msg.label != LABEL_PROGRAM_FLASH_PAGE_REPLY {
+		return false, fmt.Errorf("wrong label, expected '%d', but got '%d'", LABEL_PROGRAM_FLASH_PAGE_REPLY, msg.label)
+	}
+	switch string(msg.payload) {
+	case FLASH_PAGE_REPLY_SUCCESS:
+		return true, nil
+	case FLASH_PAGE_REPLY_FAILURE:
+		return false, nil
+	}
+	return false, fmt.Errorf("payload must be '%s' or '%s', but was '%v'", FLASH_PAGE_REPLY_SUCCESS, FLASH_PAGE_REPLY_FAILURE, msg.payload)
+}
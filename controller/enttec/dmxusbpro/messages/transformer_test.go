@@ -1,7 +1,10 @@
 package messages
 
 import (
+	"bytes"
+	"math"
 	"testing"
+	"time"
 )
 
 func TestByteToBools(t *testing.T) {
@@ -134,3 +137,264 @@ func TestToChangeSetMultipleChannelsChanged(t *testing.T) {
 		t.Errorf("expected channel[%d] to be %d, but was %d", 14, 114, result[14])
 	}
 }
+
+// 1111 1111 => 255, for all 5 bytes
+func TestToChangeSetAllChannelsOfBlockChanged(t *testing.T) {
+	payload := []byte{2, 255, 255, 255, 255, 255}
+	for i := 0; i < 40; i++ {
+		payload = append(payload, byte(i+1))
+	}
+	input := EnttecDMXUSBProApplicationMessage{
+		label:   LABEL_RECEIVED_DMX_CHANGE_OF_STATE_PACKET,
+		payload: payload,
+	}
+	result, err := ToChangeSet(input)
+	if err != nil {
+		t.Errorf("expected no error, but got %v", err)
+	}
+	if len(result) != 40 {
+		t.Errorf("expected 40 channels to be changed, but were %d", len(result))
+	}
+	for i := 0; i < 40; i++ {
+		if result[16+i] != byte(i+1) {
+			t.Errorf("expected channel[%d] to be %d, but was %d", 16+i, i+1, result[16+i])
+		}
+	}
+}
+
+// 0000 0000 => 0
+// 0000 0000 => 0
+// 0000 0000 => 0
+// 0000 0000 => 0
+// 1000 0000 => 128
+func TestToChangeSetLastChannelOfBlockChanged(t *testing.T) {
+	input := EnttecDMXUSBProApplicationMessage{
+		label:   LABEL_RECEIVED_DMX_CHANGE_OF_STATE_PACKET,
+		payload: []byte{1, 0, 0, 0, 0, 128, 47},
+	}
+	result, err := ToChangeSet(input)
+	if err != nil {
+		t.Errorf("expected no error, but got %v", err)
+	}
+	if result[47] != 47 {
+		t.Errorf("expected channel[%d] to be %d, but was %d", 47, 47, result[47])
+	}
+}
+
+// 0000 0011 => 3, but only one changed byte
+func TestToChangeSetMissingDataError(t *testing.T) {
+	input := EnttecDMXUSBProApplicationMessage{
+		label:   LABEL_RECEIVED_DMX_CHANGE_OF_STATE_PACKET,
+		payload: []byte{0, 3, 0, 0, 0, 0, 69},
+	}
+	_, err := ToChangeSet(input)
+	if err == nil {
+		t.Errorf("expected error, because changed bit array announces 2 bytes, but only 1 is present")
+	}
+}
+
+func TestToSerialNumber(t *testing.T) {
+	input := EnttecDMXUSBProApplicationMessage{
+		label:   LABEL_WIDGET_GET_SERIAL_NUMBER_REPLY,
+		payload: []byte{0x78, 0x56, 0x34, 0x12},
+	}
+	result, err := ToSerialNumber(input)
+	if err != nil {
+		t.Errorf("expected no error, but got %v", err)
+	}
+	if result != 12345678 {
+		t.Errorf("expected serial number to be %d, but was %d", 12345678, result)
+	}
+}
+
+func TestToSerialNumberNotBCD(t *testing.T) {
+	input := EnttecDMXUSBProApplicationMessage{
+		label:   LABEL_WIDGET_GET_SERIAL_NUMBER_REPLY,
+		payload: []byte{0x0A, 0, 0, 0},
+	}
+	_, err := ToSerialNumber(input)
+	if err == nil {
+		t.Errorf("expected error, because 0x0A is no BCD digit")
+	}
+}
+
+func TestToWidgetParameters(t *testing.T) {
+	input := EnttecDMXUSBProApplicationMessage{
+		label:   LABEL_GET_WIDGET_PARAMS_REPLY,
+		payload: []byte{0x44, 0x01, 9, 1, 40, 69},
+	}
+	result, err := ToWidgetParameters(input)
+	if err != nil {
+		t.Errorf("expected no error, but got %v", err)
+	}
+	if result.FirmwareVersion != 0x0144 {
+		t.Errorf("expected firmware version to be %X, but was %X", 0x0144, result.FirmwareVersion)
+	}
+	if result.BreakTime != 9 || result.MABTime != 1 || result.OutputRate != 40 {
+		t.Errorf("expected break, MAB and rate to be 9, 1 and 40, but were %d, %d and %d", result.BreakTime, result.MABTime, result.OutputRate)
+	}
+	if len(result.UserConfig) != 1 || result.UserConfig[0] != 69 {
+		t.Errorf("expected user config to be [69], but was %v", result.UserConfig)
+	}
+}
+
+func TestWidgetParametersConversions(t *testing.T) {
+	params := WidgetParameters{FirmwareVersion: 1<<8 | 44, BreakTime: 9, MABTime: 1, OutputRate: 40}
+	if us := params.GetBreakTimeMicroseconds(); math.Abs(us-96.03) > 0.001 {
+		t.Errorf("expected break time to be %v, but was %v", 96.03, us)
+	}
+	if us := params.GetMABTimeMicroseconds(); math.Abs(us-10.67) > 0.001 {
+		t.Errorf("expected MAB time to be %v, but was %v", 10.67, us)
+	}
+	if pps := params.GetPacketsPerSecond(); pps != 40 {
+		t.Errorf("expected packets per second to be %d, but was %d", 40, pps)
+	}
+	if interval := params.GetOutputInterval(); interval != 25*time.Millisecond {
+		t.Errorf("expected output interval to be %v, but was %v", 25*time.Millisecond, interval)
+	}
+	if version := params.GetFirmwareVersionString(); version != "1.44" {
+		t.Errorf("expected firmware version to be '1.44', but was '%s'", version)
+	}
+}
+
+func TestFromWidgetParameters(t *testing.T) {
+	params := WidgetParameters{FirmwareVersion: 0xFFFF, BreakTime: 20, MABTime: 5, OutputRate: 30, UserConfig: []byte{69, 96}}
+	msg, err := FromWidgetParameters(params)
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if msg.GetLabel() != LABEL_SET_WIDGET_PARAMS_REQUEST {
+		t.Errorf("expected label to be %d, but was %d", LABEL_SET_WIDGET_PARAMS_REQUEST, msg.GetLabel())
+	}
+	expected := []byte{2, 0, 20, 5, 30, 69, 96}
+	if !bytes.Equal(msg.GetPayload(), expected) {
+		t.Errorf("expected payload to be %v, but was %v", expected, msg.GetPayload())
+	}
+}
+
+func TestFromWidgetParametersValidates(t *testing.T) {
+	valid := WidgetParameters{BreakTime: 9, MABTime: 1, OutputRate: 0}
+	invalid := map[string]func(p *WidgetParameters){
+		"break too short":  func(p *WidgetParameters) { p.BreakTime = 8 },
+		"break too long":   func(p *WidgetParameters) { p.BreakTime = 128 },
+		"MAB too short":    func(p *WidgetParameters) { p.MABTime = 0 },
+		"MAB too long":     func(p *WidgetParameters) { p.MABTime = 128 },
+		"rate too fast":    func(p *WidgetParameters) { p.OutputRate = 41 },
+		"user config size": func(p *WidgetParameters) { p.UserConfig = make([]byte, 509) },
+	}
+	if _, err := FromWidgetParameters(valid); err != nil {
+		t.Errorf("expected no error, but got %v", err)
+	}
+	for name, change := range invalid {
+		params := valid
+		change(&params)
+		if _, err := FromWidgetParameters(params); err == nil {
+			t.Errorf("expected an error for '%s'", name)
+		}
+	}
+}
+
+func TestToFlashPageResult(t *testing.T) {
+	for payload, expected := range map[string]bool{"TRUE": true, "FALS": false} {
+		input := EnttecDMXUSBProApplicationMessage{label: LABEL_PROGRAM_FLASH_PAGE_REPLY, payload: []byte(payload)}
+		result, err := ToFlashPageResult(input)
+		if err != nil {
+			t.Errorf("expected no error for '%s', but got %v", payload, err)
+		}
+		if result != expected {
+			t.Errorf("expected result for '%s' to be %v, but was %v", payload, expected, result)
+		}
+	}
+	if _, err := ToFlashPageResult(EnttecDMXUSBProApplicationMessage{label: LABEL_PROGRAM_FLASH_PAGE_REPLY, payload: []byte("MAYB")}); err == nil {
+		t.Errorf("expected an error for an unknown reply")
+	}
+}
+
+func TestToRDMPacket(t *testing.T) {
+	input := EnttecDMXUSBProApplicationMessage{label: LABEL_RECEIVED_DMX_PACKET, payload: []byte{0, 0xCC, 0x01, 24}}
+	result, err := ToRDMPacket(input)
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if !bytes.Equal(result, []byte{0xCC, 0x01, 24}) {
+		t.Errorf("expected packet to be %X, but was %X", []byte{0xCC, 0x01, 24}, result)
+	}
+	dmx := EnttecDMXUSBProApplicationMessage{label: LABEL_RECEIVED_DMX_PACKET, payload: []byte{0, 0, 69}}
+	if _, err := ToRDMPacket(dmx); err == nil {
+		t.Errorf("expected an error for a DMX packet")
+	}
+}
+
+func TestToRDMDiscoveryResponse(t *testing.T) {
+	input := EnttecDMXUSBProApplicationMessage{label: LABEL_RECEIVED_DMX_PACKET, payload: []byte{0, 0xFE, 0xFE, 0xAA}}
+	result, err := ToRDMDiscoveryResponse(input)
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if !bytes.Equal(result, []byte{0xFE, 0xFE, 0xAA}) {
+		t.Errorf("expected response to be %X, but was %X", []byte{0xFE, 0xFE, 0xAA}, result)
+	}
+	empty := EnttecDMXUSBProApplicationMessage{label: LABEL_RECEIVED_DMX_PACKET, payload: []byte{0}}
+	if result, err := ToRDMDiscoveryResponse(empty); err != nil || len(result) != 0 {
+		t.Errorf("expected an empty response, but got %X and %v", result, err)
+	}
+	dmx := EnttecDMXUSBProApplicationMessage{label: LABEL_RECEIVED_DMX_PACKET, payload: []byte{0, 0, 69}}
+	if _, err := ToRDMDiscoveryResponse(dmx); err == nil {
+		t.Errorf("expected an error for a DMX packet")
+	}
+}
+
+func TestToReceivedDMXPacket(t *testing.T) {
+	input := EnttecDMXUSBProApplicationMessage{label: LABEL_RECEIVED_DMX_PACKET, payload: []byte{RECEIVE_STATUS_OVERRUN, TEXT_PACKET_START_CODE, 'h', 'i'}}
+	packet, err := ToReceivedDMXPacket(input)
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if !packet.Status.IsOverrun() || packet.Status.IsQueueOverflow() || packet.Status.IsOK() {
+		t.Errorf("expected only the overrun flag, but status was %08b", packet.Status)
+	}
+	if packet.StartCode != TEXT_PACKET_START_CODE || packet.IsDMX() || packet.IsRDM() {
+		t.Errorf("expected start code %X, but was %X", TEXT_PACKET_START_CODE, packet.StartCode)
+	}
+	if !bytes.Equal(packet.Data, []byte("hi")) {
+		t.Errorf("expected data to be %v, but was %v", []byte("hi"), packet.Data)
+	}
+	overflow := EnttecDMXUSBProApplicationMessage{label: LABEL_RECEIVED_DMX_PACKET, payload: []byte{RECEIVE_STATUS_QUEUE_OVERFLOW, RDM_START_CODE}}
+	packet, _ = ToReceivedDMXPacket(overflow)
+	if !packet.Status.IsQueueOverflow() || !packet.IsRDM() || len(packet.Data) != 0 {
+		t.Errorf("expected an empty RDM packet after a queue overflow, but got %+v", packet)
+	}
+	if _, err := ToReceivedDMXPacket(EnttecDMXUSBProApplicationMessage{label: LABEL_RECEIVED_DMX_PACKET, payload: []byte{}}); err == nil {
+		t.Errorf("expected an error for a packet without status")
+	}
+}
+
+func TestToReceivedDMXPacketStatusOnly(t *testing.T) {
+	input := EnttecDMXUSBProApplicationMessage{label: LABEL_RECEIVED_DMX_PACKET, payload: []byte{RECEIVE_STATUS_QUEUE_OVERFLOW}}
+	packet, err := ToReceivedDMXPacket(input)
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if !packet.Status.IsQueueOverflow() || packet.HasStartCode || packet.IsDMX() || packet.IsRDM() || len(packet.Data) != 0 {
+		t.Errorf("expected only a queue overflow status, but got %+v", packet)
+	}
+	if _, err := ToDMXArray(EnttecDMXUSBProApplicationMessage{label: LABEL_RECEIVED_DMX_PACKET, payload: []byte{0}}); err == nil {
+		t.Errorf("expected an error converting a packet without start code to DMX")
+	}
+}
+
+func TestToDMXArray(t *testing.T) {
+	input := EnttecDMXUSBProApplicationMessage{label: LABEL_RECEIVED_DMX_PACKET, payload: []byte{0, 0, 69, 96}}
+	result, err := ToDMXArray(input)
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if !bytes.Equal(result, []byte{0, 69, 96}) {
+		t.Errorf("expected array to be %v, but was %v", []byte{0, 69, 96}, result)
+	}
+	for _, payload := range [][]byte{{RECEIVE_STATUS_OVERRUN, 0, 69}, {0, SYSTEM_INFORMATION_PACKET_START_CODE, 69}} {
+		if _, err := ToDMXArray(EnttecDMXUSBProApplicationMessage{label: LABEL_RECEIVED_DMX_PACKET, payload: payload}); err == nil {
+			t.Errorf("expected an error for payload %v", payload)
+		}
+	}
+}
@@ -0,0 +1,311 @@
+package dmxusbpro
+
+import (
+	"github.com/H3rby7/usbdmx-golang/controller/enttec/dmxusbpro/messages"
+	"github.com/tarm/serial"
+)
+
+// Number of DMX ports of the Enttec DMX USB Pro Mk2
+const MK2_PORT_COUNT = 2
+
+/*
+A DMX port of a widget, staging and committing or receiving one universe.
+
+Implemented by 'EnttecDMXUSBProController', see 'EnttecDMXUSBProMk2Controller.GetPort' for the ports of a Mk2.
+*/
+type DMXPort interface {
+	// Prepare a channel to be changed to the given value
+	Stage(channel int16, value byte) error
+	// Prepare consecutive channels to be changed to the given values at once
+	StageRange(channel int16, values []byte) error
+	// Gets a copy of all staged channel values
+	GetStage() []byte
+	// Set all values of the staged channels to '0'
+	ClearStage()
+	// Apply the 'staged' values to go live
+	Commit() error
+	// Change the receive mode of the port
+	SwitchReadMode(changesOnly byte) error
+	// Send the stage at a fixed rate, whenever it changed
+	StartRefresh(conf RefreshConfig) error
+	// Stop sending the stage
+	StopRefresh()
+	// Returns the counts of the refresh
+	GetRefreshStatistics() RefreshStatistics
+	// Let 'Commit' return right away, while a routine writes the frames
+	EnableAsyncCommit()
+	// Let 'Commit' wait for the frame to be written again
+	DisableAsyncCommit()
+	// Returns the counts of the asynchronous commits
+	GetCommitStatistics() CommitStatistics
+}
+
+// Configuration of the Mk2 API
+type Mk2Config struct {
+	// Key activating the Mk2 API, as distributed by Enttec
+	APIKey uint32
+	// Labels distributed along with the API key
+	Labels messages.Mk2Labels
+	// Does port 1 send DMX (as opposed to receive)
+	Port1IsWriter bool
+	// Does port 2 send DMX (as opposed to receive)
+	Port2IsWriter bool
+}
+
+/*
+Controller for the Enttec DMX USB Pro Mk2, exposing its two DMX ports as independent universes.
+
+The embedded controller handles port 1 and everything shared by the ports, e.g. RDM, the serial number and reconnecting.
+Connecting activates the Mk2 API and assigns both ports, this is repeated after reconnecting.
+
+Messages received on port 2 arrive with the port 2 labels, see 'ToPortMessage'.
+
+Example useage:
+
+	controller := dmxusbpro.NewEnttecDMXUSBProMk2Controller(&serial.Config{Name: "/dev/ttyUSB0", Baud: 57600}, mk2Config, 512)
+	controller.Connect()
+	controller.GetPort(2).Stage(1, 255)
+	controller.GetPort(2).Commit()
+*/
+type EnttecDMXUSBProMk2Controller struct {
+	*EnttecDMXUSBProController
+	conf  Mk2Config
+	port2 *mk2Port
+}
+
+// Second DMX port of the Mk2, like 'EnttecDMXUSBProController' using the port 2 labels
+type mk2Port struct {
+	d *EnttecDMXUSBProController
+	// Holds DMX data, staged and committed, safe for concurrent use
+	frames   *frameBuffer
+	refresh  *refresher
+	async    *asyncWriter
+	isWriter bool
+	labels   messages.Mk2Labels
+
+	// Guarded by 'connMu' of the controller, restored after reconnecting
+	readMode      byte
+	hasReadMode   bool
+	lastCommitted []byte
+}
+
+// Helper function for creating a new DMX USB PRO Mk2 controller using a serial port
+func NewEnttecDMXUSBProMk2Controller(conf *serial.Config, mk2 Mk2Config, dmxChannelCount int) *EnttecDMXUSBProMk2Controller {
+	return NewEnttecDMXUSBProMk2ControllerWithTransport(NewSerialOpener(conf), mk2, dmxChannelCount)
+}
+
+/*
+Helper function for creating a new DMX USB PRO Mk2 controller using any Transport.
+
+Panics if the labels are invalid, see 'messages.Mk2Labels.Validate'.
+*/
+func NewEnttecDMXUSBProMk2ControllerWithTransport(opener TransportOpener, mk2 Mk2Config, dmxChannelCount int) *EnttecDMXUSBProMk2Controller {
+	d := NewEnttecDMXUSBProControllerWithTransport(opener, dmxChannelCount, mk2.Port1IsWriter)
+	if err := mk2.Labels.Validate(); err != nil {
+		d.panicf("%v", err)
+	}
+	d.labels = mk2.Labels.ToLabelSet()
+	d.otherPortReads = !mk2.Port2IsWriter
+	m := &EnttecDMXUSBProMk2Controller{EnttecDMXUSBProController: d, conf: mk2}
+	m.port2 = &mk2Port{
+		d:        d,
+		frames:   newFrameBuffer(dmxChannelCount),
+		refresh:  &refresher{},
+		async:    &asyncWriter{},
+		isWriter: mk2.Port2IsWriter,
+		labels:   mk2.Labels,
+	}
+	d.haltOnDisconnect = append(d.haltOnDisconnect, m.port2.refresh.halt, m.port2.async.halt)
+	d.restoreFirst = m.restore
+	return m
+}
+
+/*
+	Open the connection to the widget, activate the Mk2 API and assign both ports
+
+Succeeded if no error is returned
+*/
+func (m *EnttecDMXUSBProMk2Controller) Connect() error {
+	if err := m.EnttecDMXUSBProController.Connect(); err != nil {
+		return err
+	}
+	return m.activate()
+}
+
+// Send the API key and the port assignment
+func (m *EnttecDMXUSBProMk2Controller) activate() error {
+	labels := m.conf.Labels
+	key := messages.NewEnttecDMXUSBProApplicationMessageWithLabels(m.labels, labels.SetAPIKey, messages.NewMk2APIKeyPayload(m.conf.APIKey))
+	if err := m.writeMessage(key); err != nil {
+		return err
+	}
+	assignment := messages.NewEnttecDMXUSBProApplicationMessageWithLabels(m.labels, labels.SetPortAssignment, []byte{messages.MK2_PORT_DMX, messages.MK2_PORT_DMX})
+	return m.writeMessage(assignment)
+}
+
+// Activate the Mk2 API again and restore port 2, port 1 is restored by the embedded controller
+func (m *EnttecDMXUSBProMk2Controller) restore() {
+	if err := m.activate(); err != nil {
+		m.printf(1, "Could not activate the Mk2 API: %v", err)
+		return
+	}
+	m.port2.restore()
+}
+
+// Returns the port, 1 or 2
+func (m *EnttecDMXUSBProMk2Controller) GetPort(port int) DMXPort {
+	switch port {
+	case 1:
+		return m.EnttecDMXUSBProController
+	case 2:
+		return m.port2
+	}
+	m.panicf("invalid port, only 1 and 2 are allowed, but got '%d'", port)
+	return nil
+}
+
+/*
+Returns the port the received message belongs to, and the message using the standard label.
+
+Messages of port 2 can thereby be handled like the ones of port 1, e.g. by 'messages.ToDMXArray' or 'Universe.Apply'.
+
+Example useage:
+
+	for msg := range c {
+		port, msg := controller.ToPortMessage(msg)
+		universes[port].Apply(msg)
+	}
+*/
+func (m *EnttecDMXUSBProMk2Controller) ToPortMessage(msg messages.EnttecDMXUSBProApplicationMessage) (int, messages.EnttecDMXUSBProApplicationMessage) {
+	switch msg.GetLabel() {
+	case m.conf.Labels.ReceivedDMXPort2:
+		return 2, messages.NewEnttecDMXUSBProApplicationMessage(messages.LABEL_RECEIVED_DMX_PACKET, msg.GetPayload())
+	case m.conf.Labels.ReceivedDMXChangeOfStatePort2:
+		return 2, messages.NewEnttecDMXUSBProApplicationMessage(messages.LABEL_RECEIVED_DMX_CHANGE_OF_STATE_PACKET, msg.GetPayload())
+	}
+	return 1, msg
+}
+
+// Prepare a channel of port 2 to be changed to the given value, see 'EnttecDMXUSBProController.Stage'
+func (p *mk2Port) Stage(channel int16, value byte) error {
+	return p.StageRange(channel, []byte{value})
+}
+
+// Prepare consecutive channels of port 2 to be changed to the given values, see 'EnttecDMXUSBProController.StageRange'
+func (p *mk2Port) StageRange(channel int16, values []byte) error {
+	if !p.isWriter {
+		return p.d.errorf("port 2 is not in WRITE mode")
+	}
+	return p.frames.stage(channel, values)
+}
+
+// Gets a copy of all staged channel values of port 2
+func (p *mk2Port) GetStage() []byte {
+	return p.frames.getStaged()
+}
+
+// Set all values of the staged channels of port 2 to '0'
+func (p *mk2Port) ClearStage() {
+	p.frames.clear()
+}
+
+// Apply the 'staged' values of port 2 to go live, see 'EnttecDMXUSBProController.Commit'
+func (p *mk2Port) Commit() error {
+	if !p.isWriter {
+		return p.d.errorf("port 2 is not in WRITE mode")
+	}
+	return p.frames.commit(p.commitFrame)
+}
+
+// Send a committed frame of port 2, see 'EnttecDMXUSBProController.commitFrame'
+func (p *mk2Port) commitFrame(frame []byte) error {
+	return p.async.commit(frame, p.sendFrame)
+}
+
+// Send a committed frame of port 2, remembering it for reconnecting
+func (p *mk2Port) sendFrame(frame []byte) error {
+	p.d.connMu.Lock()
+	p.lastCommitted = append(p.lastCommitted[:0], frame...)
+	p.d.connMu.Unlock()
+	msg := messages.NewEnttecDMXUSBProApplicationMessageWithLabels(p.d.labels, p.labels.SendDMXPort2, frame)
+	return p.d.writeMessage(msg)
+}
+
+// Send the stage of port 2 at a fixed rate, see 'EnttecDMXUSBProController.StartRefresh'
+func (p *mk2Port) StartRefresh(conf RefreshConfig) error {
+	if !p.isWriter {
+		return p.d.errorf("port 2 is not in WRITE mode")
+	}
+	return p.refresh.start(conf, p.frames, p.commitFrame)
+}
+
+// Stop sending the stage of port 2
+func (p *mk2Port) StopRefresh() {
+	p.refresh.halt()
+}
+
+// Returns the counts of the refresh of port 2
+func (p *mk2Port) GetRefreshStatistics() RefreshStatistics {
+	return p.refresh.getStatistics()
+}
+
+// Let 'Commit' of port 2 return right away, see 'EnttecDMXUSBProController.EnableAsyncCommit'
+func (p *mk2Port) EnableAsyncCommit() {
+	p.async.start(p.sendFrame, p.frames.markDirty)
+}
+
+// Let 'Commit' of port 2 wait for the frame to be written again
+func (p *mk2Port) DisableAsyncCommit() {
+	p.async.halt()
+}
+
+// Returns the counts of the asynchronous commits of port 2
+func (p *mk2Port) GetCommitStatistics() CommitStatistics {
+	return p.async.getStatistics()
+}
+
+// Change the receive mode of port 2, see 'EnttecDMXUSBProController.SwitchReadMode'
+func (p *mk2Port) SwitchReadMode(changesOnly byte) error {
+	if changesOnly > 1 {
+		p.d.panicf("invalid value, only 0 and 1 are allowed, but got '%d'", changesOnly)
+	}
+	if p.isWriter {
+		return p.d.errorf("port 2 is not in READ mode")
+	}
+	msg := messages.NewEnttecDMXUSBProApplicationMessageWithLabels(p.d.labels, p.labels.ReceiveDMXOnChangePort2, []byte{changesOnly})
+	p.d.connMu.Lock()
+	p.readMode = changesOnly
+	p.hasReadMode = true
+	p.d.connMu.Unlock()
+	if err := p.d.writeMessage(msg); err != nil {
+		return err
+	}
+	p.d.connMu.Lock()
+	p.d.readOnChange = true
+	p.d.connMu.Unlock()
+	return nil
+}
+
+// Send the last read mode and the last committed frame of port 2 again
+func (p *mk2Port) restore() {
+	p.d.connMu.Lock()
+	hasReadMode := p.hasReadMode
+	readMode := p.readMode
+	var lastCommitted []byte
+	if p.lastCommitted != nil {
+		lastCommitted = append([]byte{}, p.lastCommitted...)
+	}
+	p.d.connMu.Unlock()
+	if hasReadMode {
+		msg := messages.NewEnttecDMXUSBProApplicationMessageWithLabels(p.d.labels, p.labels.ReceiveDMXOnChangePort2, []byte{readMode})
+		if err := p.d.writeMessage(msg); err != nil {
+			p.d.printf(1, "Could not restore read mode of port 2: %v", err)
+		}
+	}
+	if lastCommitted != nil {
+		msg := messages.NewEnttecDMXUSBProApplicationMessageWithLabels(p.d.labels, p.labels.SendDMXPort2, lastCommitted)
+		if err := p.d.writeMessage(msg); err != nil {
+			p.d.printf(1, "Could not restore output of port 2: %v", err)
+		}
+	}
+}
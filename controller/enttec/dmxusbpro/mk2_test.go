@@ -0,0 +1,196 @@
+package dmxusbpro
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/H3rby7/usbdmx-golang/controller/enttec/dmxusbpro/emulator"
+	"github.com/H3rby7/usbdmx-golang/controller/enttec/dmxusbpro/messages"
+)
+
+func testMk2Config(port1IsWriter bool, port2IsWriter bool) Mk2Config {
+	conf := emulator.DefaultMk2Config()
+	return Mk2Config{APIKey: conf.APIKey, Labels: conf.Mk2Labels, Port1IsWriter: port1IsWriter, Port2IsWriter: port2IsWriter}
+}
+
+// Connect a Mk2 controller with 3 channels per port to the emulated widget
+func newMk2Controller(t *testing.T, widget *emulator.Widget, conf Mk2Config) (*EnttecDMXUSBProMk2Controller, *recordingWriter) {
+	host, device := emulator.NewPipe()
+	go widget.Serve(device)
+	writes := &recordingWriter{w: host, labels: conf.Labels.ToLabelSet()}
+	opener := NewTransportOpener("fake", func() (Transport, error) {
+		return &fakeTransport{r: host, w: writes}, nil
+	})
+	m := NewEnttecDMXUSBProMk2ControllerWithTransport(opener, conf, 3)
+	if err := m.Connect(); err != nil {
+		t.Fatalf("expected no error on connect, but got %v", err)
+	}
+	t.Cleanup(func() { m.Disconnect() })
+	return m, writes
+}
+
+// Wait for the universe to hold the value, or fail
+func waitForChannel(t *testing.T, u *Universe, channel int, value byte) {
+	for start := time.Now(); ; time.Sleep(time.Millisecond) {
+		if actual, _ := u.Get(channel); actual == value {
+			return
+		}
+		if time.Since(start) > time.Second {
+			actual, _ := u.Get(channel)
+			t.Fatalf("expected channel %d to be %d, but was %d", channel, value, actual)
+		}
+	}
+}
+
+func TestMk2ConnectActivatesAPI(t *testing.T) {
+	widget := emulator.NewWidget(emulator.DefaultMk2Config())
+	conf := testMk2Config(true, true)
+	m, writes := newMk2Controller(t, widget, conf)
+	// Replies once serving, having handled the activation
+	if _, err := m.GetSerialNumber(); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if !widget.IsMk2Activated() {
+		t.Errorf("expected the Mk2 API to be activated")
+	}
+	msgs := writes.getMessages()
+	if len(msgs) < 2 {
+		t.Fatalf("expected at least 2 messages, but got %d", len(msgs))
+	}
+	if msgs[0].GetLabel() != conf.Labels.SetAPIKey {
+		t.Errorf("expected first label to be %d, but was %d", conf.Labels.SetAPIKey, msgs[0].GetLabel())
+	}
+	if key, _ := messages.ToMk2APIKey(msgs[0]); key != conf.APIKey {
+		t.Errorf("expected API key to be %X, but was %X", conf.APIKey, key)
+	}
+	if msgs[1].GetLabel() != conf.Labels.SetPortAssignment {
+		t.Errorf("expected second label to be %d, but was %d", conf.Labels.SetPortAssignment, msgs[1].GetLabel())
+	}
+}
+
+func TestMk2IndependentUniverses(t *testing.T) {
+	mk2Widget := emulator.NewWidget(emulator.DefaultMk2Config())
+	port1Widget := emulator.NewWidget(emulator.DefaultConfig())
+	port2Widget := emulator.NewWidget(emulator.DefaultConfig())
+	emulator.Link(mk2Widget, port1Widget)
+	emulator.LinkPort2(mk2Widget, port2Widget)
+
+	// Port 1 sends to 'port1Widget', port 2 receives from 'port2Widget'
+	m, _ := newMk2Controller(t, mk2Widget, testMk2Config(true, false))
+	host, device := emulator.NewPipe()
+	go port1Widget.Serve(device)
+	reader := newFakeController(t, host, false)
+	t.Cleanup(func() { reader.Disconnect() })
+	host, device = emulator.NewPipe()
+	go port2Widget.Serve(device)
+	writer := newFakeController(t, host, true)
+	t.Cleanup(func() { writer.Disconnect() })
+
+	if err := m.GetPort(2).SwitchReadMode(1); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	reader.SwitchReadMode(1)
+	// Replies once serving, having switched the read mode
+	for _, d := range []*EnttecDMXUSBProController{m.EnttecDMXUSBProController, reader} {
+		if _, err := d.GetSerialNumber(); err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	universes := map[int]*Universe{1: NewUniverse(), 2: NewUniverse()}
+	c := make(chan messages.EnttecDMXUSBProApplicationMessage, 8)
+	go m.OnDMXChangeContext(ctx, c, nil)
+	go func() {
+		for msg := range c {
+			port, msg := m.ToPortMessage(msg)
+			universes[port].Apply(msg)
+		}
+	}()
+	received := NewUniverse()
+	readerC := make(chan messages.EnttecDMXUSBProApplicationMessage, 8)
+	go reader.OnDMXChangeContext(ctx, readerC, nil)
+	go func() {
+		for msg := range readerC {
+			received.Apply(msg)
+		}
+	}()
+
+	m.GetPort(1).Stage(1, 11)
+	if err := m.GetPort(1).Commit(); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	writer.Stage(2, 22)
+	writer.Commit()
+	waitForChannel(t, received, 1, 11)
+	waitForChannel(t, universes[2], 2, 22)
+	if value, _ := received.Get(2); value != 0 {
+		t.Errorf("expected port 2 input not to reach port 1 output, but channel 2 was %d", value)
+	}
+	if value, _ := universes[2].Get(1); value != 0 {
+		t.Errorf("expected port 1 output not to reach port 2 input, but channel 1 was %d", value)
+	}
+	if value, _ := universes[1].Get(2); value != 0 {
+		t.Errorf("expected nothing received on port 1, but channel 2 was %d", value)
+	}
+}
+
+func TestMk2PortModes(t *testing.T) {
+	m, _ := newMk2Controller(t, emulator.NewWidget(emulator.DefaultMk2Config()), testMk2Config(true, false))
+	if err := m.GetPort(2).Stage(1, 1); err == nil {
+		t.Errorf("expected error staging on port 2, as it reads")
+	}
+	if err := m.GetPort(1).SwitchReadMode(1); err == nil {
+		t.Errorf("expected error switching the read mode of port 1, as it writes")
+	}
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected a panic for port 3")
+		}
+	}()
+	m.GetPort(3)
+}
+
+func TestMk2InvalidLabelsPanic(t *testing.T) {
+	conf := testMk2Config(true, true)
+	conf.Labels.SendDMXPort2 = messages.LABEL_OUTPUT_ONLY_SEND_DMX_PACKET_REQUEST
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected a panic for labels colliding with standard labels")
+		}
+	}()
+	NewEnttecDMXUSBProMk2ControllerWithTransport(NewTransportOpener("fake", nil), conf, 3)
+}
+
+// Wait until the refresh of the port sent the given number of frames
+func waitForRefreshedFrames(t *testing.T, port DMXPort, count uint64) {
+	for start := time.Now(); port.GetRefreshStatistics().Frames < count; time.Sleep(time.Millisecond) {
+		if time.Since(start) > time.Second {
+			t.Fatalf("expected %d frames to be sent, but got %d", count, port.GetRefreshStatistics().Frames)
+		}
+	}
+}
+
+func TestMk2RefreshPerPort(t *testing.T) {
+	conf := testMk2Config(true, true)
+	m, writes := newMk2Controller(t, emulator.NewWidget(emulator.DefaultMk2Config()), conf)
+	if err := m.GetPort(2).StartRefresh(RefreshConfig{Rate: MAXIMUM_REFRESH_RATE}); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	waitForRefreshedFrames(t, m.GetPort(2), 1)
+	m.GetPort(2).Stage(2, 22)
+	waitForRefreshedFrames(t, m.GetPort(2), 2)
+	if m.IsRefreshing() {
+		t.Errorf("expected port 1 not to refresh")
+	}
+	m.Disconnect()
+	for _, msg := range writes.getMessages() {
+		if msg.GetLabel() == messages.LABEL_OUTPUT_ONLY_SEND_DMX_PACKET_REQUEST {
+			t.Errorf("expected only port 2 to send frames, but port 1 sent %v", msg.GetPayload())
+		}
+	}
+	if frames := m.GetPort(2).GetRefreshStatistics().Frames; frames < 2 {
+		t.Errorf("expected port 2 to send at least 2 frames, but sent %d", frames)
+	}
+}
@@ -0,0 +1,207 @@
+package dmxusbpro
+
+import (
+	"github.com/H3rby7/usbdmx-golang/controller/enttec/dmxusbpro/messages"
+	"github.com/H3rby7/usbdmx-golang/rdm"
+)
+
+// ESTA manufacturer ID of Enttec, used for the controller's RDM UID
+const ENTTEC_ESTA_MANUFACTURER_ID = 0x454E
+
+// Set the UID the controller uses as source of RDM requests
+func (d *EnttecDMXUSBProController) SetRDMSourceUID(uid rdm.UID) {
+	d.rdmMu.Lock()
+	defer d.rdmMu.Unlock()
+	d.rdmSourceUID = uid
+	d.hasRDMSourceUID = true
+}
+
+/*
+Returns the UID the controller uses as source of RDM requests.
+
+Unless set with 'SetRDMSourceUID', it is made of the Enttec manufacturer ID and the widget serial number.
+*/
+func (d *EnttecDMXUSBProController) GetRDMSourceUID() (rdm.UID, error) {
+	d.rdmMu.Lock()
+	defer d.rdmMu.Unlock()
+	return d.getRDMSourceUID()
+}
+
+// 'rdmMu' must be held
+func (d *EnttecDMXUSBProController) getRDMSourceUID() (rdm.UID, error) {
+	if d.hasRDMSourceUID {
+		return d.rdmSourceUID, nil
+	}
+	serialNumber, err := d.GetSerialNumber()
+	if err != nil {
+		return 0, err
+	}
+	return rdm.NewUID(ENTTEC_ESTA_MANUFACTURER_ID, serialNumber), nil
+}
+
+/*
+Send an RDM request (label 7) and wait for the response (label 5).
+
+Source UID, transaction number and port ID are filled in, unless set.
+Requests to broadcast UIDs are not responded to, so an empty message is returned.
+
+The widget stops sending DMX when sending RDM, so the last committed frame is sent again afterwards.
+Responses are only passed on while the widget is in 'send always'-mode, which is the default, see 'SwitchReadMode'.
+
+Example useage:
+
+	response, err := controller.SendRDM(rdm.Message{
+		Destination:  uid,
+		CommandClass: rdm.GET_COMMAND,
+		PID:          rdm.PID_DEVICE_INFO,
+	})
+*/
+func (d *EnttecDMXUSBProController) SendRDM(request rdm.Message) (rdm.Message, error) {
+	d.rdmMu.Lock()
+	defer d.rdmMu.Unlock()
+	defer d.resumeOutput()
+	request, packet, err := d.prepareRDM(request)
+	if err != nil {
+		return rdm.Message{}, err
+	}
+	msg := messages.NewEnttecDMXUSBProApplicationMessage(messages.LABEL_SEND_RDM_PACKET_REQUEST, packet)
+	if request.Destination.IsBroadcast() {
+		return rdm.Message{}, d.writeMessage(msg)
+	}
+	reply, err := d.requestMatching(msg, messages.LABEL_RECEIVED_DMX_PACKET, func(reply messages.EnttecDMXUSBProApplicationMessage) bool {
+		_, err := d.toRDMResponse(reply, request)
+		return err == nil
+	})
+	if err != nil {
+		return rdm.Message{}, d.errorf("no RDM response from %v, %v", request.Destination, err)
+	}
+	return d.toRDMResponse(reply, request)
+}
+
+// Fill in source UID, transaction number and port ID, unless set, and encode the request, 'rdmMu' must be held
+func (d *EnttecDMXUSBProController) prepareRDM(request rdm.Message) (rdm.Message, []byte, error) {
+	if request.Source == 0 {
+		source, err := d.getRDMSourceUID()
+		if err != nil {
+			return request, nil, err
+		}
+		request.Source = source
+	}
+	if request.TransactionNumber == 0 {
+		d.rdmTransaction++
+		request.TransactionNumber = d.rdmTransaction
+	}
+	if request.PortID == 0 {
+		request.PortID = 1
+	}
+	packet, err := request.ToBytes()
+	if err != nil {
+		return request, nil, d.errorf("invalid RDM request, %v", err)
+	}
+	return request, packet, nil
+}
+
+// Decode the RDM packet in the message, if it is the response to the request
+func (d *EnttecDMXUSBProController) toRDMResponse(reply messages.EnttecDMXUSBProApplicationMessage, request rdm.Message) (rdm.Message, error) {
+	packet, err := messages.ToRDMPacket(reply)
+	if err != nil {
+		return rdm.Message{}, err
+	}
+	response, err := rdm.FromBytes(packet)
+	if err != nil {
+		return rdm.Message{}, err
+	}
+	if !response.IsResponse() || response.Source != request.Destination || response.Destination != request.Source || response.TransactionNumber != request.TransactionNumber {
+		return rdm.Message{}, d.errorf("RDM packet is not the response to transaction %d", request.TransactionNumber)
+	}
+	return response, nil
+}
+
+// Send the last committed frame again, as other requests stop the widget from sending DMX
+func (d *EnttecDMXUSBProController) resumeOutput() {
+	d.connMu.Lock()
+	var lastCommitted []byte
+	if d.lastCommitted != nil {
+		lastCommitted = append([]byte{}, d.lastCommitted...)
+	}
+	d.connMu.Unlock()
+	if lastCommitted == nil {
+		return
+	}
+	msg := messages.NewEnttecDMXUSBProApplicationMessage(messages.LABEL_OUTPUT_ONLY_SEND_DMX_PACKET_REQUEST, lastCommitted)
+	if err := d.writeMessage(msg); err != nil {
+		d.printf(1, "Could not resume output: %v", err)
+	}
+}
+
+/*
+Find the UIDs of all RDM responders on the DMX line, sorted ascending, see 'rdm.Discover'.
+
+Discovery requests are sent with label 11, the responses are passed on with label 5.
+A widget passing on nothing when no responder answered costs the reply timeout per request, see 'SetReplyTimeout'.
+
+Example useage:
+
+	uids, err := controller.DiscoverRDM()
+*/
+func (d *EnttecDMXUSBProController) DiscoverRDM() ([]rdm.UID, error) {
+	if _, ok := d.connectedPort(); !ok {
+		return nil, d.errorf("not connected")
+	}
+	return rdm.Discover(&rdmDiscoveryTransport{d: d})
+}
+
+// Sends the requests of 'rdm.Discover' using the widget
+type rdmDiscoveryTransport struct {
+	d *EnttecDMXUSBProController
+}
+
+func (t *rdmDiscoveryTransport) DiscoverUniqueBranch(lower rdm.UID, upper rdm.UID) ([]byte, error) {
+	d := t.d
+	d.rdmMu.Lock()
+	defer d.rdmMu.Unlock()
+	defer d.resumeOutput()
+	_, packet, err := d.prepareRDM(rdm.Message{
+		Destination:   rdm.BROADCAST_UID,
+		CommandClass:  rdm.DISCOVERY_COMMAND,
+		PID:           rdm.PID_DISC_UNIQUE_BRANCH,
+		ParameterData: rdm.NewDiscoverUniqueBranchData(lower, upper),
+	})
+	if err != nil {
+		return nil, err
+	}
+	msg := messages.NewEnttecDMXUSBProApplicationMessage(messages.LABEL_SEND_RDM_DISCOVERY_REQUEST, packet)
+	reply, err := d.requestMatching(msg, messages.LABEL_RECEIVED_DMX_PACKET, func(reply messages.EnttecDMXUSBProApplicationMessage) bool {
+		_, err := messages.ToRDMDiscoveryResponse(reply)
+		return err == nil
+	})
+	if err != nil {
+		return nil, t.noResponse(err)
+	}
+	response, _ := messages.ToRDMDiscoveryResponse(reply)
+	if len(response) == 0 {
+		return nil, nil
+	}
+	return response, nil
+}
+
+func (t *rdmDiscoveryTransport) DiscoveryMute(uid rdm.UID) (bool, error) {
+	response, err := t.d.SendRDM(rdm.Message{Destination: uid, CommandClass: rdm.DISCOVERY_COMMAND, PID: rdm.PID_DISC_MUTE})
+	if err != nil {
+		return false, t.noResponse(err)
+	}
+	return response.CommandClass == rdm.DISCOVERY_COMMAND_RESPONSE, nil
+}
+
+func (t *rdmDiscoveryTransport) DiscoveryUnMuteAll() error {
+	_, err := t.d.SendRDM(rdm.Message{Destination: rdm.BROADCAST_UID, CommandClass: rdm.DISCOVERY_COMMAND, PID: rdm.PID_DISC_UN_MUTE})
+	return err
+}
+
+// A missing response is part of discovery, unless the widget is gone
+func (t *rdmDiscoveryTransport) noResponse(err error) error {
+	if _, ok := t.d.connectedPort(); !ok {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,180 @@
+package dmxusbpro
+
+import (
+	"github.com/H3rby7/usbdmx-golang/rdm"
+)
+
+/*
+Request the device info (PID_DEVICE_INFO) of the responder.
+
+Requests refused by the responder return *rdm.NackError, delayed ones *rdm.AckTimerError, as all of the 'GetRDM...' and 'SetRDM...' methods.
+
+Example useage:
+
+	info, err := controller.GetRDMDeviceInfo(uid)
+*/
+func (d *EnttecDMXUSBProController) GetRDMDeviceInfo(uid rdm.UID) (rdm.DeviceInfo, error) {
+	data, err := d.getRDMParameter(uid, rdm.PID_DEVICE_INFO, nil)
+	if err != nil {
+		return rdm.DeviceInfo{}, err
+	}
+	return rdm.ToDeviceInfo(data)
+}
+
+// Request the user defined name (PID_DEVICE_LABEL) of the responder
+func (d *EnttecDMXUSBProController) GetRDMDeviceLabel(uid rdm.UID) (string, error) {
+	data, err := d.getRDMParameter(uid, rdm.PID_DEVICE_LABEL, nil)
+	if err != nil {
+		return "", err
+	}
+	return rdm.ToLabel(data)
+}
+
+// Set the user defined name (PID_DEVICE_LABEL) of the responder, up to 32 characters
+func (d *EnttecDMXUSBProController) SetRDMDeviceLabel(uid rdm.UID, label string) error {
+	if len(label) > rdm.MAXIMUM_LABEL_LENGTH {
+		return d.errorf("label must be at most %d bytes, but was %d", rdm.MAXIMUM_LABEL_LENGTH, len(label))
+	}
+	return d.setRDMParameter(uid, rdm.PID_DEVICE_LABEL, []byte(label))
+}
+
+// Request the first DMX channel used (PID_DMX_START_ADDRESS) by the responder
+func (d *EnttecDMXUSBProController) GetRDMStartAddress(uid rdm.UID) (uint16, error) {
+	data, err := d.getRDMParameter(uid, rdm.PID_DMX_START_ADDRESS, nil)
+	if err != nil {
+		return 0, err
+	}
+	return rdm.ToUint16(data)
+}
+
+/*
+Set the first DMX channel used (PID_DMX_START_ADDRESS) by the responder.
+
+Example useage:
+
+	err := controller.SetRDMStartAddress(uid, 42)
+*/
+func (d *EnttecDMXUSBProController) SetRDMStartAddress(uid rdm.UID, address uint16) error {
+	if address < rdm.MINIMUM_DMX_START_ADDRESS || address > rdm.MAXIMUM_DMX_START_ADDRESS {
+		return d.errorf("start address must be within [%d..%d], but was %d", rdm.MINIMUM_DMX_START_ADDRESS, rdm.MAXIMUM_DMX_START_ADDRESS, address)
+	}
+	return d.setRDMParameter(uid, rdm.PID_DMX_START_ADDRESS, []byte{byte(address >> 8), byte(address)})
+}
+
+// Request the selected DMX personality (PID_DMX_PERSONALITY) of the responder
+func (d *EnttecDMXUSBProController) GetRDMPersonality(uid rdm.UID) (rdm.Personality, error) {
+	data, err := d.getRDMParameter(uid, rdm.PID_DMX_PERSONALITY, nil)
+	if err != nil {
+		return rdm.Personality{}, err
+	}
+	return rdm.ToPersonality(data)
+}
+
+// Select the DMX personality (PID_DMX_PERSONALITY) of the responder, counting from 1
+func (d *EnttecDMXUSBProController) SetRDMPersonality(uid rdm.UID, personality byte) error {
+	if personality < 1 {
+		return d.errorf("personality must be at least %d, but was %d", 1, personality)
+	}
+	return d.setRDMParameter(uid, rdm.PID_DMX_PERSONALITY, []byte{personality})
+}
+
+// Request whether the responder identifies itself (PID_IDENTIFY_DEVICE)
+func (d *EnttecDMXUSBProController) GetRDMIdentify(uid rdm.UID) (bool, error) {
+	data, err := d.getRDMParameter(uid, rdm.PID_IDENTIFY_DEVICE, nil)
+	if err != nil {
+		return false, err
+	}
+	if len(data) != 1 {
+		return false, d.errorf("identify state must be %d byte, but was %d", 1, len(data))
+	}
+	return data[0] == 1, nil
+}
+
+// Let the responder identify itself (PID_IDENTIFY_DEVICE), e.g. by flashing
+func (d *EnttecDMXUSBProController) SetRDMIdentify(uid rdm.UID, identify bool) error {
+	data := []byte{0}
+	if identify {
+		data[0] = 1
+	}
+	return d.setRDMParameter(uid, rdm.PID_IDENTIFY_DEVICE, data)
+}
+
+// Request the description of the software version (PID_SOFTWARE_VERSION_LABEL) of the responder
+func (d *EnttecDMXUSBProController) GetRDMSoftwareVersionLabel(uid rdm.UID) (string, error) {
+	data, err := d.getRDMParameter(uid, rdm.PID_SOFTWARE_VERSION_LABEL, nil)
+	if err != nil {
+		return "", err
+	}
+	return rdm.ToLabel(data)
+}
+
+// Request the value of a sensor (PID_SENSOR_VALUE) of the responder, counting from 0
+func (d *EnttecDMXUSBProController) GetRDMSensorValue(uid rdm.UID, sensor byte) (rdm.SensorValue, error) {
+	data, err := d.getRDMParameter(uid, rdm.PID_SENSOR_VALUE, []byte{sensor})
+	if err != nil {
+		return rdm.SensorValue{}, err
+	}
+	return rdm.ToSensorValue(data)
+}
+
+// Request the hours the lamp of the responder was on (PID_LAMP_HOURS)
+func (d *EnttecDMXUSBProController) GetRDMLampHours(uid rdm.UID) (uint32, error) {
+	data, err := d.getRDMParameter(uid, rdm.PID_LAMP_HOURS, nil)
+	if err != nil {
+		return 0, err
+	}
+	return rdm.ToUint32(data)
+}
+
+// Request the optional PIDs supported by the responder (PID_SUPPORTED_PARAMETERS)
+func (d *EnttecDMXUSBProController) GetRDMSupportedParameters(uid rdm.UID) ([]uint16, error) {
+	data, err := d.getRDMParameter(uid, rdm.PID_SUPPORTED_PARAMETERS, nil)
+	if err != nil {
+		return nil, err
+	}
+	return rdm.ToPIDs(data)
+}
+
+/*
+Send a GET_COMMAND and return the parameter data of the response.
+
+Responses split with RESPONSE_TYPE_ACK_OVERFLOW are requested again, until the last part arrives.
+*/
+func (d *EnttecDMXUSBProController) getRDMParameter(uid rdm.UID, pid uint16, data []byte) ([]byte, error) {
+	result := make([]byte, 0)
+	for {
+		response, err := d.sendRDMParameter(uid, rdm.GET_COMMAND, pid, data)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, response.ParameterData...)
+		if response.GetResponseType() != rdm.RESPONSE_TYPE_ACK_OVERFLOW {
+			return result, nil
+		}
+	}
+}
+
+// Send a SET_COMMAND
+func (d *EnttecDMXUSBProController) setRDMParameter(uid rdm.UID, pid uint16, data []byte) error {
+	if uid.IsBroadcast() {
+		_, err := d.SendRDM(rdm.Message{Destination: uid, CommandClass: rdm.SET_COMMAND, PID: pid, ParameterData: data})
+		return err
+	}
+	_, err := d.sendRDMParameter(uid, rdm.SET_COMMAND, pid, data)
+	return err
+}
+
+// Send the request and turn responses other than ACK into errors
+func (d *EnttecDMXUSBProController) sendRDMParameter(uid rdm.UID, commandClass byte, pid uint16, data []byte) (rdm.Message, error) {
+	response, err := d.SendRDM(rdm.Message{Destination: uid, CommandClass: commandClass, PID: pid, ParameterData: data})
+	if err != nil {
+		return rdm.Message{}, err
+	}
+	if response.PID != pid {
+		return rdm.Message{}, d.errorf("expected response for PID %04X, but got %04X", pid, response.PID)
+	}
+	if err := rdm.ResponseError(response); err != nil {
+		return rdm.Message{}, err
+	}
+	return response, nil
+}
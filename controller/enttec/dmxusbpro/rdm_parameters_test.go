@@ -0,0 +1,137 @@
+package dmxusbpro
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/H3rby7/usbdmx-golang/controller/enttec/dmxusbpro/emulator"
+	"github.com/H3rby7/usbdmx-golang/rdm"
+)
+
+func newParameterTestFixture() *emulator.Fixture {
+	conf := emulator.DefaultFixtureConfig(testFixtureUID)
+	conf.Footprint = 8
+	conf.PersonalityCount = 2
+	conf.SoftwareVersionLabel = "v2.1"
+	conf.LampHours = 1234
+	conf.Sensors = []int16{-5, 40}
+	return emulator.NewFixture(conf)
+}
+
+func TestGetRDMParameters(t *testing.T) {
+	d, _ := newRDMController(t, newParameterTestFixture())
+	info, err := d.GetRDMDeviceInfo(testFixtureUID)
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if info.Footprint != 8 || info.PersonalityCount != 2 || info.SensorCount != 2 {
+		t.Errorf("expected footprint, personalities and sensors to be 8, 2 and 2, but were %d, %d and %d", info.Footprint, info.PersonalityCount, info.SensorCount)
+	}
+	if label, err := d.GetRDMSoftwareVersionLabel(testFixtureUID); err != nil || label != "v2.1" {
+		t.Errorf("expected software version label 'v2.1', but got '%s' and %v", label, err)
+	}
+	if hours, err := d.GetRDMLampHours(testFixtureUID); err != nil || hours != 1234 {
+		t.Errorf("expected lamp hours to be %d, but got %d and %v", 1234, hours, err)
+	}
+	if value, err := d.GetRDMSensorValue(testFixtureUID, 0); err != nil || value.Present != -5 {
+		t.Errorf("expected sensor value to be %d, but got %d and %v", -5, value.Present, err)
+	}
+	pids, err := d.GetRDMSupportedParameters(testFixtureUID)
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if len(pids) == 0 || pids[0] != rdm.PID_DEVICE_LABEL {
+		t.Errorf("expected supported parameters to begin with %04X, but were %v", rdm.PID_DEVICE_LABEL, pids)
+	}
+}
+
+func TestSetRDMParameters(t *testing.T) {
+	fixture := newParameterTestFixture()
+	d, _ := newRDMController(t, fixture)
+	if err := d.SetRDMDeviceLabel(testFixtureUID, "stage left"); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if label, err := d.GetRDMDeviceLabel(testFixtureUID); err != nil || label != "stage left" {
+		t.Errorf("expected label 'stage left', but got '%s' and %v", label, err)
+	}
+	if err := d.SetRDMStartAddress(testFixtureUID, 100); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if address, err := d.GetRDMStartAddress(testFixtureUID); err != nil || address != 100 {
+		t.Errorf("expected start address to be %d, but got %d and %v", 100, address, err)
+	}
+	if err := d.SetRDMPersonality(testFixtureUID, 2); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if personality, err := d.GetRDMPersonality(testFixtureUID); err != nil || personality.Current != 2 {
+		t.Errorf("expected personality to be %d, but got %d and %v", 2, personality.Current, err)
+	}
+	if err := d.SetRDMIdentify(testFixtureUID, true); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if identify, err := d.GetRDMIdentify(testFixtureUID); err != nil || !identify {
+		t.Errorf("expected fixture to identify, but got %v and %v", identify, err)
+	}
+	if err := d.SetRDMStartAddress(testFixtureUID, 513); err == nil {
+		t.Errorf("expected an error for start address %d", 513)
+	}
+}
+
+func TestRDMParameterNack(t *testing.T) {
+	d, _ := newRDMController(t, newParameterTestFixture())
+	err := d.SetRDMPersonality(testFixtureUID, 3)
+	var nack *rdm.NackError
+	if !errors.As(err, &nack) {
+		t.Fatalf("expected a NackError, but got %v", err)
+	}
+	if nack.Reason != rdm.NACK_REASON_DATA_OUT_OF_RANGE {
+		t.Errorf("expected reason %d, but was %d", rdm.NACK_REASON_DATA_OUT_OF_RANGE, nack.Reason)
+	}
+}
+
+// Responder answering with the given response type, parts of ACK_OVERFLOW count down to ACK
+type scriptedResponder struct {
+	uid           rdm.UID
+	responseType  byte
+	data          []byte
+	overflowParts int
+}
+
+func (r *scriptedResponder) HandleRDM(packet []byte) []byte {
+	request, err := rdm.FromBytes(packet)
+	if err != nil || request.Destination != r.uid {
+		return nil
+	}
+	responseType := r.responseType
+	if r.overflowParts > 0 {
+		r.overflowParts--
+		responseType = rdm.RESPONSE_TYPE_ACK_OVERFLOW
+	}
+	response := rdm.NewResponse(request, responseType, r.data)
+	raw, _ := response.ToBytes()
+	return raw
+}
+
+func TestRDMParameterAckTimer(t *testing.T) {
+	d, _ := newRDMController(t, &scriptedResponder{uid: testFixtureUID, responseType: rdm.RESPONSE_TYPE_ACK_TIMER, data: []byte{0, 3}})
+	_, err := d.GetRDMLampHours(testFixtureUID)
+	var timer *rdm.AckTimerError
+	if !errors.As(err, &timer) {
+		t.Fatalf("expected an AckTimerError, but got %v", err)
+	}
+	if timer.Delay != 300*time.Millisecond {
+		t.Errorf("expected delay to be %v, but was %v", 300*time.Millisecond, timer.Delay)
+	}
+}
+
+func TestRDMParameterAckOverflow(t *testing.T) {
+	d, _ := newRDMController(t, &scriptedResponder{uid: testFixtureUID, responseType: rdm.RESPONSE_TYPE_ACK, data: []byte{0x00, 0x82}, overflowParts: 2})
+	pids, err := d.GetRDMSupportedParameters(testFixtureUID)
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if len(pids) != 3 {
+		t.Errorf("expected the parts of %d responses to be joined, but got %v", 3, pids)
+	}
+}
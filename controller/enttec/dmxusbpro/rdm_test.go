@@ -0,0 +1,189 @@
+package dmxusbpro
+
+import (
+	"bytes"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/H3rby7/usbdmx-golang/controller/enttec/dmxusbpro/emulator"
+	"github.com/H3rby7/usbdmx-golang/controller/enttec/dmxusbpro/messages"
+	"github.com/H3rby7/usbdmx-golang/rdm"
+)
+
+var testFixtureUID = rdm.NewUID(0x7A70, 1)
+
+// Writer remembering all bytes written
+type recordingWriter struct {
+	mu  sync.Mutex
+	w   io.Writer
+	buf bytes.Buffer
+	// Labels to decode, the standard ones unless extended
+	labels messages.LabelSet
+}
+
+func (r *recordingWriter) Write(data []byte) (int, error) {
+	r.mu.Lock()
+	r.buf.Write(data)
+	r.mu.Unlock()
+	return r.w.Write(data)
+}
+
+func (r *recordingWriter) getMessages() []messages.EnttecDMXUSBProApplicationMessage {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	decoder := NewDecoderWithLabels(r.labels)
+	decoder.Feed(r.buf.Bytes())
+	var msgs []messages.EnttecDMXUSBProApplicationMessage
+	for msg, ok := decoder.Next(); ok; msg, ok = decoder.Next() {
+		msgs = append(msgs, msg)
+	}
+	return msgs
+}
+
+func newRDMController(t *testing.T, responders ...emulator.RDMResponder) (*EnttecDMXUSBProController, *recordingWriter) {
+	host, device := emulator.NewPipe()
+	widget := emulator.NewWidget(emulator.DefaultConfig())
+	widget.AttachRDMResponder(responders...)
+	go widget.Serve(device)
+	writes := &recordingWriter{w: host}
+	d := newFakeController(t, &fakeTransport{r: host, w: writes}, true)
+	t.Cleanup(func() { d.Disconnect() })
+	return d, writes
+}
+
+func TestSendRDM(t *testing.T) {
+	conf := emulator.DefaultFixtureConfig(testFixtureUID)
+	conf.StartAddress = 42
+	d, _ := newRDMController(t, emulator.NewFixture(conf))
+	response, err := d.SendRDM(rdm.Message{Destination: testFixtureUID, CommandClass: rdm.GET_COMMAND, PID: rdm.PID_DMX_START_ADDRESS})
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if response.GetResponseType() != rdm.RESPONSE_TYPE_ACK {
+		t.Errorf("expected response type to be %d, but was %d", rdm.RESPONSE_TYPE_ACK, response.GetResponseType())
+	}
+	if response.Source != testFixtureUID {
+		t.Errorf("expected source to be %v, but was %v", testFixtureUID, response.Source)
+	}
+	if !bytes.Equal(response.ParameterData, []byte{0, 42}) {
+		t.Errorf("expected parameter data to be %v, but was %v", []byte{0, 42}, response.ParameterData)
+	}
+	source, _ := d.GetRDMSourceUID()
+	if response.Destination != source || source.GetManufacturer() != ENTTEC_ESTA_MANUFACTURER_ID {
+		t.Errorf("expected destination to be the Enttec source UID %v, but was %v", source, response.Destination)
+	}
+}
+
+func TestSendRDMIncrementsTransactionNumber(t *testing.T) {
+	d, _ := newRDMController(t, emulator.NewFixture(emulator.DefaultFixtureConfig(testFixtureUID)))
+	request := rdm.Message{Destination: testFixtureUID, CommandClass: rdm.GET_COMMAND, PID: rdm.PID_DEVICE_INFO}
+	first, err := d.SendRDM(request)
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	second, err := d.SendRDM(request)
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if second.TransactionNumber != first.TransactionNumber+1 {
+		t.Errorf("expected transaction number to be %d, but was %d", first.TransactionNumber+1, second.TransactionNumber)
+	}
+}
+
+func TestSendRDMSet(t *testing.T) {
+	fixture := emulator.NewFixture(emulator.DefaultFixtureConfig(testFixtureUID))
+	d, _ := newRDMController(t, fixture)
+	d.SetRDMSourceUID(rdm.NewUID(0x7A70, 99))
+	response, err := d.SendRDM(rdm.Message{Destination: testFixtureUID, CommandClass: rdm.SET_COMMAND, PID: rdm.PID_DMX_START_ADDRESS, ParameterData: []byte{1, 0}})
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if response.CommandClass != rdm.SET_COMMAND_RESPONSE {
+		t.Errorf("expected command class to be %X, but was %X", rdm.SET_COMMAND_RESPONSE, response.CommandClass)
+	}
+	if response.Destination != rdm.NewUID(0x7A70, 99) {
+		t.Errorf("expected destination to be the set source UID, but was %v", response.Destination)
+	}
+	if address := fixture.GetConfig().StartAddress; address != 256 {
+		t.Errorf("expected start address to be %d, but was %d", 256, address)
+	}
+}
+
+func TestSendRDMBroadcast(t *testing.T) {
+	fixture := emulator.NewFixture(emulator.DefaultFixtureConfig(testFixtureUID))
+	d, _ := newRDMController(t, fixture)
+	response, err := d.SendRDM(rdm.Message{Destination: rdm.BROADCAST_UID, CommandClass: rdm.SET_COMMAND, PID: rdm.PID_DMX_START_ADDRESS, ParameterData: []byte{0, 7}})
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if response.PID != 0 {
+		t.Errorf("expected an empty response, but got PID %X", response.PID)
+	}
+	for start := time.Now(); fixture.GetConfig().StartAddress != 7; time.Sleep(time.Millisecond) {
+		if time.Since(start) > time.Second {
+			t.Fatalf("expected the broadcast to set the start address")
+		}
+	}
+}
+
+func TestSendRDMResumesOutput(t *testing.T) {
+	d, writes := newRDMController(t, emulator.NewFixture(emulator.DefaultFixtureConfig(testFixtureUID)))
+	d.Stage(1, 255)
+	if err := d.Commit(); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if _, err := d.SendRDM(rdm.Message{Destination: testFixtureUID, CommandClass: rdm.GET_COMMAND, PID: rdm.PID_DEVICE_INFO}); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	msgs := writes.getMessages()
+	last := msgs[len(msgs)-1]
+	if last.GetLabel() != messages.LABEL_OUTPUT_ONLY_SEND_DMX_PACKET_REQUEST {
+		t.Fatalf("expected the last message to be a DMX packet, but was label %d", last.GetLabel())
+	}
+	if payload := last.GetPayload(); len(payload) < 2 || payload[1] != 255 {
+		t.Errorf("expected the last committed frame to be sent again, but was %v", payload)
+	}
+}
+
+func TestSendRDMTimeout(t *testing.T) {
+	d := newFakeController(t, &fakeTransport{r: timeoutReader{}, w: io.Discard}, true)
+	d.SetRDMSourceUID(rdm.NewUID(0x7A70, 99))
+	d.SetReplyTimeout(20 * time.Millisecond)
+	if _, err := d.SendRDM(rdm.Message{Destination: testFixtureUID, CommandClass: rdm.GET_COMMAND, PID: rdm.PID_DEVICE_INFO}); err == nil {
+		t.Errorf("expected an error, as no fixture responds")
+	}
+}
+
+func TestDiscoverRDM(t *testing.T) {
+	uids := []rdm.UID{rdm.NewUID(0x454E, 1), rdm.NewUID(0x454E, 2), testFixtureUID}
+	d, _ := newRDMController(t,
+		emulator.NewFixture(emulator.DefaultFixtureConfig(uids[2])),
+		emulator.NewFixture(emulator.DefaultFixtureConfig(uids[0])),
+		emulator.NewFixture(emulator.DefaultFixtureConfig(uids[1])),
+	)
+	found, err := d.DiscoverRDM()
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if len(found) != len(uids) {
+		t.Fatalf("expected to find %v, but found %v", uids, found)
+	}
+	for i, uid := range uids {
+		if found[i] != uid {
+			t.Errorf("expected UID %d to be %v, but was %v", i, uid, found[i])
+		}
+	}
+}
+
+func TestDiscoverRDMNothing(t *testing.T) {
+	d, _ := newRDMController(t)
+	found, err := d.DiscoverRDM()
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if len(found) != 0 {
+		t.Errorf("expected to find nothing, but found %v", found)
+	}
+}
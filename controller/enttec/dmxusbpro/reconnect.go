@@ -0,0 +1,270 @@
+package dmxusbpro
+
+import (
+	"context"
+	"time"
+
+	"github.com/H3rby7/usbdmx-golang/controller/enttec/dmxusbpro/messages"
+)
+
+// State of the connection to the widget
+type ConnectionState int
+
+const (
+	// Not connected, either not yet connected, disconnected or reconnecting gave up
+	CONNECTION_STATE_DISCONNECTED ConnectionState = iota
+	// Connected to the widget
+	CONNECTION_STATE_CONNECTED
+	// Connection was lost, trying to reconnect
+	CONNECTION_STATE_RECONNECTING
+)
+
+func (s ConnectionState) String() string {
+	switch s {
+	case CONNECTION_STATE_DISCONNECTED:
+		return "DISCONNECTED"
+	case CONNECTION_STATE_CONNECTED:
+		return "CONNECTED"
+	case CONNECTION_STATE_RECONNECTING:
+		return "RECONNECTING"
+	}
+	return "UNKNOWN"
+}
+
+// Exponential backoff between attempts to reconnect
+type ReconnectPolicy struct {
+	// Delay before the first attempt
+	InitialDelay time.Duration
+	// Upper limit for the delay between attempts
+	MaxDelay time.Duration
+	// Factor applied to the delay after each failed attempt
+	Multiplier float64
+	// Give up after this many failed attempts, 0 means never give up
+	MaxAttempts int
+}
+
+// Returns a policy retrying forever, starting at 100ms and backing off up to 10s
+func DefaultReconnectPolicy() ReconnectPolicy {
+	return ReconnectPolicy{
+		InitialDelay: 100 * time.Millisecond,
+		MaxDelay:     10 * time.Second,
+		Multiplier:   2,
+		MaxAttempts:  0,
+	}
+}
+
+/*
+Returns the policy with unusable values replaced, so reconnecting never retries without delay.
+
+A non-positive 'InitialDelay' or a 'Multiplier' below 1 take the value of 'DefaultReconnectPolicy'.
+A 'MaxDelay' below the 'InitialDelay' takes the default if 0, the 'InitialDelay' otherwise.
+*/
+func (p ReconnectPolicy) withDefaults() ReconnectPolicy {
+	defaults := DefaultReconnectPolicy()
+	if p.InitialDelay <= 0 {
+		p.InitialDelay = defaults.InitialDelay
+	}
+	if p.Multiplier < 1 {
+		p.Multiplier = defaults.Multiplier
+	}
+	if p.MaxDelay <= 0 {
+		p.MaxDelay = defaults.MaxDelay
+	}
+	if p.MaxDelay < p.InitialDelay {
+		p.MaxDelay = p.InitialDelay
+	}
+	return p
+}
+
+// Returns the delay to use after the given delay
+func (p ReconnectPolicy) nextDelay(delay time.Duration) time.Duration {
+	next := time.Duration(float64(delay) * p.Multiplier)
+	if next > p.MaxDelay {
+		return p.MaxDelay
+	}
+	return next
+}
+
+/*
+Supervise the connection, reconnecting whenever reading or writing fails (e.g. the widget was unplugged).
+
+After reconnecting the last read mode (see 'SwitchReadMode') and the last committed frame are sent again.
+
+A running 'OnDMXChangeContext' waits for the reconnect instead of returning the error.
+
+Unusable values of the policy are replaced by defaults, e.g. a zero 'InitialDelay' (see 'ReconnectPolicy.withDefaults').
+*/
+func (d *EnttecDMXUSBProController) EnableAutoReconnect(policy ReconnectPolicy) {
+	d.connMu.Lock()
+	defer d.connMu.Unlock()
+	d.autoReconnect = true
+	d.reconnectPolicy = policy.withDefaults()
+}
+
+// Stop supervising the connection, errors are returned to the caller again.
+func (d *EnttecDMXUSBProController) DisableAutoReconnect() {
+	d.connMu.Lock()
+	defer d.connMu.Unlock()
+	d.autoReconnect = false
+}
+
+// Returns the current state of the connection
+func (d *EnttecDMXUSBProController) GetConnectionState() ConnectionState {
+	d.connMu.Lock()
+	defer d.connMu.Unlock()
+	return d.connState
+}
+
+/*
+Get notified about changes of the connection state via channel
+
+Notifications are dropped if the channel is not ready, so it should be buffered.
+
+Example useage:
+
+	c := make(chan dmxusbpro.ConnectionState, 8) // create channel
+	controller.OnConnectionStateChange(c) // subscribe
+	for state := range c { ... } // handle state changes
+*/
+func (d *EnttecDMXUSBProController) OnConnectionStateChange(c chan<- ConnectionState) {
+	d.connMu.Lock()
+	defer d.connMu.Unlock()
+	d.connSubscribers = append(d.connSubscribers, c)
+}
+
+// Change the connection state and notify subscribers, 'connMu' must be held
+func (d *EnttecDMXUSBProController) setConnectionState(state ConnectionState) {
+	if d.connState == state {
+		return
+	}
+	d.printf(1, "Connection state \t%v -> %v", d.connState, state)
+	d.connState = state
+	close(d.connStateChanged)
+	d.connStateChanged = make(chan struct{})
+	for _, c := range d.connSubscribers {
+		select {
+		case c <- state:
+		default:
+		}
+	}
+}
+
+/*
+Handle a failed read or write on the given transport.
+
+Starts reconnecting, if enabled and the transport is still in use.
+*/
+func (d *EnttecDMXUSBProController) connectionLost(port Transport, err error) {
+	d.connMu.Lock()
+	defer d.connMu.Unlock()
+	if !d.autoReconnect || d.port != port || !d.isConnected {
+		return
+	}
+	d.printf(1, "Connection lost: %v", err)
+	d.isConnected = false
+	d.reconnectErr = nil
+	d.reconnectStop = make(chan struct{})
+	d.setConnectionState(CONNECTION_STATE_RECONNECTING)
+	go d.reconnect(port, d.reconnectPolicy, d.reconnectStop)
+}
+
+// Retry connecting with backoff, until connected, stopped or the policy gives up
+func (d *EnttecDMXUSBProController) reconnect(stale Transport, policy ReconnectPolicy, stop chan struct{}) {
+	stale.Close()
+	delay := policy.InitialDelay
+	for attempt := 1; ; attempt++ {
+		select {
+		case <-time.After(delay):
+		case <-stop:
+			return
+		}
+		port, err := d.opener.Open()
+		if err == nil {
+			d.reconnected(port, stop)
+			return
+		}
+		d.printf(1, "Reconnect attempt %d failed: %v", attempt, err)
+		if policy.MaxAttempts > 0 && attempt >= policy.MaxAttempts {
+			d.connMu.Lock()
+			d.reconnectErr = d.errorf("gave up reconnecting after %d attempts, %v", attempt, err)
+			d.setConnectionState(CONNECTION_STATE_DISCONNECTED)
+			d.connMu.Unlock()
+			return
+		}
+		delay = policy.nextDelay(delay)
+	}
+}
+
+// Use the new transport and restore the state of the widget
+func (d *EnttecDMXUSBProController) reconnected(port Transport, stop chan struct{}) {
+	d.connMu.Lock()
+	select {
+	case <-stop:
+		// 'Disconnect' was called meanwhile
+		d.connMu.Unlock()
+		port.Close()
+		return
+	default:
+	}
+	d.port = port
+	d.isConnected = true
+	d.connMu.Unlock()
+	// The port may lead to another widget now
+	d.forgetSerialNumber()
+	d.restore()
+	d.connMu.Lock()
+	defer d.connMu.Unlock()
+	if d.port == port && d.isConnected {
+		d.setConnectionState(CONNECTION_STATE_CONNECTED)
+	}
+}
+
+// Send the last read mode and the last committed frame again
+func (d *EnttecDMXUSBProController) restore() {
+	if d.restoreFirst != nil {
+		d.restoreFirst()
+	}
+	d.connMu.Lock()
+	hasReadMode := d.hasReadMode
+	readMode := d.readMode
+	d.connMu.Unlock()
+	if hasReadMode {
+		msg := messages.NewEnttecDMXUSBProApplicationMessage(messages.LABEL_RECEIVE_DMX_ON_CHANGE, []byte{readMode})
+		if err := d.writeMessage(msg); err != nil {
+			d.printf(1, "Could not restore read mode: %v", err)
+		}
+	}
+	d.resumeOutput()
+}
+
+/*
+Wait for a running reconnect to finish.
+
+Returns true if connected again, false if reconnecting was not running, stopped or gave up, or the context is done.
+*/
+func (d *EnttecDMXUSBProController) waitReconnected(ctx context.Context) bool {
+	for {
+		d.connMu.Lock()
+		state := d.connState
+		changed := d.connStateChanged
+		d.connMu.Unlock()
+		switch state {
+		case CONNECTION_STATE_CONNECTED:
+			return true
+		case CONNECTION_STATE_DISCONNECTED:
+			return false
+		}
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return false
+		}
+	}
+}
+
+// Returns the reason reconnecting gave up, if it did
+func (d *EnttecDMXUSBProController) getReconnectErr() error {
+	d.connMu.Lock()
+	defer d.connMu.Unlock()
+	return d.reconnectErr
+}
@@ -0,0 +1,199 @@
+package dmxusbpro
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/H3rby7/usbdmx-golang/controller/enttec/dmxusbpro/emulator"
+	"github.com/H3rby7/usbdmx-golang/controller/enttec/dmxusbpro/messages"
+)
+
+// Transport recording writes, that can be 'unplugged' to make writes fail
+type unpluggableTransport struct {
+	mu        sync.Mutex
+	written   bytes.Buffer
+	unplugged bool
+}
+
+func (u *unpluggableTransport) Read(buf []byte) (int, error) {
+	return 0, io.EOF
+}
+
+func (u *unpluggableTransport) Write(buf []byte) (int, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.unplugged {
+		return 0, errors.New("unplugged")
+	}
+	return u.written.Write(buf)
+}
+
+func (u *unpluggableTransport) Close() error {
+	return nil
+}
+
+func (u *unpluggableTransport) unplug() {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.unplugged = true
+}
+
+func (u *unpluggableTransport) getWritten() []byte {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return append([]byte{}, u.written.Bytes()...)
+}
+
+func fastReconnectPolicy() ReconnectPolicy {
+	return ReconnectPolicy{InitialDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond, Multiplier: 2}
+}
+
+func expectState(t *testing.T, c chan ConnectionState, expected ConnectionState) {
+	select {
+	case state := <-c:
+		if state != expected {
+			t.Fatalf("expected state to be %v, but was %v", expected, state)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("expected state %v", expected)
+	}
+}
+
+func TestReconnectPolicyBackoff(t *testing.T) {
+	p := ReconnectPolicy{InitialDelay: time.Second, MaxDelay: 3 * time.Second, Multiplier: 2}
+	if delay := p.nextDelay(time.Second); delay != 2*time.Second {
+		t.Errorf("expected delay to be %v, but was %v", 2*time.Second, delay)
+	}
+	if delay := p.nextDelay(2 * time.Second); delay != 3*time.Second {
+		t.Errorf("expected delay to be capped at %v, but was %v", 3*time.Second, delay)
+	}
+}
+
+func TestEnableAutoReconnectZeroPolicy(t *testing.T) {
+	d := NewEnttecDMXUSBProControllerWithTransport(NewTransportOpener("fake", nil), 3, true)
+	d.EnableAutoReconnect(ReconnectPolicy{})
+	if d.reconnectPolicy != DefaultReconnectPolicy() {
+		t.Errorf("expected the zero policy to be replaced by %+v, but was %+v", DefaultReconnectPolicy(), d.reconnectPolicy)
+	}
+	d.EnableAutoReconnect(ReconnectPolicy{InitialDelay: time.Second, MaxDelay: time.Millisecond, Multiplier: 0.5})
+	expected := ReconnectPolicy{InitialDelay: time.Second, MaxDelay: time.Second, Multiplier: 2}
+	if d.reconnectPolicy != expected {
+		t.Errorf("expected policy to be %+v, but was %+v", expected, d.reconnectPolicy)
+	}
+}
+
+func TestAutoReconnectRestoresLastCommittedFrame(t *testing.T) {
+	transports := []*unpluggableTransport{{}, {}}
+	opened := 0
+	opener := NewTransportOpener("fake", func() (Transport, error) {
+		transport := transports[opened]
+		opened++
+		return transport, nil
+	})
+	d := NewEnttecDMXUSBProControllerWithTransport(opener, 3, true)
+	d.EnableAutoReconnect(fastReconnectPolicy())
+	states := make(chan ConnectionState, 8)
+	d.OnConnectionStateChange(states)
+	if err := d.Connect(); err != nil {
+		t.Fatalf("expected no error on connect, but got %v", err)
+	}
+	expectState(t, states, CONNECTION_STATE_CONNECTED)
+	d.Stage(1, 69)
+	d.Commit()
+	transports[0].unplug()
+	d.Stage(2, 96)
+	if err := d.Commit(); err == nil {
+		t.Errorf("expected commit to fail while unplugged")
+	}
+	expectState(t, states, CONNECTION_STATE_RECONNECTING)
+	expectState(t, states, CONNECTION_STATE_CONNECTED)
+	expected := []byte{0x7E, messages.LABEL_OUTPUT_ONLY_SEND_DMX_PACKET_REQUEST, 4, 0, 0, 69, 96, 0, 0xE7}
+	if written := transports[1].getWritten(); !bytes.Equal(written, expected) {
+		t.Errorf("expected restored frame to be %v, but was %v", expected, written)
+	}
+}
+
+func TestAutoReconnectReaderContinues(t *testing.T) {
+	console := emulator.NewWidget(emulator.DefaultConfig())
+	consoleHost, consoleDevice := emulator.NewPipe()
+	go console.Serve(consoleDevice)
+	defer consoleHost.Close()
+	// Every connect plugs in a factory new widget, connected to the console
+	devices := make(chan io.ReadWriteCloser, 2)
+	opener := NewTransportOpener("fake", func() (Transport, error) {
+		widget := emulator.NewWidget(emulator.DefaultConfig())
+		emulator.Link(console, widget)
+		host, device := emulator.NewPipe()
+		go widget.Serve(device)
+		devices <- device
+		return host, nil
+	})
+	d := NewEnttecDMXUSBProControllerWithTransport(opener, 3, false)
+	d.EnableAutoReconnect(fastReconnectPolicy())
+	states := make(chan ConnectionState, 8)
+	d.OnConnectionStateChange(states)
+	d.Connect()
+	defer d.Disconnect()
+	expectState(t, states, CONNECTION_STATE_CONNECTED)
+	d.SwitchReadMode(1)
+	c := make(chan messages.EnttecDMXUSBProApplicationMessage)
+	go d.OnDMXChangeContext(context.Background(), c, nil)
+	// Unplug
+	(<-devices).Close()
+	expectState(t, states, CONNECTION_STATE_RECONNECTING)
+	expectState(t, states, CONNECTION_STATE_CONNECTED)
+	// The console keeps sending DMX, which must be read as change of state once the read mode was restored
+	msg := messages.NewEnttecDMXUSBProApplicationMessage(messages.LABEL_OUTPUT_ONLY_SEND_DMX_PACKET_REQUEST, []byte{0, 69})
+	packet, _ := msg.ToBytes()
+	timeout := time.After(time.Second)
+	for {
+		consoleHost.Write(packet)
+		select {
+		case msg := <-c:
+			if msg.GetLabel() == messages.LABEL_RECEIVED_DMX_CHANGE_OF_STATE_PACKET {
+				return
+			}
+		case <-time.After(10 * time.Millisecond):
+		case <-timeout:
+			t.Fatalf("expected a change of state to be read after reconnecting")
+		}
+	}
+}
+
+func TestAutoReconnectGivesUp(t *testing.T) {
+	opened := false
+	opener := NewTransportOpener("fake", func() (Transport, error) {
+		if opened {
+			return nil, errors.New("no such device")
+		}
+		opened = true
+		return &fakeTransport{r: failingReader{err: io.EOF}, w: io.Discard}, nil
+	})
+	d := NewEnttecDMXUSBProControllerWithTransport(opener, 3, false)
+	policy := fastReconnectPolicy()
+	policy.MaxAttempts = 2
+	d.EnableAutoReconnect(policy)
+	d.Connect()
+	d.SwitchReadMode(1)
+	c := make(chan messages.EnttecDMXUSBProApplicationMessage)
+	result := make(chan error)
+	go func() {
+		result <- d.OnDMXChangeContext(context.Background(), c, nil)
+	}()
+	select {
+	case err := <-result:
+		if err == nil {
+			t.Errorf("expected an error after giving up")
+		}
+	case <-time.After(time.Second):
+		t.Errorf("expected reading to stop after giving up")
+	}
+	if state := d.GetConnectionState(); state != CONNECTION_STATE_DISCONNECTED {
+		t.Errorf("expected state to be %v, but was %v", CONNECTION_STATE_DISCONNECTED, state)
+	}
+}
@@ -0,0 +1,224 @@
+package dmxusbpro
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Highest refresh rate sending a full universe of 512 channels, in frames per second
+const MAXIMUM_REFRESH_RATE = 44
+
+// Sending the stage repeatedly, so staging alone drives the output
+type RefreshConfig struct {
+	// Frames per second, between 1 and 'MAXIMUM_REFRESH_RATE'
+	Rate int
+	// Send the frame again if unchanged for this long, 0 only sends changes
+	KeepAlive time.Duration
+}
+
+// Returns a configuration refreshing at 30 Hz, only sending changes
+func DefaultRefreshConfig() RefreshConfig {
+	return RefreshConfig{Rate: 30, KeepAlive: 0}
+}
+
+// Returns an error if the configuration can not be used
+func (c RefreshConfig) Validate() error {
+	if c.Rate < 1 || c.Rate > MAXIMUM_REFRESH_RATE {
+		return fmt.Errorf(ENTTEC_DMX_USB_PRO_LOG_PREFIX+": refresh rate must be between 1 and %d, but was %d", MAXIMUM_REFRESH_RATE, c.Rate)
+	}
+	if c.KeepAlive < 0 {
+		return fmt.Errorf(ENTTEC_DMX_USB_PRO_LOG_PREFIX+": keep alive must not be negative, but was %v", c.KeepAlive)
+	}
+	return nil
+}
+
+// Counts of the refresh loop, since starting it or the last reset
+type RefreshStatistics struct {
+	// Time the counts were taken over
+	Elapsed time.Duration
+	// Intervals passed, at the configured rate
+	Ticks uint64
+	// Frames sent, including keep alives
+	Frames uint64
+	// Frames sent again unchanged
+	KeepAlives uint64
+	// Frames that could not be sent, they are sent again with the next tick
+	Errors    uint64
+	LastError error
+}
+
+// Returns the achieved frames per second
+func (s RefreshStatistics) GetFrameRate() float64 {
+	if s.Elapsed <= 0 {
+		return 0
+	}
+	return float64(s.Frames) / s.Elapsed.Seconds()
+}
+
+// Returns the achieved ticks per second, the configured rate unless ticks were missed
+func (s RefreshStatistics) GetTickRate() float64 {
+	if s.Elapsed <= 0 {
+		return 0
+	}
+	return float64(s.Ticks) / s.Elapsed.Seconds()
+}
+
+// Routine committing a frame buffer at a fixed rate
+type refresher struct {
+	mu sync.Mutex
+	// Closed to stop the routine, nil if not running
+	stop chan struct{}
+	// Closed when the routine returned
+	done  chan struct{}
+	stats RefreshStatistics
+	// Counting since, and until if stopped
+	since   time.Time
+	stopped time.Time
+}
+
+func (r *refresher) start(conf RefreshConfig, frames *frameBuffer, send func(frame []byte) error) error {
+	if err := conf.Validate(); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.stop != nil {
+		return fmt.Errorf(ENTTEC_DMX_USB_PRO_LOG_PREFIX + ": refresh is already running")
+	}
+	r.stop = make(chan struct{})
+	r.done = make(chan struct{})
+	r.stats = RefreshStatistics{}
+	r.since = time.Now()
+	r.stopped = time.Time{}
+	go r.run(conf, frames, send, r.stop, r.done)
+	return nil
+}
+
+// Stop the routine and wait for it to return, does nothing if not running
+func (r *refresher) halt() {
+	r.mu.Lock()
+	stop := r.stop
+	done := r.done
+	r.stop = nil
+	r.mu.Unlock()
+	if stop == nil {
+		return
+	}
+	close(stop)
+	<-done
+	r.mu.Lock()
+	r.stopped = time.Now()
+	r.mu.Unlock()
+}
+
+func (r *refresher) isRunning() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.stop != nil
+}
+
+func (r *refresher) getStatistics() RefreshStatistics {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stats := r.stats
+	if r.since.IsZero() {
+		return stats
+	}
+	until := r.stopped
+	if until.IsZero() {
+		until = time.Now()
+	}
+	stats.Elapsed = until.Sub(r.since)
+	return stats
+}
+
+func (r *refresher) resetStatistics() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.stats = RefreshStatistics{}
+	r.since = time.Now()
+	if !r.stopped.IsZero() {
+		r.stopped = r.since
+	}
+}
+
+func (r *refresher) run(conf RefreshConfig, frames *frameBuffer, send func(frame []byte) error, stop <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(time.Second / time.Duration(conf.Rate))
+	defer ticker.Stop()
+	lastSent := time.Now()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		sent, err := frames.commitIfDirty(send)
+		keepAlive := false
+		if !sent && conf.KeepAlive > 0 && time.Since(lastSent) >= conf.KeepAlive {
+			keepAlive = true
+			sent, err = true, frames.commit(send)
+		}
+		if sent && err == nil {
+			lastSent = time.Now()
+		}
+		r.mu.Lock()
+		r.stats.Ticks++
+		if err != nil {
+			r.stats.Errors++
+			r.stats.LastError = err
+		} else if sent {
+			r.stats.Frames++
+			if keepAlive {
+				r.stats.KeepAlives++
+			}
+		}
+		r.mu.Unlock()
+	}
+}
+
+/*
+Send the stage at a fixed rate, whenever it changed, so calling 'Stage' is enough to drive the output.
+
+With a keep alive the frame is also sent again when unchanged for that long.
+The first tick sends the stage, even if nothing was staged yet.
+Frames that could not be sent, e.g. while reconnecting, are sent with the next tick.
+With 'EnableAsyncCommit' the frames are handed to the writing routine, like committed ones.
+
+Refreshing stops with 'StopRefresh' or 'Disconnect'. Calling 'Commit' while refreshing is allowed.
+
+Example useage:
+
+	controller.Connect()
+	controller.StartRefresh(dmxusbpro.DefaultRefreshConfig())
+	controller.Stage(1, 255) // sent with the next tick
+	...
+	log.Printf("%.1f frames per second", controller.GetRefreshStatistics().GetFrameRate())
+*/
+func (d *EnttecDMXUSBProController) StartRefresh(conf RefreshConfig) error {
+	if !d.isWriter {
+		return d.errorf("controller is not in WRITE mode")
+	}
+	return d.refresh.start(conf, d.frames, d.commitFrame)
+}
+
+// Stop sending the stage, does nothing if not refreshing
+func (d *EnttecDMXUSBProController) StopRefresh() {
+	d.refresh.halt()
+}
+
+// Returns true between 'StartRefresh' and 'StopRefresh'
+func (d *EnttecDMXUSBProController) IsRefreshing() bool {
+	return d.refresh.isRunning()
+}
+
+// Returns the counts of the refresh since starting it or the last 'ResetRefreshStatistics'
+func (d *EnttecDMXUSBProController) GetRefreshStatistics() RefreshStatistics {
+	return d.refresh.getStatistics()
+}
+
+// Set all counts of the refresh to 0
+func (d *EnttecDMXUSBProController) ResetRefreshStatistics() {
+	d.refresh.resetStatistics()
+}
@@ -0,0 +1,160 @@
+package dmxusbpro
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/H3rby7/usbdmx-golang/controller/enttec/dmxusbpro/messages"
+)
+
+// Returns the payloads of the frames written
+func writtenFrames(writes *recordingWriter) [][]byte {
+	var frames [][]byte
+	for _, msg := range writes.getMessages() {
+		if msg.GetLabel() == messages.LABEL_OUTPUT_ONLY_SEND_DMX_PACKET_REQUEST {
+			frames = append(frames, msg.GetPayload())
+		}
+	}
+	return frames
+}
+
+// Wait until the given number of frames were written
+func waitForFrames(t *testing.T, writes *recordingWriter, count int) [][]byte {
+	deadline := time.Now().Add(time.Second)
+	for {
+		frames := writtenFrames(writes)
+		if len(frames) >= count {
+			return frames
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d frames to be written, but got %d", count, len(frames))
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestRefreshSendsOnlyChanges(t *testing.T) {
+	writes := &recordingWriter{w: io.Discard}
+	d := newFakeController(t, &fakeTransport{w: writes}, true)
+	if err := d.StartRefresh(RefreshConfig{Rate: MAXIMUM_REFRESH_RATE}); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	waitForFrames(t, writes, 1)
+	d.StageRange(1, []byte{1, 2, 3})
+	frames := waitForFrames(t, writes, 2)
+	// Unchanged, so nothing else is sent
+	d.Stage(1, 1)
+	time.Sleep(100 * time.Millisecond)
+	d.StopRefresh()
+	frames = writtenFrames(writes)
+	if len(frames) != 2 {
+		t.Fatalf("expected 2 frames, but got %d", len(frames))
+	}
+	if !bytes.Equal(frames[1], []byte{0, 1, 2, 3}) {
+		t.Errorf("expected frame to be %v, but was %v", []byte{0, 1, 2, 3}, frames[1])
+	}
+	stats := d.GetRefreshStatistics()
+	if stats.Frames != 2 || stats.KeepAlives != 0 || stats.Errors != 0 {
+		t.Errorf("expected 2 frames and no keep alives or errors, but got %+v", stats)
+	}
+	if stats.Ticks < 4 {
+		t.Errorf("expected at least 4 ticks, but got %d", stats.Ticks)
+	}
+}
+
+func TestRefreshKeepAlive(t *testing.T) {
+	writes := &recordingWriter{w: io.Discard}
+	d := newFakeController(t, &fakeTransport{w: writes}, true)
+	if err := d.StartRefresh(RefreshConfig{Rate: MAXIMUM_REFRESH_RATE, KeepAlive: 20 * time.Millisecond}); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	waitForFrames(t, writes, 4)
+	d.StopRefresh()
+	if stats := d.GetRefreshStatistics(); stats.KeepAlives < 3 {
+		t.Errorf("expected at least 3 keep alives, but got %+v", stats)
+	}
+}
+
+func TestRefreshFrameRate(t *testing.T) {
+	writes := &recordingWriter{w: io.Discard}
+	d := newFakeController(t, &fakeTransport{w: writes}, true)
+	// A keep alive shorter than the interval sends every tick
+	if err := d.StartRefresh(RefreshConfig{Rate: 20, KeepAlive: time.Millisecond}); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	time.Sleep(500 * time.Millisecond)
+	d.StopRefresh()
+	stats := d.GetRefreshStatistics()
+	if rate := stats.GetFrameRate(); rate < 16 || rate > 22 {
+		t.Errorf("expected a frame rate of about 20, but was %.1f", rate)
+	}
+	if stats.Frames != stats.Ticks {
+		t.Errorf("expected a frame every tick, but got %+v", stats)
+	}
+	if elapsed := d.GetRefreshStatistics().Elapsed; elapsed != stats.Elapsed {
+		t.Errorf("expected elapsed time to stop with the refresh, but was %v and then %v", stats.Elapsed, elapsed)
+	}
+}
+
+func TestRefreshRetriesFailedFrames(t *testing.T) {
+	d := NewEnttecDMXUSBProControllerWithTransport(NewTransportOpener("fake", nil), 3, true)
+	if err := d.StartRefresh(RefreshConfig{Rate: MAXIMUM_REFRESH_RATE}); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	d.StopRefresh()
+	stats := d.GetRefreshStatistics()
+	if stats.Errors < 2 || stats.Frames != 0 || stats.LastError == nil {
+		t.Errorf("expected every tick to fail, as not connected, but got %+v", stats)
+	}
+}
+
+func TestRefreshStopsOnDisconnect(t *testing.T) {
+	d := newFakeController(t, &fakeTransport{w: io.Discard}, true)
+	if err := d.StartRefresh(DefaultRefreshConfig()); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if err := d.StartRefresh(DefaultRefreshConfig()); err == nil {
+		t.Errorf("expected error, as refresh is already running")
+	}
+	d.Disconnect()
+	if d.IsRefreshing() {
+		t.Errorf("expected refresh to stop when disconnecting")
+	}
+}
+
+func TestStartRefreshValidates(t *testing.T) {
+	d := newFakeController(t, &fakeTransport{w: io.Discard}, true)
+	invalid := []RefreshConfig{
+		{Rate: 0},
+		{Rate: MAXIMUM_REFRESH_RATE + 1},
+		{Rate: 30, KeepAlive: -time.Second},
+	}
+	for _, conf := range invalid {
+		if err := d.StartRefresh(conf); err == nil {
+			t.Errorf("expected error for config %+v", conf)
+		}
+	}
+	reader := newFakeController(t, &fakeTransport{}, false)
+	if err := reader.StartRefresh(DefaultRefreshConfig()); err == nil {
+		t.Errorf("expected error, as the controller is not in WRITE mode")
+	}
+}
+
+func TestSetLogVerbosityWhileRefreshing(t *testing.T) {
+	writes := &recordingWriter{w: io.Discard}
+	d := newFakeController(t, &fakeTransport{w: writes}, true)
+	if err := d.StartRefresh(RefreshConfig{Rate: MAXIMUM_REFRESH_RATE, KeepAlive: time.Millisecond}); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	defer d.StopRefresh()
+	// Written by the refresh routine, while the verbosity changes
+	for start := time.Now(); len(writtenFrames(writes)) < 3; time.Sleep(time.Millisecond) {
+		if time.Since(start) > time.Second {
+			t.Fatalf("expected 3 frames to be written")
+		}
+		d.SetLogVerbosity(0)
+	}
+}
@@ -0,0 +1,66 @@
+package dmxusbpro
+
+import (
+	"fmt"
+	"strconv"
+
+	usbdmxgolang "github.com/H3rby7/usbdmx-golang"
+	"github.com/tarm/serial"
+)
+
+// URI scheme to open the controller with 'usbdmxgolang.Open'
+const DRIVER_SCHEME = "enttec-pro"
+
+func init() {
+	usbdmxgolang.Register(DRIVER_SCHEME, openURI)
+}
+
+/*
+Create a controller from a URI like "enttec-pro:///dev/ttyUSB0?channels=512&mode=write".
+
+Parameters:
+
+	channels  number of DMX channels, defaults to 512
+	mode      'write' (default) or 'read'
+	baud      baud rate of the serial port, defaults to 57600
+	serial    serial number of the widget, looked up instead of giving the device (e.g. "enttec-pro:?serial=12345678")
+*/
+func openURI(uri usbdmxgolang.ControllerURI) (usbdmxgolang.DMXController, error) {
+	if err := uri.CheckParams("channels", "mode", "baud", "serial"); err != nil {
+		return nil, err
+	}
+	channels, err := uri.GetInt("channels", 512)
+	if err != nil {
+		return nil, err
+	}
+	if channels < 1 || channels > 512 {
+		return nil, fmt.Errorf(ENTTEC_DMX_USB_PRO_LOG_PREFIX+": channels must be between 1 and 512, but was %d", channels)
+	}
+	var isWriter bool
+	switch mode := uri.GetString("mode", "write"); mode {
+	case "write":
+		isWriter = true
+	case "read":
+		isWriter = false
+	default:
+		return nil, fmt.Errorf(ENTTEC_DMX_USB_PRO_LOG_PREFIX+": mode must be 'write' or 'read', but was '%s'", mode)
+	}
+	baud, err := uri.GetInt("baud", 57600)
+	if err != nil {
+		return nil, err
+	}
+	if uri.Params.Has("serial") {
+		if uri.Device != "" {
+			return nil, fmt.Errorf(ENTTEC_DMX_USB_PRO_LOG_PREFIX + ": give either a device or a serial number, not both")
+		}
+		serialNumber, err := strconv.ParseUint(uri.Params.Get("serial"), 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf(ENTTEC_DMX_USB_PRO_LOG_PREFIX+": serial must be a number, but was '%s'", uri.Params.Get("serial"))
+		}
+		return NewEnttecDMXUSBProControllerBySerialNumber(uint32(serialNumber), channels, isWriter), nil
+	}
+	if uri.Device == "" {
+		return nil, fmt.Errorf(ENTTEC_DMX_USB_PRO_LOG_PREFIX + ": URI must name a device or a serial number")
+	}
+	return NewEnttecDMXUSBProController(&serial.Config{Name: uri.Device, Baud: baud}, channels, isWriter), nil
+}
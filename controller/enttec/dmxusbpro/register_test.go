@@ -0,0 +1,52 @@
+package dmxusbpro
+
+import (
+	"testing"
+
+	usbdmxgolang "github.com/H3rby7/usbdmx-golang"
+)
+
+func TestOpenURI(t *testing.T) {
+	controller, err := usbdmxgolang.Open("enttec-pro:///dev/ttyUSB0?channels=16&mode=read")
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	d, ok := controller.(*EnttecDMXUSBProController)
+	if !ok {
+		t.Fatalf("expected an EnttecDMXUSBProController, but got %T", controller)
+	}
+	if d.GetName() != "/dev/ttyUSB0" {
+		t.Errorf("expected name to be '/dev/ttyUSB0', but was '%s'", d.GetName())
+	}
+	if !d.isReader || d.isWriter {
+		t.Errorf("expected the controller to read")
+	}
+	if len(d.GetStage()) != 17 {
+		t.Errorf("expected 16 channels, but stage has size %d", len(d.GetStage()))
+	}
+}
+
+func TestOpenURIBySerialNumber(t *testing.T) {
+	controller, err := usbdmxgolang.Open("enttec-pro:?serial=12345678")
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if _, ok := controller.(*EnttecDMXUSBProController).opener.(*SerialNumberOpener); !ok {
+		t.Errorf("expected the widget to be looked up by serial number")
+	}
+}
+
+func TestOpenURIInvalid(t *testing.T) {
+	invalid := []string{
+		"enttec-pro:///dev/ttyUSB0?mode=both",
+		"enttec-pro:///dev/ttyUSB0?channels=513",
+		"enttec-pro:///dev/ttyUSB0?serial=1",
+		"enttec-pro:///dev/ttyUSB0?chanels=1",
+		"enttec-pro:",
+	}
+	for _, uri := range invalid {
+		if _, err := usbdmxgolang.Open(uri); err == nil {
+			t.Errorf("expected error for '%s'", uri)
+		}
+	}
+}
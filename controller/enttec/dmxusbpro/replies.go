@@ -0,0 +1,244 @@
+package dmxusbpro
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/H3rby7/usbdmx-golang/controller/enttec/dmxusbpro/messages"
+)
+
+// Time to wait for the widget to reply to a request, unless configured otherwise
+const DEFAULT_REPLY_TIMEOUT = 500 * time.Millisecond
+
+// Set the time to wait for the widget to reply to a request
+func (d *EnttecDMXUSBProController) SetReplyTimeout(timeout time.Duration) {
+	d.replyMu.Lock()
+	defer d.replyMu.Unlock()
+	d.replyTimeout = timeout
+}
+
+/*
+Ask the widget for its parameters (label 3), waiting for the reply.
+
+No user configuration bytes are requested, see 'GetWidgetParametersWithUserConfig'.
+
+Example useage:
+
+	params, err := controller.GetWidgetParameters()
+	log.Printf("Firmware %s, break %.2fus", params.GetFirmwareVersionString(), params.GetBreakTimeMicroseconds())
+*/
+func (d *EnttecDMXUSBProController) GetWidgetParameters() (messages.WidgetParameters, error) {
+	return d.GetWidgetParametersWithUserConfig(0)
+}
+
+// Like 'GetWidgetParameters', also requesting the given number of user configuration bytes
+func (d *EnttecDMXUSBProController) GetWidgetParametersWithUserConfig(size int) (messages.WidgetParameters, error) {
+	if size < 0 || size > messages.MAXIMUM_USER_CONFIG_SIZE {
+		return messages.WidgetParameters{}, d.errorf("user configuration size %d out of range, must be between 0 and %d", size, messages.MAXIMUM_USER_CONFIG_SIZE)
+	}
+	msg := messages.NewEnttecDMXUSBProApplicationMessage(messages.LABEL_GET_WIDGET_PARAMS_REQUEST, []byte{byte(size & 0xFF), byte(size >> 8 & 0xFF)})
+	reply, err := d.request(msg, messages.LABEL_GET_WIDGET_PARAMS_REPLY)
+	if err != nil {
+		return messages.WidgetParameters{}, err
+	}
+	return messages.ToWidgetParameters(reply)
+}
+
+/*
+Change the widget's parameters (label 4), the firmware version is ignored.
+
+Only as many user configuration bytes as given are written, the following ones are kept.
+
+Example useage:
+
+	params := messages.WidgetParameters{BreakTime: 20, MABTime: 2, OutputRate: 30}
+	err := controller.SetWidgetParameters(params)
+*/
+func (d *EnttecDMXUSBProController) SetWidgetParameters(params messages.WidgetParameters) error {
+	msg, err := messages.FromWidgetParameters(params)
+	if err != nil {
+		return d.errorf("invalid widget parameters, %v", err)
+	}
+	return d.writeMessage(msg)
+}
+
+/*
+Read the widget's parameters, change them and write them back.
+
+The user configuration is not read, so it is only written if 'update' sets it.
+
+Example useage:
+
+	err := controller.UpdateWidgetParameters(func(params *messages.WidgetParameters) {
+		params.BreakTime = 20
+	})
+*/
+func (d *EnttecDMXUSBProController) UpdateWidgetParameters(update func(params *messages.WidgetParameters)) error {
+	params, err := d.GetWidgetParameters()
+	if err != nil {
+		return err
+	}
+	update(&params)
+	return d.SetWidgetParameters(params)
+}
+
+/*
+Ask the widget for its serial number (label 10), as printed on the case.
+
+The serial number is cached until the controller (re)connects.
+*/
+func (d *EnttecDMXUSBProController) GetSerialNumber() (uint32, error) {
+	d.serialMu.Lock()
+	if d.hasSerialNumber {
+		defer d.serialMu.Unlock()
+		return d.serialNumber, nil
+	}
+	d.serialMu.Unlock()
+	msg := messages.NewEnttecDMXUSBProApplicationMessage(messages.LABEL_WIDGET_GET_SERIAL_NUMBER_REQUEST, []byte{})
+	reply, err := d.request(msg, messages.LABEL_WIDGET_GET_SERIAL_NUMBER_REPLY)
+	if err != nil {
+		return 0, err
+	}
+	serialNumber, err := messages.ToSerialNumber(reply)
+	if err != nil {
+		return 0, d.errorf("invalid serial number reply, %v", err)
+	}
+	d.serialMu.Lock()
+	defer d.serialMu.Unlock()
+	d.serialNumber = serialNumber
+	d.hasSerialNumber = true
+	return serialNumber, nil
+}
+
+/*
+Returns the name used for opening the connection, followed by the serial number once known (see 'GetSerialNumber').
+
+e.g. "COM4 #12345678" or "COM4"
+*/
+func (d *EnttecDMXUSBProController) GetIdentifier() string {
+	d.serialMu.Lock()
+	defer d.serialMu.Unlock()
+	if !d.hasSerialNumber {
+		return d.GetName()
+	}
+	return fmt.Sprintf("%s #%d", d.GetName(), d.serialNumber)
+}
+
+func (d *EnttecDMXUSBProController) forgetSerialNumber() {
+	d.serialMu.Lock()
+	defer d.serialMu.Unlock()
+	d.hasSerialNumber = false
+}
+
+// Request waiting for a reply
+type replyWaiter struct {
+	reply chan messages.EnttecDMXUSBProApplicationMessage
+	// Decides whether a message with the awaited label is the reply, nil accepts any
+	accept func(msg messages.EnttecDMXUSBProApplicationMessage) bool
+}
+
+func (w *replyWaiter) accepts(msg messages.EnttecDMXUSBProApplicationMessage) bool {
+	return w.accept == nil || w.accept(msg)
+}
+
+// Send the request and wait for the reply with the given label, see 'requestMatching'
+func (d *EnttecDMXUSBProController) request(msg messages.EnttecDMXUSBProApplicationMessage, replyLabel byte) (messages.EnttecDMXUSBProApplicationMessage, error) {
+	return d.requestMatching(msg, replyLabel, nil)
+}
+
+/*
+Send the request and wait for the reply with the given label, that is accepted by 'accept' (nil accepts any).
+
+If a read routine (see 'OnDMXChangeContext') is running, it hands over the reply.
+Otherwise the reply is read directly, skipping any other messages, which relies on the transport's read timeout.
+*/
+func (d *EnttecDMXUSBProController) requestMatching(msg messages.EnttecDMXUSBProApplicationMessage, replyLabel byte, accept func(msg messages.EnttecDMXUSBProApplicationMessage) bool) (messages.EnttecDMXUSBProApplicationMessage, error) {
+	waiter := &replyWaiter{reply: make(chan messages.EnttecDMXUSBProApplicationMessage, 1), accept: accept}
+	d.replyMu.Lock()
+	d.replyWaiters[replyLabel] = append(d.replyWaiters[replyLabel], waiter)
+	reading := d.readLoops > 0
+	timeout := d.replyTimeout
+	d.replyMu.Unlock()
+	defer d.removeReplyWaiter(replyLabel, waiter)
+	if err := d.writeMessage(msg); err != nil {
+		return messages.EnttecDMXUSBProApplicationMessage{}, err
+	}
+	deadline := time.Now().Add(timeout)
+	if !reading {
+		return d.readReply(replyLabel, waiter, deadline)
+	}
+	select {
+	case msg := <-waiter.reply:
+		return msg, nil
+	case <-time.After(time.Until(deadline)):
+		return messages.EnttecDMXUSBProApplicationMessage{}, d.errorf("no reply with label %d within %v", replyLabel, timeout)
+	}
+}
+
+// Read from the transport until the reply arrives or the deadline passes
+func (d *EnttecDMXUSBProController) readReply(replyLabel byte, waiter *replyWaiter, deadline time.Time) (messages.EnttecDMXUSBProApplicationMessage, error) {
+	port, ok := d.connectedPort()
+	if !ok {
+		return messages.EnttecDMXUSBProApplicationMessage{}, d.errorf("not connected")
+	}
+	readBuf := make([]byte, messages.MAXIMUM_MESSAGE_LENGTH)
+	decoder := NewDecoderWithLabels(d.labels)
+	for time.Now().Before(deadline) {
+		n, err := port.Read(readBuf)
+		if err != nil {
+			d.connectionLost(port, err)
+			return messages.EnttecDMXUSBProApplicationMessage{}, d.errorf("error reading reply, %v", err)
+		}
+		decoder.Feed(readBuf[:n])
+		for msg, ok := decoder.Next(); ok; msg, ok = decoder.Next() {
+			d.countReceived(msg)
+			if msg.GetLabel() != replyLabel || !waiter.accepts(msg) {
+				d.printf(1, "Skipping while waiting for reply \tlabel=%v \tdata=%v", msg.GetLabel(), msg.GetPayload())
+				continue
+			}
+			d.printf(1, "Read reply \tlabel=%v \tdata=%v", msg.GetLabel(), msg.GetPayload())
+			return messages.NewEnttecDMXUSBProApplicationMessageWithLabels(d.labels, msg.GetLabel(), append([]byte{}, msg.GetPayload()...)), nil
+		}
+	}
+	return messages.EnttecDMXUSBProApplicationMessage{}, d.errorf("no reply with label %d before %v", replyLabel, deadline.Format(time.StampMilli))
+}
+
+// Hand the message to the oldest request waiting for it, returns false if none is waiting
+func (d *EnttecDMXUSBProController) deliverReply(msg messages.EnttecDMXUSBProApplicationMessage) bool {
+	d.replyMu.Lock()
+	defer d.replyMu.Unlock()
+	waiters := d.replyWaiters[msg.GetLabel()]
+	for i, waiter := range waiters {
+		if waiter.accepts(msg) {
+			waiter.reply <- msg
+			d.replyWaiters[msg.GetLabel()] = append(waiters[:i:i], waiters[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func (d *EnttecDMXUSBProController) removeReplyWaiter(replyLabel byte, waiter *replyWaiter) {
+	d.replyMu.Lock()
+	defer d.replyMu.Unlock()
+	waiters := d.replyWaiters[replyLabel]
+	for i, w := range waiters {
+		if w == waiter {
+			d.replyWaiters[replyLabel] = append(waiters[:i:i], waiters[i+1:]...)
+			return
+		}
+	}
+}
+
+// Register a running read routine, replies are handed over by it from now on
+func (d *EnttecDMXUSBProController) startReadLoop() {
+	d.replyMu.Lock()
+	defer d.replyMu.Unlock()
+	d.readLoops++
+}
+
+func (d *EnttecDMXUSBProController) stopReadLoop() {
+	d.replyMu.Lock()
+	defer d.replyMu.Unlock()
+	d.readLoops--
+}
@@ -0,0 +1,169 @@
+package dmxusbpro
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/H3rby7/usbdmx-golang/controller/enttec/dmxusbpro/emulator"
+	"github.com/H3rby7/usbdmx-golang/controller/enttec/dmxusbpro/messages"
+)
+
+func newEmulatedController(t *testing.T, conf emulator.Config, isWriter bool) *EnttecDMXUSBProController {
+	host, device := emulator.NewPipe()
+	go emulator.NewWidget(conf).Serve(device)
+	d := newFakeController(t, host, isWriter)
+	t.Cleanup(func() { d.Disconnect() })
+	return d
+}
+
+func TestGetWidgetParameters(t *testing.T) {
+	conf := emulator.DefaultConfig()
+	conf.UserConfig = []byte{69, 96}
+	d := newEmulatedController(t, conf, true)
+	params, err := d.GetWidgetParametersWithUserConfig(2)
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if params.FirmwareVersion != conf.FirmwareVersion {
+		t.Errorf("expected firmware version to be %X, but was %X", conf.FirmwareVersion, params.FirmwareVersion)
+	}
+	if params.BreakTime != 9 || params.MABTime != 1 || params.OutputRate != 40 {
+		t.Errorf("expected break, MAB and rate to be 9, 1 and 40, but were %d, %d and %d", params.BreakTime, params.MABTime, params.OutputRate)
+	}
+	if !bytes.Equal(params.UserConfig, conf.UserConfig) {
+		t.Errorf("expected user config to be %v, but was %v", conf.UserConfig, params.UserConfig)
+	}
+}
+
+func TestGetWidgetParametersWhileReading(t *testing.T) {
+	d := newEmulatedController(t, emulator.DefaultConfig(), false)
+	d.SwitchReadMode(1)
+	c := make(chan messages.EnttecDMXUSBProApplicationMessage, 8)
+	go d.OnDMXChangeContext(context.Background(), c, nil)
+	// Wait for the read routine to be running
+	for start := time.Now(); ; time.Sleep(time.Millisecond) {
+		d.replyMu.Lock()
+		reading := d.readLoops > 0
+		d.replyMu.Unlock()
+		if reading {
+			break
+		}
+		if time.Since(start) > time.Second {
+			t.Fatalf("expected read routine to start")
+		}
+	}
+	params, err := d.GetWidgetParameters()
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if params.OutputRate != 40 {
+		t.Errorf("expected output rate to be %d, but was %d", 40, params.OutputRate)
+	}
+	select {
+	case msg := <-c:
+		t.Errorf("expected the reply not to be passed on, but got label %d", msg.GetLabel())
+	default:
+	}
+}
+
+func TestGetWidgetParametersTimeout(t *testing.T) {
+	d := newFakeController(t, &fakeTransport{r: timeoutReader{}, w: io.Discard}, true)
+	d.SetReplyTimeout(20 * time.Millisecond)
+	if _, err := d.GetWidgetParameters(); err == nil {
+		t.Errorf("expected an error, as the widget does not reply")
+	}
+}
+
+func TestSetWidgetParameters(t *testing.T) {
+	d := newEmulatedController(t, emulator.DefaultConfig(), true)
+	if err := d.SetWidgetParameters(messages.WidgetParameters{BreakTime: 20, MABTime: 5, OutputRate: 30, UserConfig: []byte{69}}); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	params, err := d.GetWidgetParametersWithUserConfig(1)
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if params.BreakTime != 20 || params.MABTime != 5 || params.OutputRate != 30 {
+		t.Errorf("expected break, MAB and rate to be 20, 5 and 30, but were %d, %d and %d", params.BreakTime, params.MABTime, params.OutputRate)
+	}
+	if !bytes.Equal(params.UserConfig, []byte{69}) {
+		t.Errorf("expected user config to be %v, but was %v", []byte{69}, params.UserConfig)
+	}
+}
+
+func TestSetWidgetParametersRejectsInvalid(t *testing.T) {
+	out := &bytes.Buffer{}
+	d := newFakeController(t, &fakeTransport{w: out}, true)
+	if err := d.SetWidgetParameters(messages.WidgetParameters{BreakTime: 5, MABTime: 1}); err == nil {
+		t.Errorf("expected an error for a break time of 5")
+	}
+	if out.Len() != 0 {
+		t.Errorf("expected nothing to be written, but was %v", out.Bytes())
+	}
+}
+
+func TestUpdateWidgetParameters(t *testing.T) {
+	conf := emulator.DefaultConfig()
+	conf.UserConfig = []byte{69, 96}
+	d := newEmulatedController(t, conf, true)
+	err := d.UpdateWidgetParameters(func(params *messages.WidgetParameters) {
+		params.BreakTime = 20
+	})
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	params, err := d.GetWidgetParametersWithUserConfig(2)
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if params.BreakTime != 20 || params.MABTime != 1 || params.OutputRate != 40 {
+		t.Errorf("expected break, MAB and rate to be 20, 1 and 40, but were %d, %d and %d", params.BreakTime, params.MABTime, params.OutputRate)
+	}
+	if !bytes.Equal(params.UserConfig, conf.UserConfig) {
+		t.Errorf("expected user config to be kept as %v, but was %v", conf.UserConfig, params.UserConfig)
+	}
+}
+
+func TestGetSerialNumber(t *testing.T) {
+	conf := emulator.DefaultConfig()
+	conf.SerialNumber = 12345678
+	d := newEmulatedController(t, conf, true)
+	if identifier := d.GetIdentifier(); identifier != "fake" {
+		t.Errorf("expected identifier to be 'fake', but was '%s'", identifier)
+	}
+	serialNumber, err := d.GetSerialNumber()
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if serialNumber != 12345678 {
+		t.Errorf("expected serial number to be %d, but was %d", 12345678, serialNumber)
+	}
+	if identifier := d.GetIdentifier(); identifier != "fake #12345678" {
+		t.Errorf("expected identifier to be 'fake #12345678', but was '%s'", identifier)
+	}
+}
+
+func TestGetSerialNumberIsCached(t *testing.T) {
+	out := &bytes.Buffer{}
+	d := newFakeController(t, &fakeTransport{r: bytes.NewReader([]byte{0x7E, 10, 4, 0, 0x78, 0x56, 0x34, 0x12, 0xE7}), w: out}, true)
+	d.GetSerialNumber()
+	written := out.Len()
+	serialNumber, err := d.GetSerialNumber()
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if serialNumber != 12345678 {
+		t.Errorf("expected serial number to be %d, but was %d", 12345678, serialNumber)
+	}
+	if out.Len() != written {
+		t.Errorf("expected the cached serial number to be used, but the request was sent again")
+	}
+	d.Disconnect()
+	d.Connect()
+	if identifier := d.GetIdentifier(); identifier != "fake" {
+		t.Errorf("expected serial number to be forgotten on connect, but identifier was '%s'", identifier)
+	}
+}
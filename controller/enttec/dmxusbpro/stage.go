@@ -0,0 +1,109 @@
+package dmxusbpro
+
+import (
+	"fmt"
+	"sync"
+)
+
+/*
+Double buffered DMX frame, safe for concurrent use.
+
+Values are staged into the back buffer, committing copies it into the front buffer at once.
+A committed frame thereby never mixes values of a single 'stage' call, even while other routines keep staging.
+*/
+type frameBuffer struct {
+	// Guards the back buffer
+	mu sync.Mutex
+	// Staged values, as DMX starts with channel '1' the index '0' is unused.
+	back []byte
+	// Staged values changed since the last commit, or the last commit failed
+	dirty bool
+	// Serialises commits, guards the front buffer
+	commitMu sync.Mutex
+	// Committed values, while being sent
+	front []byte
+}
+
+func newFrameBuffer(dmxChannelCount int) *frameBuffer {
+	// Dirty, so the first frame is sent even if nothing was staged
+	return &frameBuffer{back: make([]byte, dmxChannelCount+1), front: make([]byte, dmxChannelCount+1), dirty: true}
+}
+
+// Stage consecutive channels, beginning at the given channel
+func (b *frameBuffer) stage(channel int16, values []byte) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	highestChannel := int16(len(b.back) - 1)
+	if channel < 1 || int(channel)+len(values)-1 > int(highestChannel) {
+		return fmt.Errorf(ENTTEC_DMX_USB_PRO_LOG_PREFIX+": index %d out of range, must be between 1 and %d", int(channel)+len(values)-1, highestChannel)
+	}
+	for i, value := range values {
+		if b.back[int(channel)+i] != value {
+			b.back[int(channel)+i] = value
+			b.dirty = true
+		}
+	}
+	return nil
+}
+
+// Returns a copy of the back buffer
+func (b *frameBuffer) getStaged() []byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]byte{}, b.back...)
+}
+
+// Set all staged values to '0'
+func (b *frameBuffer) clear() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for i := range b.back {
+		if b.back[i] != 0 {
+			b.back[i] = 0
+			b.dirty = true
+		}
+	}
+}
+
+// Send the staged values with the next 'commitIfDirty', e.g. after writing them failed elsewhere
+func (b *frameBuffer) markDirty() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.dirty = true
+}
+
+/*
+Snapshot the back buffer and pass the snapshot to 'send', one commit at a time.
+
+The snapshot must not be used after 'send' returns.
+*/
+func (b *frameBuffer) commit(send func(frame []byte) error) error {
+	_, err := b.commitWhen(false, send)
+	return err
+}
+
+// Like 'commit', but only if the staged values changed since the last commit. Returns whether 'send' was called.
+func (b *frameBuffer) commitIfDirty(send func(frame []byte) error) (bool, error) {
+	return b.commitWhen(true, send)
+}
+
+func (b *frameBuffer) commitWhen(onlyDirty bool, send func(frame []byte) error) (bool, error) {
+	b.commitMu.Lock()
+	defer b.commitMu.Unlock()
+	b.mu.Lock()
+	if onlyDirty && !b.dirty {
+		b.mu.Unlock()
+		return false, nil
+	}
+	copy(b.front, b.back)
+	b.dirty = false
+	b.mu.Unlock()
+	err := send(b.front)
+	if err != nil {
+		// Send again with the next commit
+		b.mu.Lock()
+		b.dirty = true
+		b.mu.Unlock()
+	}
+	return true, err
+}
@@ -0,0 +1,54 @@
+package dmxusbpro
+
+import (
+	"github.com/H3rby7/usbdmx-golang/controller/enttec/dmxusbpro/messages"
+)
+
+// Counts of the 'Received DMX Packet's (label 5) read, by receive status bit
+type ReceiveStatistics struct {
+	// Packets read, of any start code
+	Packets uint64
+	// Packets reporting that the widget receive queue overflowed
+	QueueOverflows uint64
+	// Packets reporting a widget receive overrun
+	Overruns uint64
+}
+
+/*
+Returns the counts of received packets since creating the controller or the last 'ResetReceiveStatistics'.
+
+Packets are counted as they are read, by 'OnDMXChange' or while waiting for a reply.
+
+Example useage:
+
+	stats := controller.GetReceiveStatistics()
+	log.Printf("%d of %d packets overran", stats.Overruns, stats.Packets)
+*/
+func (d *EnttecDMXUSBProController) GetReceiveStatistics() ReceiveStatistics {
+	d.statsMu.Lock()
+	defer d.statsMu.Unlock()
+	return d.receiveStats
+}
+
+// Set all counts of received packets to 0
+func (d *EnttecDMXUSBProController) ResetReceiveStatistics() {
+	d.statsMu.Lock()
+	defer d.statsMu.Unlock()
+	d.receiveStats = ReceiveStatistics{}
+}
+
+func (d *EnttecDMXUSBProController) countReceived(msg messages.EnttecDMXUSBProApplicationMessage) {
+	packet, err := messages.ToReceivedDMXPacket(msg)
+	if err != nil {
+		return
+	}
+	d.statsMu.Lock()
+	defer d.statsMu.Unlock()
+	d.receiveStats.Packets++
+	if packet.Status.IsQueueOverflow() {
+		d.receiveStats.QueueOverflows++
+	}
+	if packet.Status.IsOverrun() {
+		d.receiveStats.Overruns++
+	}
+}
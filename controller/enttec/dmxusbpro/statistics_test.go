@@ -0,0 +1,50 @@
+package dmxusbpro
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+
+	"github.com/H3rby7/usbdmx-golang/controller/enttec/dmxusbpro/messages"
+)
+
+func TestReceiveStatistics(t *testing.T) {
+	stream := make([]byte, 0)
+	payloads := [][]byte{
+		{0, messages.DMX_START_CODE, 69},
+		{messages.RECEIVE_STATUS_OVERRUN, messages.TEXT_PACKET_START_CODE, 'h', 'i'},
+		{messages.RECEIVE_STATUS_QUEUE_OVERFLOW | messages.RECEIVE_STATUS_OVERRUN, messages.SYSTEM_INFORMATION_PACKET_START_CODE},
+		// Status only
+		{messages.RECEIVE_STATUS_QUEUE_OVERFLOW},
+	}
+	for _, payload := range payloads {
+		msg := messages.NewEnttecDMXUSBProApplicationMessage(messages.LABEL_RECEIVED_DMX_PACKET, payload)
+		raw, _ := msg.ToBytes()
+		stream = append(stream, raw...)
+	}
+	d := newFakeController(t, &fakeTransport{r: bytes.NewReader(stream), w: io.Discard}, false)
+	d.SwitchReadMode(0)
+	c := make(chan messages.EnttecDMXUSBProApplicationMessage, len(payloads))
+	// Stops at the end of the stream
+	d.OnDMXChangeContext(context.Background(), c, nil)
+	received := 0
+	for msg := range c {
+		if _, err := messages.ToReceivedDMXPacket(msg); err != nil {
+			t.Errorf("expected a received DMX packet, but got %v", err)
+		}
+		received++
+	}
+	if received != len(payloads) {
+		t.Errorf("expected all %d packets to be passed on, but were %d", len(payloads), received)
+	}
+	stats := d.GetReceiveStatistics()
+	expected := ReceiveStatistics{Packets: 4, QueueOverflows: 2, Overruns: 2}
+	if stats != expected {
+		t.Errorf("expected statistics to be %+v, but were %+v", expected, stats)
+	}
+	d.ResetReceiveStatistics()
+	if stats := d.GetReceiveStatistics(); stats != (ReceiveStatistics{}) {
+		t.Errorf("expected statistics to be reset, but were %+v", stats)
+	}
+}
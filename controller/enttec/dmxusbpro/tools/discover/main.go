@@ -0,0 +1,28 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"time"
+
+	"github.com/H3rby7/usbdmx-golang/controller/enttec/dmxusbpro"
+)
+
+func main() {
+	timeout := flag.Duration("timeout", 500*time.Millisecond, "Time each candidate has to answer")
+	flag.Parse()
+
+	opts := dmxusbpro.DefaultDiscoveryOptions()
+	opts.Timeout = *timeout
+	widgets, err := dmxusbpro.Discover(opts)
+	if err != nil {
+		log.Fatalf("Failed to discover widgets: %s", err)
+	}
+	if len(widgets) == 0 {
+		log.Printf("No widgets found.")
+	}
+	for _, widget := range widgets {
+		log.Printf("Found widget \tport=%s \tserial=%d \tfirmware=%d.%d",
+			widget.Port, widget.SerialNumber, widget.FirmwareVersion>>8, widget.FirmwareVersion&0xFF)
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/H3rby7/usbdmx-golang/controller/enttec/dmxusbpro/emulator"
+	"github.com/H3rby7/usbdmx-golang/rdm"
+)
+
+func main() {
+	count := flag.Int("count", 1, "Number of widgets to emulate, multiple widgets are connected to each other using DMX")
+	serialNumber := flag.Uint("serial", 1, "Serial number of the first widget, further widgets count up")
+	verbosity := flag.Uint("verbosity", 0, "Log verbosity 0 = no logging; 1 = message logging")
+	fixtures := flag.Int("fixtures", 0, "Number of RDM fixtures on the DMX line, with footprints of 1, 2, 4... channels")
+	mk2 := flag.Bool("mk2", false, "Emulate DMX USB Pro Mk2 widgets with a second port, using made up labels and API key")
+	flag.Parse()
+	if *count < 1 {
+		log.Fatalf("Count must be at least 1, but was %d", *count)
+	}
+
+	widgets := make([]*emulator.Widget, 0, *count)
+	ptys := make([]*emulator.PTY, 0, *count)
+	for i := 0; i < *count; i++ {
+		conf := emulator.DefaultConfig()
+		if *mk2 {
+			conf = emulator.DefaultMk2Config()
+		}
+		conf.SerialNumber = uint32(*serialNumber) + uint32(i)
+		widget := emulator.NewWidget(conf)
+		widget.SetLogVerbosity(uint8(*verbosity))
+		pty, err := emulator.OpenPTY()
+		if err != nil {
+			log.Fatalf("Failed to open pseudo terminal: %s", err)
+		}
+		go func() {
+			if err := widget.Serve(pty); err != nil {
+				log.Printf("Stopped serving %s: %s", pty.Name, err)
+			}
+		}()
+		log.Printf("Emulating widget with serial number %d on %s", conf.SerialNumber, pty.Name)
+		if conf.IsMk2() {
+			log.Printf("Mk2 API key %X, labels %+v", conf.APIKey, conf.Mk2Labels)
+		}
+		widgets = append(widgets, widget)
+		ptys = append(ptys, pty)
+	}
+	if *count > 1 {
+		emulator.Link(widgets...)
+	}
+	for i := 0; i < *fixtures; i++ {
+		conf := emulator.DefaultFixtureConfig(rdm.NewUID(0x7A70, uint32(i+1)))
+		conf.Footprint = 1 << (i % 5)
+		widgets[0].AttachRDMResponder(emulator.NewFixture(conf))
+		log.Printf("Emulating RDM fixture %v with footprint %d", conf.UID, conf.Footprint)
+	}
+
+	c := make(chan os.Signal, 1)
+	signal.Notify(c,
+		// https://www.gnu.org/software/libc/manual/html_node/Termination-Signals.html
+		syscall.SIGTERM, // "the normal way to politely ask a program to terminate"
+		syscall.SIGINT,  // Ctrl+C
+		syscall.SIGQUIT, // Ctrl-\
+		syscall.SIGHUP,  // "terminal is disconnected"
+	)
+	<-c
+	log.Printf("Stopping...")
+	for _, pty := range ptys {
+		pty.Close()
+	}
+	log.Printf("Finished.")
+}
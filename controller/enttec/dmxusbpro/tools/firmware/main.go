@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/H3rby7/usbdmx-golang/controller/enttec/dmxusbpro"
+	"github.com/tarm/serial"
+)
+
+func main() {
+	baud := flag.Int("baud", 57600, "Baudrate for the device")
+	name := flag.String("name", "", "Interface of the widget to update (e.g. COM4 OR /dev/ttyUSB0)")
+	file := flag.String("file", "", "Firmware binary to program")
+	flag.Parse()
+
+	firmware, err := os.ReadFile(*file)
+	if err != nil {
+		log.Fatalf("Failed to read firmware: %s", err)
+	}
+
+	// Create a controller and connect to it
+	controller := dmxusbpro.NewEnttecDMXUSBProController(&serial.Config{Name: *name, Baud: *baud}, 0, true)
+	if err := controller.Connect(); err != nil {
+		log.Fatalf("Failed to connect DMX Controller: %s", err)
+	}
+	defer controller.Disconnect()
+	if params, err := controller.GetWidgetParameters(); err == nil {
+		log.Printf("Current firmware is %s", params.GetFirmwareVersionString())
+	}
+
+	// Stop between two pages on cancel
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	defer stop()
+
+	progress := make(chan dmxusbpro.FirmwareProgress, 8)
+	done := make(chan struct{})
+	go func() {
+		for p := range progress {
+			log.Printf("Programmed page %d of %d", p.Page, p.Pages)
+		}
+		close(done)
+	}()
+	log.Printf("Programming %d bytes from %s", len(firmware), *file)
+	err = controller.UpdateFirmware(ctx, firmware, progress)
+	close(progress)
+	<-done
+	if err != nil {
+		log.Fatalf("Failed to update firmware: %s", err)
+	}
+	log.Printf("Finished.")
+}
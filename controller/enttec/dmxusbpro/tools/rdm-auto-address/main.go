@@ -0,0 +1,89 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+
+	"github.com/H3rby7/usbdmx-golang/controller/enttec/dmxusbpro"
+	"github.com/H3rby7/usbdmx-golang/rdm"
+	"github.com/tarm/serial"
+)
+
+func main() {
+	baud := flag.Int("baud", 57600, "Baudrate for the device")
+	name := flag.String("name", "", "Interface of the widget (e.g. COM4 OR /dev/ttyUSB0)")
+	patchFile := flag.String("patch", "", "Manual patch to keep, one 'UID start-address' per line (e.g. 454E:00000001 17)")
+	dryRun := flag.Bool("dry-run", false, "Only print the plan, do not set any start address")
+	flag.Parse()
+
+	manual := make(map[rdm.UID]uint16)
+	if *patchFile != "" {
+		f, err := os.Open(*patchFile)
+		if err != nil {
+			log.Fatalf("Failed to open manual patch: %s", err)
+		}
+		manual, err = rdm.ParseManualPatch(f)
+		f.Close()
+		if err != nil {
+			log.Fatalf("Failed to read manual patch: %s", err)
+		}
+	}
+
+	// Create a controller and connect to it
+	controller := dmxusbpro.NewEnttecDMXUSBProController(&serial.Config{Name: *name, Baud: *baud}, 0, true)
+	if err := controller.Connect(); err != nil {
+		log.Fatalf("Failed to connect DMX Controller: %s", err)
+	}
+	defer controller.Disconnect()
+
+	uids, err := controller.DiscoverRDM()
+	if err != nil {
+		log.Fatalf("Failed to discover RDM responders: %s", err)
+	}
+	log.Printf("Discovered %d RDM responders", len(uids))
+
+	failed := false
+	responders := make([]rdm.Patch, 0, len(uids))
+	current := make(map[rdm.UID]uint16)
+	for _, uid := range uids {
+		info, err := controller.GetRDMDeviceInfo(uid)
+		if err != nil {
+			log.Printf("Skipping %v, failed to read device info: %s", uid, err)
+			failed = true
+			continue
+		}
+		log.Printf("Found %v \tfootprint=%d \tstart-address=%d", uid, info.Footprint, info.StartAddress)
+		responders = append(responders, rdm.Patch{UID: uid, Footprint: info.Footprint})
+		current[uid] = info.StartAddress
+	}
+
+	plan := rdm.PlanAddresses(responders, manual)
+	for _, conflict := range plan.Conflicts {
+		log.Printf("Conflict %s", conflict)
+		failed = true
+	}
+	for _, p := range plan.Patches {
+		log.Printf("Plan %v \tchannels=%d-%d \twas=%d", p.UID, p.StartAddress, p.GetEndAddress(), current[p.UID])
+	}
+	if *dryRun {
+		log.Printf("Dry run, no start address was set.")
+	} else {
+		for _, p := range plan.Patches {
+			if current[p.UID] == p.StartAddress {
+				continue
+			}
+			if err := controller.SetRDMStartAddress(p.UID, p.StartAddress); err != nil {
+				log.Printf("Failed to set start address of %v: %s", p.UID, err)
+				failed = true
+				continue
+			}
+			log.Printf("Set start address of %v to %d", p.UID, p.StartAddress)
+		}
+	}
+	if failed {
+		controller.Disconnect()
+		log.Fatalf("Finished with conflicts or failures.")
+	}
+	log.Printf("Finished.")
+}
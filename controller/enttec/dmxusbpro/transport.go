@@ -0,0 +1,125 @@
+package dmxusbpro
+
+import (
+	"io"
+	"time"
+
+	"github.com/tarm/serial"
+)
+
+// Read timeout used for serial ports, unless configured otherwise
+const DEFAULT_SERIAL_READ_TIMEOUT = 100 * time.Millisecond
+
+/*
+Connection used to exchange raw bytes with the widget, e.g. a serial port
+
+Reads may return 0 bytes without an error, when no data arrived within a read timeout.
+*/
+type Transport interface {
+	io.ReadWriteCloser
+}
+
+// Creates a new Transport every time the controller connects
+type TransportOpener interface {
+	// Open a new Transport to the widget
+	Open() (Transport, error)
+	// Returns the name of the device behind the Transport, e.g. "COM4" or "/dev/tty.usbserial"
+	GetName() string
+}
+
+// Opens serial ports using 'github.com/tarm/serial', this is the default Transport
+type SerialOpener struct {
+	conf *serial.Config
+}
+
+// Helper function for creating a new SerialOpener
+func NewSerialOpener(conf *serial.Config) *SerialOpener {
+	return &SerialOpener{conf: conf}
+}
+
+/*
+Open the serial port described by the configuration.
+
+Uses 'DEFAULT_SERIAL_READ_TIMEOUT' if no 'ReadTimeout' is configured, as a pending read can neither be cancelled nor closed.
+The port counts as in use until closed, so 'SerialNumberOpener' does not probe it.
+*/
+func (o *SerialOpener) Open() (Transport, error) {
+	transport, err := openSerial(*o.conf)
+	if err != nil {
+		return nil, err
+	}
+	return &claimedTransport{Transport: transport, release: claimPort(o.conf.Name)}, nil
+}
+
+// Open the serial port, without marking it as in use
+func openSerial(conf serial.Config) (Transport, error) {
+	if conf.ReadTimeout <= 0 {
+		conf.ReadTimeout = DEFAULT_SERIAL_READ_TIMEOUT
+	}
+	port, err := serial.OpenPort(&conf)
+	if err != nil {
+		return nil, err
+	}
+	return &serialTransport{port: port, readTimeout: conf.ReadTimeout}, nil
+}
+
+// Returns the name of the serial port
+func (o *SerialOpener) GetName() string {
+	return o.conf.Name
+}
+
+// Serial port with read timeout
+type serialTransport struct {
+	port        *serial.Port
+	readTimeout time.Duration
+}
+
+/*
+Read from the serial port.
+
+The port signals both the read timeout and a hang up (e.g. unplugging the device) with 'io.EOF'.
+Only a hang up returns early, so 'io.EOF' is only returned if it did.
+*/
+func (t *serialTransport) Read(buf []byte) (int, error) {
+	start := time.Now()
+	n, err := t.port.Read(buf)
+	if n == 0 && err == io.EOF && time.Since(start) >= t.readTimeout/2 {
+		return 0, nil
+	}
+	return n, err
+}
+
+func (t *serialTransport) Write(buf []byte) (int, error) {
+	return t.port.Write(buf)
+}
+
+func (t *serialTransport) Close() error {
+	return t.port.Close()
+}
+
+// Adapter to use an ordinary function as TransportOpener
+type transportOpenerFunc struct {
+	name string
+	open func() (Transport, error)
+}
+
+/*
+Helper function for creating a TransportOpener from a function.
+
+Example useage:
+
+	opener := NewTransportOpener("widget.local:2000", func() (Transport, error) {
+		return net.Dial("tcp", "widget.local:2000")
+	})
+*/
+func NewTransportOpener(name string, open func() (Transport, error)) TransportOpener {
+	return &transportOpenerFunc{name: name, open: open}
+}
+
+func (o *transportOpenerFunc) Open() (Transport, error) {
+	return o.open()
+}
+
+func (o *transportOpenerFunc) GetName() string {
+	return o.name
+}
@@ -0,0 +1,197 @@
+package dmxusbpro
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/H3rby7/usbdmx-golang/controller/enttec/dmxusbpro/messages"
+)
+
+// Number of bytes of a DMX universe, the start code followed by 512 channels
+const UNIVERSE_SIZE = 513
+
+/*
+Mirror of a received DMX universe, built from 'Received DMX Packet's (label 5) and 'Received DMX Change Of State Packet's (label 9).
+
+Index 0 holds the start code, index 1 to 512 the channels.
+
+Example useage:
+
+	universe := dmxusbpro.NewUniverse()
+	go controller.OnDMXChangeContext(ctx, c, errs)
+	for msg := range c {
+		universe.Apply(msg)
+	}
+*/
+type Universe struct {
+	// Serialises applying and notifying, so subscribers see changes in the order they were applied.
+	// Taken before 'mu', which is released while notifying so unsubscribing can end a pending send.
+	notifyMu sync.Mutex
+	mu       sync.Mutex
+	channels [UNIVERSE_SIZE]byte
+	// Notified of changed channels
+	subscribers []*subscriber
+}
+
+// Channel notified of changed channels, until 'done' is closed by unsubscribing
+type subscriber struct {
+	c    chan<- map[int]byte
+	done chan struct{}
+}
+
+// Helper function for creating a new Universe, all channels at 0
+func NewUniverse() *Universe {
+	return &Universe{subscribers: make([]*subscriber, 0)}
+}
+
+/*
+Apply a received message, label 5 or label 9.
+
+Packets with receive errors or a start code other than 0 are refused, the mirror stays unchanged.
+*/
+func (u *Universe) Apply(msg messages.EnttecDMXUSBProApplicationMessage) error {
+	switch msg.GetLabel() {
+	case messages.LABEL_RECEIVED_DMX_CHANGE_OF_STATE_PACKET:
+		cs, err := messages.ToChangeSet(msg)
+		if err != nil {
+			return err
+		}
+		return u.ApplyChangeSet(cs)
+	case messages.LABEL_RECEIVED_DMX_PACKET:
+		arr, err := messages.ToDMXArray(msg)
+		if err != nil {
+			return err
+		}
+		u.ApplyFrame(arr)
+		return nil
+	}
+	return fmt.Errorf(ENTTEC_DMX_USB_PRO_LOG_PREFIX+": cannot apply label %d to a universe", msg.GetLabel())
+}
+
+// Apply changes as returned by 'messages.ToChangeSet', keys are indices into the universe
+func (u *Universe) ApplyChangeSet(cs map[int]byte) error {
+	for index := range cs {
+		if index < 0 || index >= UNIVERSE_SIZE {
+			return fmt.Errorf(ENTTEC_DMX_USB_PRO_LOG_PREFIX+": change set index must be within [0..%d], but was %d", UNIVERSE_SIZE-1, index)
+		}
+	}
+	u.notifyMu.Lock()
+	defer u.notifyMu.Unlock()
+	u.mu.Lock()
+	changed := make(map[int]byte)
+	for index, value := range cs {
+		if u.channels[index] != value {
+			u.channels[index] = value
+			changed[index] = value
+		}
+	}
+	subscribers := u.subscribers
+	u.mu.Unlock()
+	u.notify(subscribers, changed)
+	return nil
+}
+
+/*
+Apply a frame beginning with the start code, as returned by 'messages.ToDMXArray'.
+
+Channels beyond the end of a short frame stay unchanged.
+*/
+func (u *Universe) ApplyFrame(frame []byte) {
+	if len(frame) > UNIVERSE_SIZE {
+		frame = frame[:UNIVERSE_SIZE]
+	}
+	u.notifyMu.Lock()
+	defer u.notifyMu.Unlock()
+	u.mu.Lock()
+	changed := make(map[int]byte)
+	for index, value := range frame {
+		if u.channels[index] != value {
+			u.channels[index] = value
+			changed[index] = value
+		}
+	}
+	subscribers := u.subscribers
+	u.mu.Unlock()
+	u.notify(subscribers, changed)
+}
+
+// Returns a copy of the universe, index 0 holds the start code
+func (u *Universe) Snapshot() []byte {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	snapshot := make([]byte, UNIVERSE_SIZE)
+	copy(snapshot, u.channels[:])
+	return snapshot
+}
+
+// Returns the value of a channel, within [1..512]
+func (u *Universe) Get(channel int) (byte, error) {
+	if channel < 1 || channel >= UNIVERSE_SIZE {
+		return 0, fmt.Errorf(ENTTEC_DMX_USB_PRO_LOG_PREFIX+": channel must be within [1..%d], but was %d", UNIVERSE_SIZE-1, channel)
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.channels[channel], nil
+}
+
+/*
+Receive the changed channels (index to new value) whenever applying changes something.
+
+Changes arrive in the order they were applied, also when applying from several routines.
+Sending blocks applying further changes, so keep reading until calling the returned function to unsubscribe.
+Unsubscribing also ends a pending send. The channel is not closed.
+
+Example useage:
+
+	changes := make(chan map[int]byte, 8)
+	unsubscribe := universe.Subscribe(changes)
+	defer unsubscribe()
+	for {
+		select {
+		case changed := <-changes: ...
+		case <-ctx.Done():
+			return
+		}
+	}
+*/
+func (u *Universe) Subscribe(c chan<- map[int]byte) (unsubscribe func()) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	sub := &subscriber{c: c, done: make(chan struct{})}
+	// Copy on write, as notifying works on the old list without holding the lock
+	u.subscribers = append(u.subscribers[:len(u.subscribers):len(u.subscribers)], sub)
+	return func() {
+		u.mu.Lock()
+		defer u.mu.Unlock()
+		for i, s := range u.subscribers {
+			if s == sub {
+				u.subscribers = append(u.subscribers[:i:i], u.subscribers[i+1:]...)
+				close(sub.done)
+				return
+			}
+		}
+	}
+}
+
+func (u *Universe) notify(subscribers []*subscriber, changed map[int]byte) {
+	if len(changed) == 0 {
+		return
+	}
+	for _, s := range subscribers {
+		// Unsubscribed since the list was copied
+		select {
+		case <-s.done:
+			continue
+		default:
+		}
+		// Each subscriber gets its own copy to modify
+		c := make(map[int]byte, len(changed))
+		for index, value := range changed {
+			c[index] = value
+		}
+		select {
+		case s.c <- c:
+		case <-s.done:
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package dmxusbpro
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/H3rby7/usbdmx-golang/controller/enttec/dmxusbpro/emulator"
+	"github.com/H3rby7/usbdmx-golang/controller/enttec/dmxusbpro/messages"
+)
+
+func TestUniverseApply(t *testing.T) {
+	u := NewUniverse()
+	changes := make(chan map[int]byte, 8)
+	unsubscribe := u.Subscribe(changes)
+	frame := messages.NewEnttecDMXUSBProApplicationMessage(messages.LABEL_RECEIVED_DMX_PACKET, []byte{0, 0, 10, 20, 30})
+	if err := u.Apply(frame); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if changed := <-changes; len(changed) != 3 || changed[1] != 10 || changed[3] != 30 {
+		t.Errorf("expected channels 1 to 3 to change, but got %v", changed)
+	}
+	// Change of state of channel 2 (bit 2 of the first block)
+	cos := messages.NewEnttecDMXUSBProApplicationMessage(messages.LABEL_RECEIVED_DMX_CHANGE_OF_STATE_PACKET, []byte{0, 0x04, 0, 0, 0, 0, 99})
+	if err := u.Apply(cos); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if changed := <-changes; len(changed) != 1 || changed[2] != 99 {
+		t.Errorf("expected channel 2 to change to 99, but got %v", changed)
+	}
+	if value, err := u.Get(2); err != nil || value != 99 {
+		t.Errorf("expected channel 2 to be 99, but got %d and %v", value, err)
+	}
+	snapshot := u.Snapshot()
+	if len(snapshot) != UNIVERSE_SIZE || !bytes.Equal(snapshot[:5], []byte{0, 10, 99, 30, 0}) {
+		t.Errorf("expected snapshot to begin with %v, but was %v", []byte{0, 10, 99, 30, 0}, snapshot[:5])
+	}
+	// Unchanged values are not reported
+	u.ApplyFrame([]byte{0, 10})
+	unsubscribe()
+	u.ApplyFrame([]byte{0, 11})
+	select {
+	case changed := <-changes:
+		t.Errorf("expected no notification, but got %v", changed)
+	default:
+	}
+}
+
+func TestUniverseUnsubscribeEndsPendingNotify(t *testing.T) {
+	u := NewUniverse()
+	changes := make(chan map[int]byte)
+	unsubscribe := u.Subscribe(changes)
+	applied := make(chan struct{})
+	go func() {
+		// Blocks, as nobody reads
+		u.ApplyFrame([]byte{0, 1})
+		close(applied)
+	}()
+	time.Sleep(10 * time.Millisecond)
+	unsubscribe()
+	select {
+	case <-applied:
+	case <-time.After(time.Second):
+		t.Fatalf("expected unsubscribing to end the pending notification")
+	}
+	u.ApplyFrame([]byte{0, 2})
+}
+
+func TestUniverseNotifiesInOrderOfApplying(t *testing.T) {
+	u := NewUniverse()
+	changes := make(chan map[int]byte)
+	unsubscribe := u.Subscribe(changes)
+	defer unsubscribe()
+	// Value of channel 1, built from the notifications
+	mirror := byte(0)
+	for round := 0; round < 500; round++ {
+		var wg sync.WaitGroup
+		for r := 0; r < 2; r++ {
+			wg.Add(1)
+			go func(value byte) {
+				defer wg.Done()
+				u.ApplyChangeSet(map[int]byte{1: value})
+			}(byte(2*round%250 + r + 1))
+		}
+		done := make(chan struct{})
+		go func() {
+			wg.Wait()
+			close(done)
+		}()
+		for applying := true; applying; {
+			select {
+			case changed := <-changes:
+				mirror = changed[1]
+			case <-done:
+				applying = false
+			}
+		}
+		if snapshot := u.Snapshot(); mirror != snapshot[1] {
+			t.Fatalf("expected notified value to match the snapshot %d in round %d, but was %d", snapshot[1], round, mirror)
+		}
+	}
+}
+
+func TestUniverseRefuses(t *testing.T) {
+	u := NewUniverse()
+	rdmPacket := messages.NewEnttecDMXUSBProApplicationMessage(messages.LABEL_RECEIVED_DMX_PACKET, []byte{0, messages.RDM_START_CODE, 1})
+	if err := u.Apply(rdmPacket); err == nil {
+		t.Errorf("expected an error for an RDM packet")
+	}
+	if err := u.ApplyChangeSet(map[int]byte{UNIVERSE_SIZE: 1}); err == nil {
+		t.Errorf("expected an error for index %d", UNIVERSE_SIZE)
+	}
+	for _, channel := range []int{0, UNIVERSE_SIZE} {
+		if _, err := u.Get(channel); err == nil {
+			t.Errorf("expected an error for channel %d", channel)
+		}
+	}
+	if snapshot := u.Snapshot(); !bytes.Equal(snapshot, make([]byte, UNIVERSE_SIZE)) {
+		t.Errorf("expected the universe to stay unchanged")
+	}
+}
+
+func TestUniverseFromEmulator(t *testing.T) {
+	writerWidget := emulator.NewWidget(emulator.DefaultConfig())
+	readerWidget := emulator.NewWidget(emulator.DefaultConfig())
+	emulator.Link(writerWidget, readerWidget)
+	host, device := emulator.NewPipe()
+	go writerWidget.Serve(device)
+	writer := newFakeController(t, host, true)
+	t.Cleanup(func() { writer.Disconnect() })
+	host, device = emulator.NewPipe()
+	go readerWidget.Serve(device)
+	reader := newFakeController(t, host, false)
+	t.Cleanup(func() { reader.Disconnect() })
+
+	reader.SwitchReadMode(1)
+	// Replies once serving, having switched the read mode
+	if _, err := reader.GetSerialNumber(); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := make(chan messages.EnttecDMXUSBProApplicationMessage, 8)
+	go reader.OnDMXChangeContext(ctx, c, nil)
+	u := NewUniverse()
+	go func() {
+		for msg := range c {
+			u.Apply(msg)
+		}
+	}()
+	writer.Stage(1, 255)
+	writer.Stage(3, 42)
+	writer.Commit()
+	for start := time.Now(); ; time.Sleep(time.Millisecond) {
+		first, _ := u.Get(1)
+		third, _ := u.Get(3)
+		if first == 255 && third == 42 {
+			break
+		}
+		if time.Since(start) > time.Second {
+			t.Fatalf("expected channels 1 and 3 to be mirrored, but were %d and %d", first, third)
+		}
+	}
+	if value, _ := u.Get(2); value != 0 {
+		t.Errorf("expected channel 2 to be 0, but was %d", value)
+	}
+}
@@ -0,0 +1,358 @@
+/*
+Controller for the Enttec Open DMX USB widget.
+
+The widget is a bare FTDI chip without microcontroller, the host generates the DMX signal:
+break, mark after break (MAB) and the frame at 250 kbaud 8N2, repeated continuously by a refresh routine.
+*/
+package opendmx
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// Prefix for any log and error messages
+const OPEN_DMX_LOG_PREFIX = "ODMX"
+
+// Baud rate of DMX
+const DMX_BAUD_RATE = 250000
+
+// Baud rate to send the break as a zero byte, 9 bits low (90µs break) followed by 2 stop bits (20µs MAB)
+const BREAK_BAUD_RATE = 100000
+
+// Start code of DMX frames
+const DMX_START_CODE = 0
+
+// Highest number of DMX channels
+const MAXIMUM_CHANNEL_COUNT = 512
+
+const (
+	// Shortest break allowed by DMX
+	MINIMUM_BREAK_TIME = 88 * time.Microsecond
+	// Shortest mark after break allowed by DMX
+	MINIMUM_MAB_TIME = 8 * time.Microsecond
+	// Highest refresh rate, sending 512 channels takes about 23ms
+	MAXIMUM_REFRESH_RATE = 44
+)
+
+// Timing of the DMX signal generated by the host
+type Timing struct {
+	// Duration of the break, only used by a 'BreakTransport'
+	BreakTime time.Duration
+	// Duration of the mark after break, only used by a 'BreakTransport'
+	MABTime time.Duration
+	// Frames sent per second
+	RefreshRate int
+}
+
+// Returns a timing with some margin to the DMX minimums, refreshing 40 times per second
+func DefaultTiming() Timing {
+	return Timing{
+		BreakTime:   110 * time.Microsecond,
+		MABTime:     16 * time.Microsecond,
+		RefreshRate: 40,
+	}
+}
+
+// Controller for the Enttec Open DMX USB widget
+type OpenDMXController struct {
+	// Guards everything below, but the transport itself
+	mu sync.Mutex
+	// Staged values, as DMX starts with channel '1' the index '0' holds the start code.
+	channels []byte
+	// Committed values, sent by the refresh routine
+	frame  []byte
+	opener TransportOpener
+	port   Transport
+	// Is the controller connected
+	isConnected bool
+	timing      Timing
+	// Closed to stop the refresh routine, which closes 'stopped' when returning
+	stop    chan struct{}
+	stopped chan struct{}
+	// Reason the refresh routine stopped on its own
+	refreshErr error
+
+	// Guards writing to the transport, so raw writes do not interrupt a frame
+	writeMu      sync.Mutex
+	logVerbosity uint8
+}
+
+/*
+Helper function for creating a new Open DMX USB controller using the serial port of the widget.
+
+Example useage:
+
+	controller := opendmx.NewOpenDMXController("/dev/ttyUSB0", 512)
+*/
+func NewOpenDMXController(name string, dmxChannelCount int) *OpenDMXController {
+	return NewOpenDMXControllerWithTransport(NewSerialOpener(name), dmxChannelCount)
+}
+
+// Helper function for creating a new Open DMX USB controller using any Transport
+func NewOpenDMXControllerWithTransport(opener TransportOpener, dmxChannelCount int) *OpenDMXController {
+	if dmxChannelCount < 1 || dmxChannelCount > MAXIMUM_CHANNEL_COUNT {
+		log.Panicf(OPEN_DMX_LOG_PREFIX+": channel count must be between 1 and %d, but was %d", MAXIMUM_CHANNEL_COUNT, dmxChannelCount)
+	}
+	d := &OpenDMXController{}
+	d.channels = make([]byte, dmxChannelCount+1)
+	d.frame = make([]byte, dmxChannelCount+1)
+	d.opener = opener
+	d.timing = DefaultTiming()
+	return d
+}
+
+/*
+Returns the name used for opening the connection
+
+e.g. "/dev/ttyUSB0"
+*/
+func (d *OpenDMXController) GetName() string {
+	return d.opener.GetName()
+}
+
+/*
+Change the timing of the DMX signal, takes effect when connecting.
+
+Returns an error if the timing violates DMX.
+*/
+func (d *OpenDMXController) SetTiming(timing Timing) error {
+	if timing.BreakTime < MINIMUM_BREAK_TIME {
+		return errorf("break time must be at least %v, but was %v", MINIMUM_BREAK_TIME, timing.BreakTime)
+	}
+	if timing.MABTime < MINIMUM_MAB_TIME {
+		return errorf("MAB time must be at least %v, but was %v", MINIMUM_MAB_TIME, timing.MABTime)
+	}
+	if timing.RefreshRate < 1 || timing.RefreshRate > MAXIMUM_REFRESH_RATE {
+		return errorf("refresh rate must be between 1 and %d, but was %d", MAXIMUM_REFRESH_RATE, timing.RefreshRate)
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.timing = timing
+	return nil
+}
+
+// Returns the timing of the DMX signal
+func (d *OpenDMXController) GetTiming() Timing {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.timing
+}
+
+/*
+	Open the connection to the widget and start refreshing the committed frame
+
+Succeeded if no error is returned
+*/
+func (d *OpenDMXController) Connect() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.isConnected {
+		return errorf("already connected.")
+	}
+	port, err := d.opener.Open()
+	if err != nil {
+		return err
+	}
+	switch port.(type) {
+	case BreakTransport, BaudSwitchTransport:
+	default:
+		port.Close()
+		return errorf("transport of %s can neither send a break nor switch its baud rate", d.opener.GetName())
+	}
+	d.port = port
+	d.isConnected = true
+	d.refreshErr = nil
+	d.stop = make(chan struct{})
+	d.stopped = make(chan struct{})
+	go d.refresh(port, d.timing, d.stop, d.stopped)
+	return nil
+}
+
+/*
+	Stop refreshing and close the connection to the widget
+
+Succeeded if no error is returned
+*/
+func (d *OpenDMXController) Disconnect() error {
+	d.mu.Lock()
+	if !d.isConnected {
+		d.mu.Unlock()
+		return errorf("not connected.")
+	}
+	d.isConnected = false
+	port := d.port
+	stopped := d.stopped
+	close(d.stop)
+	d.mu.Unlock()
+	<-stopped
+	return port.Close()
+}
+
+// Send the committed frame at the refresh rate, until stopped or sending fails
+func (d *OpenDMXController) refresh(port Transport, timing Timing, stop chan struct{}, stopped chan struct{}) {
+	defer close(stopped)
+	ticker := time.NewTicker(time.Second / time.Duration(timing.RefreshRate))
+	defer ticker.Stop()
+	for {
+		d.mu.Lock()
+		frame := append([]byte{}, d.frame...)
+		d.mu.Unlock()
+		if err := d.sendFrame(port, timing, frame); err != nil {
+			d.printf(1, "Stopped refreshing: %v", err)
+			d.mu.Lock()
+			d.refreshErr = errorf("stopped refreshing, %v", err)
+			d.mu.Unlock()
+			return
+		}
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+	}
+}
+
+// Send break, mark after break and the frame beginning with the start code
+func (d *OpenDMXController) sendFrame(port Transport, timing Timing, frame []byte) error {
+	d.writeMu.Lock()
+	defer d.writeMu.Unlock()
+	switch t := port.(type) {
+	case BreakTransport:
+		if err := t.SetBreak(true); err != nil {
+			return err
+		}
+		time.Sleep(timing.BreakTime)
+		if err := t.SetBreak(false); err != nil {
+			return err
+		}
+		time.Sleep(timing.MABTime)
+	case BaudSwitchTransport:
+		if err := t.SetBaudRate(BREAK_BAUD_RATE); err != nil {
+			return err
+		}
+		if _, err := t.Write([]byte{0}); err != nil {
+			return err
+		}
+		if err := t.SetBaudRate(DMX_BAUD_RATE); err != nil {
+			return err
+		}
+	}
+	d.printf(2, "Sending frame:\t%v", frame)
+	_, err := port.Write(frame)
+	return err
+}
+
+// Returns the transport, if connected
+func (d *OpenDMXController) connectedPort() (Transport, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.port, d.isConnected
+}
+
+// The widget can not receive DMX, always returns an error
+func (d *OpenDMXController) Read(buf []byte) (int, error) {
+	return -1, errorf("the Open DMX USB can not receive DMX")
+}
+
+/*
+Expose transport write to be used directly
+
+Waits for the frame being sent, the next frame is sent at the refresh rate regardless.
+*/
+func (d *OpenDMXController) Write(buf []byte) (int, error) {
+	port, ok := d.connectedPort()
+	if !ok {
+		return -1, errorf("not connected")
+	}
+	d.writeMu.Lock()
+	defer d.writeMu.Unlock()
+	n, err := port.Write(buf)
+	d.printf(2, "Wrote %d bytes:\t%v", n, buf[0:n])
+	return n, err
+}
+
+// Gets a copy of all staged channel values, index '0' holds the start code
+func (d *OpenDMXController) GetStage() []byte {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	channels := make([]byte, len(d.channels))
+	copy(channels, d.channels)
+	return channels
+}
+
+/*
+Prepare a channel to be changed to the given value
+
+Note: This does not send out the changes, you must call the 'Commit' method to apply the stage live.
+*/
+func (d *OpenDMXController) Stage(channel int16, value byte) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	highestChannel := int16(len(d.channels) - 1)
+	if channel < 1 || channel > highestChannel {
+		return errorf("index %d out of range, must be between 1 and %d", channel, highestChannel)
+	}
+	d.channels[channel] = value
+	return nil
+}
+
+/*
+Apply the 'staged' values to go live, with the next frame sent by the refresh routine.
+
+Returns the reason if refreshing stopped, e.g. because the widget was unplugged.
+
+Note: This does not clear the Stage!
+*/
+func (d *OpenDMXController) Commit() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.refreshErr != nil {
+		return d.refreshErr
+	}
+	copy(d.frame, d.channels)
+	d.frame[0] = DMX_START_CODE
+	return nil
+}
+
+// Set all values of the staged channels to '0'
+func (d *OpenDMXController) ClearStage() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for i := range d.channels {
+		d.channels[i] = 0
+	}
+}
+
+/*
+Set log verbosity
+
+0 = no logging
+
+1 = message logging
+
+2 = byte logging
+*/
+func (d *OpenDMXController) SetLogVerbosity(verbosity uint8) {
+	if verbosity > 2 {
+		log.Panicf(OPEN_DMX_LOG_PREFIX+": invalid value, only 0, 1 and 2 are allowed, but got '%d'", verbosity)
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.logVerbosity = verbosity
+}
+
+func (d *OpenDMXController) printf(level uint8, format string, v ...any) {
+	d.mu.Lock()
+	verbosity := d.logVerbosity
+	d.mu.Unlock()
+	if verbosity == level {
+		log.Printf(OPEN_DMX_LOG_PREFIX+" "+d.GetName()+": "+format, v...)
+	}
+}
+
+func errorf(format string, v ...any) error {
+	return fmt.Errorf(OPEN_DMX_LOG_PREFIX+": "+format, v...)
+}
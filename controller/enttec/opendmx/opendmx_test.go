@@ -0,0 +1,258 @@
+package opendmx
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	usbdmxgolang "github.com/H3rby7/usbdmx-golang"
+)
+
+var _ usbdmxgolang.DMXController = (*OpenDMXController)(nil)
+
+// Something done to the mock transport, at the time it was done
+type event struct {
+	at   time.Time
+	kind string
+	data []byte
+}
+
+// Transport recording breaks and writes
+type mockTransport struct {
+	mu       sync.Mutex
+	events   []event
+	writeErr error
+	closed   bool
+}
+
+func (m *mockTransport) record(kind string, data []byte) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.events = append(m.events, event{at: time.Now(), kind: kind, data: append([]byte{}, data...)})
+}
+
+func (m *mockTransport) getEvents() []event {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]event{}, m.events...)
+}
+
+func (m *mockTransport) Write(buf []byte) (int, error) {
+	m.mu.Lock()
+	err := m.writeErr
+	m.mu.Unlock()
+	if err != nil {
+		return 0, err
+	}
+	m.record("write", buf)
+	return len(buf), nil
+}
+
+func (m *mockTransport) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.closed = true
+	return nil
+}
+
+// Mock transport holding the line low
+type mockBreakTransport struct {
+	mockTransport
+}
+
+func (m *mockBreakTransport) SetBreak(on bool) error {
+	m.record(fmt.Sprintf("break=%v", on), nil)
+	return nil
+}
+
+// Mock transport switching its baud rate
+type mockBaudSwitchTransport struct {
+	mockTransport
+}
+
+func (m *mockBaudSwitchTransport) SetBaudRate(baud int) error {
+	m.record(fmt.Sprintf("baud=%d", baud), nil)
+	return nil
+}
+
+func newMockController(t *testing.T, transport Transport, timing Timing) *OpenDMXController {
+	d := NewOpenDMXControllerWithTransport(NewTransportOpener("mock", func() (Transport, error) {
+		return transport, nil
+	}), 3)
+	if err := d.SetTiming(timing); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	return d
+}
+
+func connect(t *testing.T, d *OpenDMXController) {
+	if err := d.Connect(); err != nil {
+		t.Fatalf("expected no error on connect, but got %v", err)
+	}
+}
+
+// Returns the events of the frames sent, skipping the frames sent before the given time
+func framesSince(events []event, since time.Time) [][]event {
+	frames := make([][]event, 0)
+	var frame []event
+	for _, e := range events {
+		if e.at.Before(since) {
+			continue
+		}
+		frame = append(frame, e)
+		if e.kind == "write" {
+			frames = append(frames, frame)
+			frame = nil
+		}
+	}
+	return frames
+}
+
+func TestRefreshSendsBreakMABAndFrame(t *testing.T) {
+	transport := &mockBreakTransport{}
+	timing := Timing{BreakTime: 200 * time.Microsecond, MABTime: 50 * time.Microsecond, RefreshRate: 40}
+	d := newMockController(t, transport, timing)
+	d.Stage(1, 11)
+	d.Stage(3, 33)
+	d.Commit()
+	start := time.Now()
+	connect(t, d)
+	time.Sleep(100 * time.Millisecond)
+	d.Disconnect()
+
+	frames := framesSince(transport.getEvents(), start)
+	if len(frames) < 2 {
+		t.Fatalf("expected at least 2 frames, but got %d", len(frames))
+	}
+	for i, frame := range frames {
+		if len(frame) != 3 || frame[0].kind != "break=true" || frame[1].kind != "break=false" {
+			t.Fatalf("expected frame %d to be break on, break off and write, but was %v", i, frame)
+		}
+		if breakTime := frame[1].at.Sub(frame[0].at); breakTime < timing.BreakTime {
+			t.Errorf("expected break of frame %d to last at least %v, but was %v", i, timing.BreakTime, breakTime)
+		}
+		if mabTime := frame[2].at.Sub(frame[1].at); mabTime < timing.MABTime {
+			t.Errorf("expected MAB of frame %d to last at least %v, but was %v", i, timing.MABTime, mabTime)
+		}
+		if !bytes.Equal(frame[2].data, []byte{DMX_START_CODE, 11, 0, 33}) {
+			t.Errorf("expected frame %d to be %v, but was %v", i, []byte{DMX_START_CODE, 11, 0, 33}, frame[2].data)
+		}
+	}
+	if !transport.closed {
+		t.Errorf("expected transport to be closed")
+	}
+}
+
+func TestRefreshRate(t *testing.T) {
+	transport := &mockBreakTransport{}
+	timing := DefaultTiming()
+	timing.RefreshRate = 20
+	d := newMockController(t, transport, timing)
+	start := time.Now()
+	connect(t, d)
+	time.Sleep(500 * time.Millisecond)
+	d.Disconnect()
+	frames := framesSince(transport.getEvents(), start)
+	// 10 frames expected, allowing for scheduling delays
+	if len(frames) < 8 || len(frames) > 12 {
+		t.Errorf("expected about 10 frames within 500ms at 20 Hz, but got %d", len(frames))
+	}
+	for i := 1; i < len(frames); i++ {
+		gap := frames[i][0].at.Sub(frames[i-1][0].at)
+		if gap < 40*time.Millisecond {
+			t.Errorf("expected frames to be 50ms apart, but frame %d followed after %v", i, gap)
+		}
+	}
+}
+
+func TestRefreshUsesBaudSwitch(t *testing.T) {
+	transport := &mockBaudSwitchTransport{}
+	d := newMockController(t, transport, DefaultTiming())
+	d.Stage(2, 22)
+	d.Commit()
+	start := time.Now()
+	connect(t, d)
+	time.Sleep(60 * time.Millisecond)
+	d.Disconnect()
+	frames := framesSince(transport.getEvents(), start)
+	if len(frames) < 2 {
+		t.Fatalf("expected at least 2 writes, but got %d", len(frames))
+	}
+	expected := []string{fmt.Sprintf("baud=%d", BREAK_BAUD_RATE), "write", fmt.Sprintf("baud=%d", DMX_BAUD_RATE), "write"}
+	// The break byte ends the first frame as it is written
+	events := append(frames[0], frames[1]...)
+	for i, kind := range expected {
+		if events[i].kind != kind {
+			t.Errorf("expected event %d to be %s, but was %s", i, kind, events[i].kind)
+		}
+	}
+	if !bytes.Equal(events[1].data, []byte{0}) {
+		t.Errorf("expected the break to be a zero byte, but was %v", events[1].data)
+	}
+	if !bytes.Equal(events[3].data, []byte{DMX_START_CODE, 0, 22, 0}) {
+		t.Errorf("expected frame to be %v, but was %v", []byte{DMX_START_CODE, 0, 22, 0}, events[3].data)
+	}
+}
+
+func TestConnectRequiresBreakCapability(t *testing.T) {
+	transport := &mockTransport{}
+	d := NewOpenDMXControllerWithTransport(NewTransportOpener("mock", func() (Transport, error) {
+		return transport, nil
+	}), 3)
+	if err := d.Connect(); err == nil {
+		t.Errorf("expected error, as the transport can not send a break")
+	}
+	if !transport.closed {
+		t.Errorf("expected transport to be closed")
+	}
+}
+
+func TestCommitReportsStoppedRefresh(t *testing.T) {
+	transport := &mockBreakTransport{}
+	d := newMockController(t, transport, DefaultTiming())
+	connect(t, d)
+	transport.mu.Lock()
+	transport.writeErr = fmt.Errorf("unplugged")
+	transport.mu.Unlock()
+	for start := time.Now(); d.Commit() == nil; time.Sleep(time.Millisecond) {
+		if time.Since(start) > time.Second {
+			t.Fatalf("expected commit to fail after refreshing stopped")
+		}
+	}
+	d.Disconnect()
+}
+
+func TestStageOutOfRange(t *testing.T) {
+	d := NewOpenDMXControllerWithTransport(NewTransportOpener("mock", nil), 3)
+	for _, channel := range []int16{0, 4} {
+		if err := d.Stage(channel, 1); err == nil {
+			t.Errorf("expected error staging channel %d", channel)
+		}
+	}
+	if err := d.Stage(3, 1); err != nil {
+		t.Errorf("expected no error, but got %v", err)
+	}
+	if _, err := d.Read(make([]byte, 8)); err == nil {
+		t.Errorf("expected error, as the widget can not receive DMX")
+	}
+}
+
+func TestSetTimingValidates(t *testing.T) {
+	d := NewOpenDMXControllerWithTransport(NewTransportOpener("mock", nil), 3)
+	invalid := []Timing{
+		{BreakTime: 50 * time.Microsecond, MABTime: 16 * time.Microsecond, RefreshRate: 40},
+		{BreakTime: 110 * time.Microsecond, MABTime: 4 * time.Microsecond, RefreshRate: 40},
+		{BreakTime: 110 * time.Microsecond, MABTime: 16 * time.Microsecond, RefreshRate: 0},
+		{BreakTime: 110 * time.Microsecond, MABTime: 16 * time.Microsecond, RefreshRate: 45},
+	}
+	for _, timing := range invalid {
+		if err := d.SetTiming(timing); err == nil {
+			t.Errorf("expected error for timing %+v", timing)
+		}
+	}
+	if d.GetTiming() != DefaultTiming() {
+		t.Errorf("expected timing to stay unchanged")
+	}
+}
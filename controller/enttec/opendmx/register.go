@@ -0,0 +1,45 @@
+package opendmx
+
+import (
+	usbdmxgolang "github.com/H3rby7/usbdmx-golang"
+)
+
+// URI scheme to open the controller with 'usbdmxgolang.Open'
+const DRIVER_SCHEME = "enttec-open"
+
+func init() {
+	usbdmxgolang.Register(DRIVER_SCHEME, openURI)
+}
+
+/*
+Create a controller from a URI like "enttec-open:///dev/ttyUSB0?channels=512&rate=30".
+
+Parameters:
+
+	channels  number of DMX channels, defaults to 512
+	rate      frames sent per second, defaults to 40
+*/
+func openURI(uri usbdmxgolang.ControllerURI) (usbdmxgolang.DMXController, error) {
+	if err := uri.CheckParams("channels", "rate"); err != nil {
+		return nil, err
+	}
+	if uri.Device == "" {
+		return nil, errorf("URI must name a device")
+	}
+	channels, err := uri.GetInt("channels", MAXIMUM_CHANNEL_COUNT)
+	if err != nil {
+		return nil, err
+	}
+	if channels < 1 || channels > MAXIMUM_CHANNEL_COUNT {
+		return nil, errorf("channels must be between 1 and %d, but was %d", MAXIMUM_CHANNEL_COUNT, channels)
+	}
+	timing := DefaultTiming()
+	if timing.RefreshRate, err = uri.GetInt("rate", timing.RefreshRate); err != nil {
+		return nil, err
+	}
+	d := NewOpenDMXController(uri.Device, channels)
+	if err := d.SetTiming(timing); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
@@ -0,0 +1,27 @@
+package opendmx
+
+import (
+	"testing"
+
+	usbdmxgolang "github.com/H3rby7/usbdmx-golang"
+)
+
+func TestOpenURI(t *testing.T) {
+	controller, err := usbdmxgolang.Open("enttec-open:///dev/ttyUSB0?channels=16&rate=30")
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	d, ok := controller.(*OpenDMXController)
+	if !ok {
+		t.Fatalf("expected an OpenDMXController, but got %T", controller)
+	}
+	if d.GetName() != "/dev/ttyUSB0" {
+		t.Errorf("expected name to be '/dev/ttyUSB0', but was '%s'", d.GetName())
+	}
+	if d.GetTiming().RefreshRate != 30 {
+		t.Errorf("expected refresh rate to be 30, but was %d", d.GetTiming().RefreshRate)
+	}
+	if _, err := usbdmxgolang.Open("enttec-open:///dev/ttyUSB0?rate=100"); err == nil {
+		t.Errorf("expected error for a refresh rate violating DMX")
+	}
+}
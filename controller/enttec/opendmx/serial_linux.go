@@ -0,0 +1,99 @@
+//go:build linux && !ppc && !ppc64 && !ppc64le
+
+package opendmx
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// Opens the serial port of the widget's FTDI chip (e.g. "/dev/ttyUSB0" using the 'ftdi_sio' driver), configured for DMX (250 kbaud 8N2)
+type SerialOpener struct {
+	name string
+}
+
+// Helper function for creating a new SerialOpener
+func NewSerialOpener(name string) *SerialOpener {
+	return &SerialOpener{name: name}
+}
+
+// Open the serial port and configure it for DMX
+func (o *SerialOpener) Open() (Transport, error) {
+	f, err := os.OpenFile(o.name, os.O_RDWR|unix.O_NOCTTY, 0)
+	if err != nil {
+		return nil, errorf("could not open %s: %v", o.name, err)
+	}
+	t := &serialTransport{f: f}
+	if err := t.control(func(fd int) error { return configure(fd, DMX_BAUD_RATE) }); err != nil {
+		f.Close()
+		return nil, errorf("could not configure %s: %v", o.name, err)
+	}
+	return t, nil
+}
+
+// Returns the name of the serial port
+func (o *SerialOpener) GetName() string {
+	return o.name
+}
+
+// Serial port able to send breaks and to switch its baud rate
+type serialTransport struct {
+	f *os.File
+}
+
+// Set raw mode with 8 data bits, no parity and 2 stop bits at any baud rate, after pending data was sent
+func configure(fd int, baud int) error {
+	t, err := unix.IoctlGetTermios(fd, unix.TCGETS2)
+	if err != nil {
+		return err
+	}
+	t.Iflag &^= unix.IGNBRK | unix.BRKINT | unix.PARMRK | unix.ISTRIP | unix.INLCR | unix.IGNCR | unix.ICRNL | unix.IXON
+	t.Oflag &^= unix.OPOST
+	t.Lflag &^= unix.ECHO | unix.ECHONL | unix.ICANON | unix.ISIG | unix.IEXTEN
+	t.Cflag &^= unix.CSIZE | unix.PARENB | unix.CBAUD | unix.CRTSCTS
+	t.Cflag |= unix.CS8 | unix.CSTOPB | unix.CLOCAL | unix.BOTHER
+	t.Ispeed = uint32(baud)
+	t.Ospeed = uint32(baud)
+	return unix.IoctlSetTermios(fd, unix.TCSETSW2, t)
+}
+
+func (t *serialTransport) SetBreak(on bool) error {
+	return t.control(func(fd int) error {
+		if !on {
+			return unix.IoctlSetInt(fd, unix.TIOCCBRK, 0)
+		}
+		// Wait for the previous frame to be sent, like tcdrain(3)
+		if err := unix.IoctlSetInt(fd, unix.TCSBRK, 1); err != nil {
+			return err
+		}
+		return unix.IoctlSetInt(fd, unix.TIOCSBRK, 0)
+	})
+}
+
+func (t *serialTransport) SetBaudRate(baud int) error {
+	return t.control(func(fd int) error { return configure(fd, baud) })
+}
+
+func (t *serialTransport) Write(buf []byte) (int, error) {
+	return t.f.Write(buf)
+}
+
+func (t *serialTransport) Close() error {
+	return t.f.Close()
+}
+
+// Run the function with the file descriptor of the serial port
+func (t *serialTransport) control(fn func(fd int) error) error {
+	conn, err := t.f.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var fnErr error
+	if err := conn.Control(func(fd uintptr) {
+		fnErr = fn(int(fd))
+	}); err != nil {
+		return err
+	}
+	return fnErr
+}
@@ -0,0 +1,23 @@
+//go:build !linux || ppc || ppc64 || ppc64le
+
+package opendmx
+
+// Opens the serial port of the widget's FTDI chip, only supported on linux
+type SerialOpener struct {
+	name string
+}
+
+// Helper function for creating a new SerialOpener
+func NewSerialOpener(name string) *SerialOpener {
+	return &SerialOpener{name: name}
+}
+
+// Serial ports able to send breaks are only supported on linux, use 'NewTransportOpener' with an FTDI library instead
+func (o *SerialOpener) Open() (Transport, error) {
+	return nil, errorf("serial ports are only supported on linux, use 'NewTransportOpener' instead")
+}
+
+// Returns the name of the serial port
+func (o *SerialOpener) GetName() string {
+	return o.name
+}
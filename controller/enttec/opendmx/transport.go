@@ -0,0 +1,56 @@
+package opendmx
+
+import (
+	"io"
+)
+
+/*
+Connection used to send raw bytes to the widget, e.g. the serial port of its FTDI chip.
+
+The widget has no microcontroller, so the transport must also be able to create the DMX break,
+either by holding the line low (see 'BreakTransport') or by switching the baud rate (see 'BaudSwitchTransport').
+*/
+type Transport interface {
+	io.WriteCloser
+}
+
+// Transport able to hold the line low, the break is as long as it is held
+type BreakTransport interface {
+	Transport
+	// Hold the line low (true) or release it (false), waiting for pending data to be sent first
+	SetBreak(on bool) error
+}
+
+// Transport able to change its baud rate, the break is sent as a zero byte at 'BREAK_BAUD_RATE'
+type BaudSwitchTransport interface {
+	Transport
+	// Change the baud rate, waiting for pending data to be sent first
+	SetBaudRate(baud int) error
+}
+
+// Creates a new Transport every time the controller connects
+type TransportOpener interface {
+	// Open a new Transport to the widget
+	Open() (Transport, error)
+	// Returns the name of the device behind the Transport, e.g. "/dev/ttyUSB0"
+	GetName() string
+}
+
+// Adapter to use an ordinary function as TransportOpener
+type transportOpenerFunc struct {
+	name string
+	open func() (Transport, error)
+}
+
+// Helper function for creating a TransportOpener from a function
+func NewTransportOpener(name string, open func() (Transport, error)) TransportOpener {
+	return &transportOpenerFunc{name: name, open: open}
+}
+
+func (o *transportOpenerFunc) Open() (Transport, error) {
+	return o.open()
+}
+
+func (o *transportOpenerFunc) GetName() string {
+	return o.name
+}
@@ -0,0 +1,65 @@
+package virtual
+
+import (
+	"io"
+	"os"
+
+	usbdmxgolang "github.com/H3rby7/usbdmx-golang"
+)
+
+// URI scheme to open the controller with 'usbdmxgolang.Open'
+const DRIVER_SCHEME = "virtual"
+
+func init() {
+	usbdmxgolang.Register(DRIVER_SCHEME, openURI)
+}
+
+/*
+Create a controller from a URI like "virtual:desk?channels=512&mode=loopback&print=stdout".
+
+The device is used as name, defaults to "virtual".
+
+Parameters:
+
+	channels  number of DMX channels, defaults to 512
+	mode      'loopback' (default) or 'null'
+	print     'stdout' or 'stderr' to print committed frames
+*/
+func openURI(uri usbdmxgolang.ControllerURI) (usbdmxgolang.DMXController, error) {
+	if err := uri.CheckParams("channels", "mode", "print"); err != nil {
+		return nil, err
+	}
+	channels, err := uri.GetInt("channels", MAXIMUM_CHANNEL_COUNT)
+	if err != nil {
+		return nil, err
+	}
+	if channels < 1 || channels > MAXIMUM_CHANNEL_COUNT {
+		return nil, errorf("channels must be between 1 and %d, but was %d", MAXIMUM_CHANNEL_COUNT, channels)
+	}
+	var mode Mode
+	switch m := uri.GetString("mode", "loopback"); m {
+	case "loopback":
+		mode = MODE_LOOPBACK
+	case "null":
+		mode = MODE_NULL
+	default:
+		return nil, errorf("mode must be 'loopback' or 'null', but was '%s'", m)
+	}
+	var printer io.Writer
+	switch p := uri.GetString("print", ""); p {
+	case "":
+	case "stdout":
+		printer = os.Stdout
+	case "stderr":
+		printer = os.Stderr
+	default:
+		return nil, errorf("print must be 'stdout' or 'stderr', but was '%s'", p)
+	}
+	name := uri.Device
+	if name == "" {
+		name = DRIVER_SCHEME
+	}
+	d := NewVirtualController(name, channels, mode)
+	d.SetPrinter(printer)
+	return d, nil
+}
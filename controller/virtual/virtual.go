@@ -0,0 +1,329 @@
+/*
+In-memory controller for development without hardware.
+
+Implements the full 'usbdmxgolang.DMXController', so show software, examples and tests run the same code paths as with a widget.
+*/
+package virtual
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Prefix for any log and error messages
+const VIRTUAL_LOG_PREFIX = "VIRT"
+
+// Time 'Read' waits for a frame before returning 0 bytes, like the read timeout of a serial port
+const READ_TIMEOUT = 100 * time.Millisecond
+
+// Number of frames kept for reading, older ones are dropped
+const READ_QUEUE_SIZE = 16
+
+// Number of DMX channels in a universe
+const MAXIMUM_CHANNEL_COUNT = 512
+
+// Number of channels per line when printing frames
+const PRINT_CHANNELS_PER_LINE = 16
+
+// What happens to committed frames
+type Mode int
+
+const (
+	// Committed frames are discarded
+	MODE_NULL Mode = iota
+	// Committed frames can be read back, as if output and input were connected
+	MODE_LOOPBACK
+)
+
+func (m Mode) String() string {
+	switch m {
+	case MODE_NULL:
+		return "NULL"
+	case MODE_LOOPBACK:
+		return "LOOPBACK"
+	}
+	return "UNKNOWN"
+}
+
+/*
+Controller without hardware.
+
+Example useage:
+
+	controller := virtual.NewVirtualController("desk", 512, virtual.MODE_LOOPBACK)
+	controller.SetPrinter(os.Stdout) // optional, print committed frames
+	controller.Connect()
+	controller.Stage(1, 255)
+	controller.Commit()
+	n, err := controller.Read(buf) // reads the frame, beginning with the start code
+*/
+type VirtualController struct {
+	mu   sync.Mutex
+	name string
+	mode Mode
+	// Staged values, as DMX starts with channel '1' the index '0' holds the start code.
+	channels []byte
+	// Last committed frame, beginning with the start code
+	lastCommitted []byte
+	commitCount   int
+	isConnected   bool
+	// Frames waiting to be read in loopback mode
+	frames chan []byte
+	// Closed when disconnecting, to stop waiting reads
+	disconnected chan struct{}
+	// Receives a rendering of every committed frame, unless nil
+	printer io.Writer
+
+	logVerbosity uint8
+}
+
+// Helper function for creating a new virtual controller
+func NewVirtualController(name string, dmxChannelCount int, mode Mode) *VirtualController {
+	if dmxChannelCount < 1 || dmxChannelCount > MAXIMUM_CHANNEL_COUNT {
+		log.Panicf(VIRTUAL_LOG_PREFIX+": channel count must be between 1 and %d, but was %d", MAXIMUM_CHANNEL_COUNT, dmxChannelCount)
+	}
+	d := &VirtualController{}
+	d.name = name
+	d.mode = mode
+	d.channels = make([]byte, dmxChannelCount+1)
+	d.frames = make(chan []byte, READ_QUEUE_SIZE)
+	d.disconnected = make(chan struct{})
+	return d
+}
+
+// Returns the name given when creating the controller
+func (d *VirtualController) GetName() string {
+	return d.name
+}
+
+// Returns what happens to committed frames
+func (d *VirtualController) GetMode() Mode {
+	return d.mode
+}
+
+// Print every committed frame to the writer, nil stops printing
+func (d *VirtualController) SetPrinter(w io.Writer) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.printer = w
+}
+
+// Connecting always succeeds
+func (d *VirtualController) Connect() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if !d.isConnected {
+		d.isConnected = true
+		d.disconnected = make(chan struct{})
+	}
+	return nil
+}
+
+// Disconnect, frames not read yet are dropped
+func (d *VirtualController) Disconnect() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if !d.isConnected {
+		return errorf("not connected.")
+	}
+	d.isConnected = false
+	close(d.disconnected)
+	for {
+		select {
+		case <-d.frames:
+		default:
+			return nil
+		}
+	}
+}
+
+/*
+Read the next frame written or committed in loopback mode, beginning with the start code.
+
+Returns 0 bytes if no frame arrives within 'READ_TIMEOUT', as a serial port would.
+*/
+func (d *VirtualController) Read(buf []byte) (int, error) {
+	d.mu.Lock()
+	connected := d.isConnected
+	disconnected := d.disconnected
+	d.mu.Unlock()
+	if !connected {
+		return -1, errorf("not connected")
+	}
+	select {
+	case frame := <-d.frames:
+		n := copy(buf, frame)
+		d.mu.Lock()
+		d.printf(2, "Read %d bytes:\t%v", n, buf[0:n])
+		d.mu.Unlock()
+		return n, nil
+	case <-time.After(READ_TIMEOUT):
+		return 0, nil
+	case <-disconnected:
+		return -1, errorf("not connected")
+	}
+}
+
+// Write a raw frame, beginning with the start code, readable in loopback mode
+func (d *VirtualController) Write(buf []byte) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if !d.isConnected {
+		return -1, errorf("not connected")
+	}
+	d.printf(2, "Wrote %d bytes:\t%v", len(buf), buf)
+	d.loopback(append([]byte{}, buf...))
+	return len(buf), nil
+}
+
+/*
+Prepare a channel to be changed to the given value
+
+Note: This does not send out the changes, you must call the 'Commit' method to apply the stage live.
+*/
+func (d *VirtualController) Stage(channel int16, value byte) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	highestChannel := int16(len(d.channels) - 1)
+	if channel < 1 || channel > highestChannel {
+		return errorf("index %d out of range, must be between 1 and %d", channel, highestChannel)
+	}
+	d.channels[channel] = value
+	return nil
+}
+
+/*
+Apply the 'staged' values to go live: print them, and make them readable in loopback mode.
+
+Note: This does not clear the Stage!
+*/
+func (d *VirtualController) Commit() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if !d.isConnected {
+		return errorf("not connected")
+	}
+	d.lastCommitted = append(d.lastCommitted[:0], d.channels...)
+	d.commitCount++
+	d.printf(1, "Committing \tdata=%v", d.channels)
+	if d.printer != nil {
+		if _, err := io.WriteString(d.printer, render(d.commitCount, d.channels)); err != nil {
+			return errorf("could not print frame, %v", err)
+		}
+	}
+	d.loopback(append([]byte{}, d.channels...))
+	return nil
+}
+
+// Make the frame readable in loopback mode, dropping the oldest frame if nobody reads, 'mu' must be held
+func (d *VirtualController) loopback(frame []byte) {
+	if d.mode != MODE_LOOPBACK {
+		return
+	}
+	for {
+		select {
+		case d.frames <- frame:
+			return
+		default:
+		}
+		select {
+		case <-d.frames:
+		default:
+		}
+	}
+}
+
+// Gets a copy of all staged channel values
+func (d *VirtualController) GetStage() []byte {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	channels := make([]byte, len(d.channels))
+	copy(channels, d.channels)
+	return channels
+}
+
+// Returns a copy of the last committed frame beginning with the start code, nil if nothing was committed yet
+func (d *VirtualController) GetLastCommitted() []byte {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.commitCount == 0 {
+		return nil
+	}
+	return append([]byte{}, d.lastCommitted...)
+}
+
+// Returns the number of commits since creating the controller
+func (d *VirtualController) GetCommitCount() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.commitCount
+}
+
+// Set all values of the staged channels to '0'
+func (d *VirtualController) ClearStage() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for i := range d.channels {
+		d.channels[i] = 0
+	}
+}
+
+/*
+Render a frame beginning with the start code, channel numbers precede each line.
+
+e.g.
+
+	frame 1
+	  1: 255   0   0 ...
+	 17:   0 ...
+*/
+func render(number int, frame []byte) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "frame %d\n", number)
+	channels := frame[1:]
+	for start := 0; start < len(channels); start += PRINT_CHANNELS_PER_LINE {
+		end := start + PRINT_CHANNELS_PER_LINE
+		if end > len(channels) {
+			end = len(channels)
+		}
+		fmt.Fprintf(&sb, "%3d:", start+1)
+		for _, value := range channels[start:end] {
+			fmt.Fprintf(&sb, " %3d", value)
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+/*
+Set log verbosity
+
+0 = no logging
+
+1 = message logging
+
+2 = byte logging
+*/
+func (d *VirtualController) SetLogVerbosity(verbosity uint8) {
+	if verbosity > 2 {
+		log.Panicf(VIRTUAL_LOG_PREFIX+": invalid value, only 0, 1 and 2 are allowed, but got '%d'", verbosity)
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.logVerbosity = verbosity
+}
+
+// Callers must hold 'mu'
+func (d *VirtualController) printf(level uint8, format string, v ...any) {
+	if d.logVerbosity == level {
+		log.Printf(VIRTUAL_LOG_PREFIX+" "+d.name+": "+format, v...)
+	}
+}
+
+func errorf(format string, v ...any) error {
+	return fmt.Errorf(VIRTUAL_LOG_PREFIX+": "+format, v...)
+}
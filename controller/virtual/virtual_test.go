@@ -0,0 +1,148 @@
+package virtual
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	usbdmxgolang "github.com/H3rby7/usbdmx-golang"
+)
+
+var _ usbdmxgolang.DMXController = (*VirtualController)(nil)
+
+func newConnected(t *testing.T, mode Mode) *VirtualController {
+	d := NewVirtualController("test", 3, mode)
+	if err := d.Connect(); err != nil {
+		t.Fatalf("expected no error on connect, but got %v", err)
+	}
+	t.Cleanup(func() { d.Disconnect() })
+	return d
+}
+
+func TestLoopbackReadsCommittedFrames(t *testing.T) {
+	d := newConnected(t, MODE_LOOPBACK)
+	d.Stage(1, 11)
+	d.Commit()
+	d.Stage(3, 33)
+	d.Commit()
+	buf := make([]byte, 8)
+	for _, expected := range [][]byte{{0, 11, 0, 0}, {0, 11, 0, 33}} {
+		n, err := d.Read(buf)
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		if !bytes.Equal(buf[:n], expected) {
+			t.Errorf("expected to read %v, but was %v", expected, buf[:n])
+		}
+	}
+	// Nothing left, the read times out
+	if n, err := d.Read(buf); n != 0 || err != nil {
+		t.Errorf("expected 0 bytes and no error, but got %d and %v", n, err)
+	}
+}
+
+func TestLoopbackDropsOldestFrames(t *testing.T) {
+	d := newConnected(t, MODE_LOOPBACK)
+	for i := 0; i < READ_QUEUE_SIZE+2; i++ {
+		d.Stage(1, byte(i))
+		d.Commit()
+	}
+	buf := make([]byte, 8)
+	d.Read(buf)
+	if buf[1] != 2 {
+		t.Errorf("expected the oldest 2 frames to be dropped, but read frame %d first", buf[1])
+	}
+}
+
+func TestNullDiscardsFrames(t *testing.T) {
+	d := newConnected(t, MODE_NULL)
+	d.Stage(2, 22)
+	if err := d.Commit(); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if n, _ := d.Read(make([]byte, 8)); n != 0 {
+		t.Errorf("expected nothing to read, but got %d bytes", n)
+	}
+	if !bytes.Equal(d.GetLastCommitted(), []byte{0, 0, 22, 0}) {
+		t.Errorf("expected last committed frame to be %v, but was %v", []byte{0, 0, 22, 0}, d.GetLastCommitted())
+	}
+	if d.GetCommitCount() != 1 {
+		t.Errorf("expected 1 commit, but got %d", d.GetCommitCount())
+	}
+}
+
+func TestPrinterRendersFrames(t *testing.T) {
+	d := NewVirtualController("test", 18, MODE_NULL)
+	var out bytes.Buffer
+	d.SetPrinter(&out)
+	d.Connect()
+	d.Stage(1, 255)
+	d.Stage(17, 7)
+	d.Commit()
+	expected := "frame 1\n" +
+		"  1: 255   0   0   0   0   0   0   0   0   0   0   0   0   0   0   0\n" +
+		" 17:   7   0\n"
+	if out.String() != expected {
+		t.Errorf("expected printed frame to be\n%s\nbut was\n%s", expected, out.String())
+	}
+}
+
+func TestCommitRequiresConnection(t *testing.T) {
+	d := NewVirtualController("test", 3, MODE_LOOPBACK)
+	if err := d.Stage(1, 1); err != nil {
+		t.Errorf("expected staging to work unconnected, but got %v", err)
+	}
+	if err := d.Commit(); err == nil {
+		t.Errorf("expected error committing unconnected")
+	}
+	if _, err := d.Read(make([]byte, 8)); err == nil {
+		t.Errorf("expected error reading unconnected")
+	}
+	if err := d.Stage(4, 1); err == nil {
+		t.Errorf("expected error staging channel 4 of 3")
+	}
+}
+
+func TestOpenURI(t *testing.T) {
+	controller, err := usbdmxgolang.Open("virtual:desk?channels=16&mode=null")
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	d := controller.(*VirtualController)
+	if d.GetName() != "desk" || d.GetMode() != MODE_NULL || len(d.GetStage()) != 17 {
+		t.Errorf("expected controller 'desk' in NULL mode with 16 channels, but got '%s' in %v mode with stage of %d", d.GetName(), d.GetMode(), len(d.GetStage()))
+	}
+	for _, uri := range []string{"virtual:?mode=echo", "virtual:?print=file", "virtual:?channels=0"} {
+		if _, err := usbdmxgolang.Open(uri); err == nil || !strings.HasPrefix(err.Error(), VIRTUAL_LOG_PREFIX) {
+			t.Errorf("expected error for '%s', but got %v", uri, err)
+		}
+	}
+}
+
+func TestInvalidChannelCountPanics(t *testing.T) {
+	for _, count := range []int{-1, 0, MAXIMUM_CHANNEL_COUNT + 1} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("expected a panic for %d channels", count)
+				}
+			}()
+			NewVirtualController("test", count, MODE_LOOPBACK)
+		}()
+	}
+}
+
+func TestSetLogVerbosityWhileCommitting(t *testing.T) {
+	d := newConnected(t, MODE_NULL)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			d.Commit()
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		d.SetLogVerbosity(0)
+	}
+	<-done
+}
@@ -4,5 +4,5 @@ go 1.21
 
 require (
 	github.com/tarm/serial v0.0.0-20180830185346-98f6abe2eb07
-	golang.org/x/sys v0.13.0 // indirect
+	golang.org/x/sys v0.13.0
 )
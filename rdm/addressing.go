@@ -0,0 +1,187 @@
+package rdm
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DMX channels of a responder
+type Patch struct {
+	// Responder patched
+	UID UID
+	// Number of DMX channels used, 0 if the responder needs no address
+	Footprint uint16
+	// First DMX channel used
+	StartAddress uint16
+}
+
+// Returns the last DMX channel used
+func (p Patch) GetEndAddress() uint16 {
+	return p.StartAddress + p.Footprint - 1
+}
+
+func (p Patch) overlaps(other Patch) bool {
+	return p.StartAddress <= other.GetEndAddress() && other.StartAddress <= p.GetEndAddress()
+}
+
+// A responder that could not be patched as wanted
+type AddressConflict struct {
+	// Responder affected
+	UID UID
+	// Human readable description
+	Reason string
+}
+
+func (c AddressConflict) String() string {
+	return fmt.Sprintf("%v: %s", c.UID, c.Reason)
+}
+
+// Outcome of 'PlanAddresses'
+type AddressPlan struct {
+	// Start addresses to set, sorted by start address
+	Patches []Patch
+	// Responders left out of 'Patches'
+	Conflicts []AddressConflict
+}
+
+/*
+Propose non-overlapping DMX start addresses for the responders.
+
+Responders in the manual patch keep their start address, the others are placed in ascending UID order into the first gap big enough.
+Manual patches overlapping each other, exceeding the universe or naming unknown responders are reported as conflicts.
+Responders without footprint need no address and are left out, a manual start address for them is reported as conflict.
+
+Example useage:
+
+	plan := rdm.PlanAddresses([]rdm.Patch{{UID: uid, Footprint: info.Footprint}}, manual)
+*/
+func PlanAddresses(responders []Patch, manual map[UID]uint16) AddressPlan {
+	plan := AddressPlan{Patches: make([]Patch, 0), Conflicts: make([]AddressConflict, 0)}
+	sorted := append([]Patch{}, responders...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].UID < sorted[j].UID })
+	known := make(map[UID]bool)
+	automatic := make([]Patch, 0)
+	for _, p := range sorted {
+		known[p.UID] = true
+		address, ok := manual[p.UID]
+		if p.Footprint == 0 {
+			if ok {
+				plan.Conflicts = append(plan.Conflicts, AddressConflict{UID: p.UID, Reason: fmt.Sprintf("manual start address %d, but responder has no DMX footprint", address)})
+			}
+			continue
+		}
+		if !ok {
+			automatic = append(automatic, p)
+			continue
+		}
+		p.StartAddress = address
+		if conflict := findManualConflict(p, plan.Patches); conflict != "" {
+			plan.Conflicts = append(plan.Conflicts, AddressConflict{UID: p.UID, Reason: conflict})
+			continue
+		}
+		plan.Patches = append(plan.Patches, p)
+	}
+	for _, uid := range sortedUIDs(manual) {
+		if !known[uid] {
+			plan.Conflicts = append(plan.Conflicts, AddressConflict{UID: uid, Reason: "manually patched, but not found"})
+		}
+	}
+	sortPatches(plan.Patches)
+	for _, p := range automatic {
+		address, ok := findGap(p.Footprint, plan.Patches)
+		if !ok {
+			plan.Conflicts = append(plan.Conflicts, AddressConflict{UID: p.UID, Reason: fmt.Sprintf("no gap of %d channels left", p.Footprint)})
+			continue
+		}
+		p.StartAddress = address
+		plan.Patches = append(plan.Patches, p)
+		sortPatches(plan.Patches)
+	}
+	return plan
+}
+
+// Returns why the manual patch cannot be used, or an empty string
+func findManualConflict(p Patch, patches []Patch) string {
+	if p.StartAddress < MINIMUM_DMX_START_ADDRESS || int(p.StartAddress)+int(p.Footprint)-1 > MAXIMUM_DMX_START_ADDRESS {
+		return fmt.Sprintf("manual start address %d with footprint %d exceeds the universe", p.StartAddress, p.Footprint)
+	}
+	for _, other := range patches {
+		if p.overlaps(other) {
+			return fmt.Sprintf("manual channels %d-%d overlap %v at %d-%d", p.StartAddress, p.GetEndAddress(), other.UID, other.StartAddress, other.GetEndAddress())
+		}
+	}
+	return ""
+}
+
+// Returns the first start address with 'footprint' free channels, 'patches' must be sorted by start address
+func findGap(footprint uint16, patches []Patch) (uint16, bool) {
+	address := MINIMUM_DMX_START_ADDRESS
+	for _, p := range patches {
+		if address+int(footprint) <= int(p.StartAddress) {
+			break
+		}
+		if int(p.GetEndAddress())+1 > address {
+			address = int(p.GetEndAddress()) + 1
+		}
+	}
+	if address+int(footprint)-1 > MAXIMUM_DMX_START_ADDRESS {
+		return 0, false
+	}
+	return uint16(address), true
+}
+
+func sortPatches(patches []Patch) {
+	sort.Slice(patches, func(i, j int) bool { return patches[i].StartAddress < patches[j].StartAddress })
+}
+
+func sortedUIDs(m map[UID]uint16) []UID {
+	uids := make([]UID, 0, len(m))
+	for uid := range m {
+		uids = append(uids, uid)
+	}
+	sort.Slice(uids, func(i, j int) bool { return uids[i] < uids[j] })
+	return uids
+}
+
+/*
+Read a manual patch, one responder per line as UID and start address.
+
+Empty lines and lines starting with '#' are skipped.
+
+e.g.
+
+	# Front truss
+	454E:00000001 1
+	454E:00000002 17
+*/
+func ParseManualPatch(r io.Reader) (map[UID]uint16, error) {
+	manual := make(map[UID]uint16)
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Fields(text)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("line %d: expected UID and start address, but got '%s'", line, text)
+		}
+		uid, err := ParseUID(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		address, err := strconv.ParseUint(fields[1], 10, 16)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid start address '%s'", line, fields[1])
+		}
+		if _, duplicate := manual[uid]; duplicate {
+			return nil, fmt.Errorf("line %d: %v is patched twice", line, uid)
+		}
+		manual[uid] = uint16(address)
+	}
+	return manual, scanner.Err()
+}
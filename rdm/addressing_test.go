@@ -0,0 +1,99 @@
+package rdm
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPlanAddresses(t *testing.T) {
+	responders := []Patch{
+		{UID: NewUID(0x454E, 3), Footprint: 4},
+		{UID: NewUID(0x454E, 1), Footprint: 8},
+		{UID: NewUID(0x454E, 2), Footprint: 16},
+		{UID: NewUID(0x454E, 4), Footprint: 0},
+	}
+	plan := PlanAddresses(responders, map[UID]uint16{NewUID(0x454E, 3): 5})
+	if len(plan.Conflicts) != 0 {
+		t.Errorf("expected no conflicts, but got %v", plan.Conflicts)
+	}
+	expected := []Patch{
+		{UID: NewUID(0x454E, 1), Footprint: 8, StartAddress: 9},
+		{UID: NewUID(0x454E, 3), Footprint: 4, StartAddress: 5},
+		{UID: NewUID(0x454E, 2), Footprint: 16, StartAddress: 17},
+	}
+	if len(plan.Patches) != len(expected) {
+		t.Fatalf("expected %d patches, but got %v", len(expected), plan.Patches)
+	}
+	// Sorted by start address
+	order := []int{1, 0, 2}
+	for i, j := range order {
+		if plan.Patches[i] != expected[j] {
+			t.Errorf("expected patch %d to be %+v, but was %+v", i, expected[j], plan.Patches[i])
+		}
+	}
+}
+
+func TestPlanAddressesConflicts(t *testing.T) {
+	responders := []Patch{
+		{UID: NewUID(0x454E, 1), Footprint: 8},
+		{UID: NewUID(0x454E, 2), Footprint: 8},
+		{UID: NewUID(0x454E, 3), Footprint: 8},
+		{UID: NewUID(0x454E, 4), Footprint: 510},
+	}
+	manual := map[UID]uint16{
+		NewUID(0x454E, 1): 1,
+		NewUID(0x454E, 2): 5,   // overlaps 1
+		NewUID(0x454E, 3): 510, // exceeds the universe
+		NewUID(0x454E, 9): 100, // not found
+	}
+	plan := PlanAddresses(responders, manual)
+	if len(plan.Patches) != 1 || plan.Patches[0].UID != NewUID(0x454E, 1) {
+		t.Errorf("expected only %v to be patched, but got %v", NewUID(0x454E, 1), plan.Patches)
+	}
+	conflicting := make(map[UID]bool)
+	for _, c := range plan.Conflicts {
+		conflicting[c.UID] = true
+	}
+	for _, device := range []uint32{2, 3, 4, 9} {
+		if !conflicting[NewUID(0x454E, device)] {
+			t.Errorf("expected a conflict for %v, but got %v", NewUID(0x454E, device), plan.Conflicts)
+		}
+	}
+}
+
+func TestPlanAddressesManualWithoutFootprint(t *testing.T) {
+	uid := NewUID(0x454E, 1)
+	plan := PlanAddresses([]Patch{{UID: uid, Footprint: 0}}, map[UID]uint16{uid: 42})
+	if len(plan.Patches) != 0 {
+		t.Errorf("expected no patches, but got %v", plan.Patches)
+	}
+	if len(plan.Conflicts) != 1 || plan.Conflicts[0].UID != uid || !strings.Contains(plan.Conflicts[0].Reason, "no DMX footprint") {
+		t.Errorf("expected a conflict for %v without footprint, but got %v", uid, plan.Conflicts)
+	}
+}
+
+func TestPlanAddressesFillsGaps(t *testing.T) {
+	responders := []Patch{
+		{UID: NewUID(0x454E, 1), Footprint: 10},
+		{UID: NewUID(0x454E, 2), Footprint: 3},
+	}
+	plan := PlanAddresses(responders, map[UID]uint16{NewUID(0x454E, 1): 4})
+	if plan.Patches[0].UID != NewUID(0x454E, 2) || plan.Patches[0].StartAddress != 1 {
+		t.Errorf("expected %v to fill the gap at 1, but got %v", NewUID(0x454E, 2), plan.Patches)
+	}
+}
+
+func TestParseManualPatch(t *testing.T) {
+	manual, err := ParseManualPatch(strings.NewReader("# Front truss\n454E:00000001 1\n\n  454E:00000002   17  \n"))
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if len(manual) != 2 || manual[NewUID(0x454E, 1)] != 1 || manual[NewUID(0x454E, 2)] != 17 {
+		t.Errorf("expected two patches at 1 and 17, but got %v", manual)
+	}
+	for _, invalid := range []string{"454E:00000001", "454E:00000001 x", "454E 1", "454E:00000001 1\n454E:00000001 2"} {
+		if _, err := ParseManualPatch(strings.NewReader(invalid)); err == nil {
+			t.Errorf("expected an error for '%s'", invalid)
+		}
+	}
+}
@@ -0,0 +1,201 @@
+package rdm
+
+import (
+	"fmt"
+	"sort"
+)
+
+const (
+	// Byte of the preamble a discovery response begins with
+	DISCOVERY_PREAMBLE_BYTE = 0xFE
+	// Separates the preamble from the encoded UID
+	DISCOVERY_PREAMBLE_SEPARATOR = 0xAA
+	// Maximum number of preamble bytes, receivers must accept fewer
+	DISCOVERY_MAXIMUM_PREAMBLE_SIZE = 7
+	// Number of bytes of the encoded UID, each byte is sent twice
+	DISCOVERY_ENCODED_UID_SIZE = 2 * UID_SIZE
+	// Number of bytes of the encoded checksum, each byte is sent twice
+	DISCOVERY_ENCODED_CHECKSUM_SIZE = 4
+)
+
+/*
+Sends discovery requests on a DMX line, see 'Discover'.
+
+Implemented by controllers able to send RDM.
+*/
+type DiscoveryTransport interface {
+	// Send DISC_UNIQUE_BRANCH to all responders within the range, returns the raw response or nil if none responded
+	DiscoverUniqueBranch(lower UID, upper UID) ([]byte, error)
+	// Send DISC_MUTE to the responder, returns false if it did not respond
+	DiscoveryMute(uid UID) (bool, error)
+	// Send DISC_UN_MUTE to all responders
+	DiscoveryUnMuteAll() error
+}
+
+/*
+Find the UIDs of all responders on the DMX line, sorted ascending.
+
+Un-mutes all responders, then searches the UID space with DISC_UNIQUE_BRANCH.
+A valid response is muted with DISC_MUTE, a collision of multiple responses splits the range in halves (binary search).
+
+Example useage:
+
+	uids, err := rdm.Discover(transport)
+*/
+func Discover(t DiscoveryTransport) ([]UID, error) {
+	if err := t.DiscoveryUnMuteAll(); err != nil {
+		return nil, err
+	}
+	found := make([]UID, 0)
+	err := discoverBranch(t, MINIMUM_UID, MAXIMUM_UID, &found)
+	sort.Slice(found, func(i, j int) bool { return found[i] < found[j] })
+	return found, err
+}
+
+// Find all unmuted responders within the range
+func discoverBranch(t DiscoveryTransport, lower UID, upper UID, found *[]UID) error {
+	for {
+		response, err := t.DiscoverUniqueBranch(lower, upper)
+		if err != nil {
+			return err
+		}
+		if response == nil {
+			return nil
+		}
+		uid, err := DecodeDiscoveryResponse(response)
+		if err == nil && uid >= lower && uid <= upper && !containsUID(*found, uid) {
+			muted, err := t.DiscoveryMute(uid)
+			if err != nil {
+				return err
+			}
+			if muted {
+				// More responders may be left in the range
+				*found = append(*found, uid)
+				continue
+			}
+		}
+		// Collision, or a responder not behaving
+		if lower == upper {
+			return nil
+		}
+		middle := lower + (upper-lower)/2
+		if err := discoverBranch(t, lower, middle, found); err != nil {
+			return err
+		}
+		return discoverBranch(t, middle+1, upper, found)
+	}
+}
+
+func containsUID(uids []UID, uid UID) bool {
+	for _, u := range uids {
+		if u == uid {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+Create the parameter data of DISC_UNIQUE_BRANCH for the range.
+
+Byte layout:
+
+0-5  - Lower bound UID
+
+6-11 - Upper bound UID
+*/
+func NewDiscoverUniqueBranchData(lower UID, upper UID) []byte {
+	return append(lower.ToBytes(), upper.ToBytes()...)
+}
+
+// Read the range from the parameter data of DISC_UNIQUE_BRANCH
+func ToDiscoverUniqueBranchRange(data []byte) (lower UID, upper UID, err error) {
+	if len(data) != 2*UID_SIZE {
+		return 0, 0, fmt.Errorf("parameter data must be %d bytes, but was %d", 2*UID_SIZE, len(data))
+	}
+	lower, _ = UIDFromBytes(data[:UID_SIZE])
+	upper, _ = UIDFromBytes(data[UID_SIZE:])
+	return lower, upper, nil
+}
+
+/*
+Create the response to DISC_UNIQUE_BRANCH, as sent by the responder without a break.
+
+Byte layout:
+
+0-6   - Preamble of 'DISCOVERY_PREAMBLE_BYTE'
+
+7     - 'DISCOVERY_PREAMBLE_SEPARATOR'
+
+8-19  - UID, each byte sent as (byte | 0xAA) and (byte | 0x55)
+
+20-23 - Checksum of the encoded UID, encoded the same way
+*/
+func EncodeDiscoveryResponse(uid UID) []byte {
+	response := make([]byte, 0, DISCOVERY_MAXIMUM_PREAMBLE_SIZE+1+DISCOVERY_ENCODED_UID_SIZE+DISCOVERY_ENCODED_CHECKSUM_SIZE)
+	for i := 0; i < DISCOVERY_MAXIMUM_PREAMBLE_SIZE; i++ {
+		response = append(response, DISCOVERY_PREAMBLE_BYTE)
+	}
+	response = append(response, DISCOVERY_PREAMBLE_SEPARATOR)
+	encoded := encodeDiscoveryBytes(uid.ToBytes())
+	checksum := Checksum(encoded)
+	response = append(response, encoded...)
+	return append(response, encodeDiscoveryBytes([]byte{byte(checksum >> 8), byte(checksum)})...)
+}
+
+/*
+Read the UID from a response to DISC_UNIQUE_BRANCH, see 'EncodeDiscoveryResponse'.
+
+Returns an error if the response is corrupted, usually because multiple responders collided.
+*/
+func DecodeDiscoveryResponse(response []byte) (UID, error) {
+	preamble := 0
+	for preamble < len(response) && response[preamble] == DISCOVERY_PREAMBLE_BYTE {
+		preamble++
+	}
+	if preamble > DISCOVERY_MAXIMUM_PREAMBLE_SIZE {
+		return 0, fmt.Errorf("preamble must be at most %d bytes, but was %d", DISCOVERY_MAXIMUM_PREAMBLE_SIZE, preamble)
+	}
+	if preamble >= len(response) || response[preamble] != DISCOVERY_PREAMBLE_SEPARATOR {
+		return 0, fmt.Errorf("preamble separator %X missing", DISCOVERY_PREAMBLE_SEPARATOR)
+	}
+	encoded := response[preamble+1:]
+	if len(encoded) < DISCOVERY_ENCODED_UID_SIZE+DISCOVERY_ENCODED_CHECKSUM_SIZE {
+		return 0, fmt.Errorf("encoded UID and checksum must be %d bytes, but were %d", DISCOVERY_ENCODED_UID_SIZE+DISCOVERY_ENCODED_CHECKSUM_SIZE, len(encoded))
+	}
+	uidBytes, err := decodeDiscoveryBytes(encoded[:DISCOVERY_ENCODED_UID_SIZE])
+	if err != nil {
+		return 0, err
+	}
+	checksumBytes, err := decodeDiscoveryBytes(encoded[DISCOVERY_ENCODED_UID_SIZE : DISCOVERY_ENCODED_UID_SIZE+DISCOVERY_ENCODED_CHECKSUM_SIZE])
+	if err != nil {
+		return 0, err
+	}
+	expected := Checksum(encoded[:DISCOVERY_ENCODED_UID_SIZE])
+	actual := uint16(checksumBytes[0])<<8 | uint16(checksumBytes[1])
+	if expected != actual {
+		return 0, fmt.Errorf("checksum should be %04X, but was %04X", expected, actual)
+	}
+	return UIDFromBytes(uidBytes)
+}
+
+// Send each byte twice, once with the odd and once with the even bits set
+func encodeDiscoveryBytes(data []byte) []byte {
+	encoded := make([]byte, 0, 2*len(data))
+	for _, b := range data {
+		encoded = append(encoded, b|0xAA, b|0x55)
+	}
+	return encoded
+}
+
+func decodeDiscoveryBytes(encoded []byte) ([]byte, error) {
+	data := make([]byte, len(encoded)/2)
+	for i := range data {
+		b := encoded[2*i] & encoded[2*i+1]
+		if encoded[2*i] != b|0xAA || encoded[2*i+1] != b|0x55 {
+			return nil, fmt.Errorf("byte %d is not encoded correctly, got %X and %X", i, encoded[2*i], encoded[2*i+1])
+		}
+		data[i] = b
+	}
+	return data, nil
+}
@@ -0,0 +1,166 @@
+package rdm
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+// DMX line of responders, simultaneous responses collide
+type fakeDiscoveryLine struct {
+	responders map[UID]bool // UID to muted
+	requests   int
+}
+
+func newFakeDiscoveryLine(uids ...UID) *fakeDiscoveryLine {
+	line := &fakeDiscoveryLine{responders: make(map[UID]bool)}
+	for _, uid := range uids {
+		line.responders[uid] = true
+	}
+	return line
+}
+
+func (l *fakeDiscoveryLine) DiscoverUniqueBranch(lower UID, upper UID) ([]byte, error) {
+	l.requests++
+	var combined []byte
+	for uid, muted := range l.responders {
+		if muted || uid < lower || uid > upper {
+			continue
+		}
+		response := EncodeDiscoveryResponse(uid)
+		if combined == nil {
+			combined = response
+			continue
+		}
+		for i := range response {
+			combined[i] |= response[i]
+		}
+	}
+	return combined, nil
+}
+
+func (l *fakeDiscoveryLine) DiscoveryMute(uid UID) (bool, error) {
+	l.requests++
+	if _, ok := l.responders[uid]; !ok {
+		return false, nil
+	}
+	l.responders[uid] = true
+	return true, nil
+}
+
+func (l *fakeDiscoveryLine) DiscoveryUnMuteAll() error {
+	l.requests++
+	for uid := range l.responders {
+		l.responders[uid] = false
+	}
+	return nil
+}
+
+func TestEncodeDiscoveryResponse(t *testing.T) {
+	response := EncodeDiscoveryResponse(NewUID(0x1234, 0x56789ABC))
+	expected := []byte{
+		0xFE, 0xFE, 0xFE, 0xFE, 0xFE, 0xFE, 0xFE, 0xAA,
+		0xBA, 0x57, 0xBE, 0x75, 0xFE, 0x57, 0xFA, 0x7D, 0xBA, 0xDF, 0xBE, 0xFD,
+	}
+	sum := 0
+	for _, b := range expected[8:] {
+		sum += int(b)
+	}
+	expected = append(expected, byte(sum>>8)|0xAA, byte(sum>>8)|0x55, byte(sum)|0xAA, byte(sum)|0x55)
+	if !bytes.Equal(response, expected) {
+		t.Errorf("expected response to be %X, but was %X", expected, response)
+	}
+}
+
+func TestDecodeDiscoveryResponse(t *testing.T) {
+	uid := NewUID(0x454E, 0x12345678)
+	response := EncodeDiscoveryResponse(uid)
+	// Receivers must accept a shortened preamble
+	decoded, err := DecodeDiscoveryResponse(response[5:])
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if decoded != uid {
+		t.Errorf("expected UID to be %v, but was %v", uid, decoded)
+	}
+}
+
+func TestDecodeDiscoveryResponseCollision(t *testing.T) {
+	response := EncodeDiscoveryResponse(NewUID(0x454E, 1))
+	other := EncodeDiscoveryResponse(NewUID(0x454E, 2))
+	for i := range response {
+		response[i] |= other[i]
+	}
+	if _, err := DecodeDiscoveryResponse(response); err == nil {
+		t.Errorf("expected an error, as the responses collided")
+	}
+	if _, err := DecodeDiscoveryResponse([]byte{0xFE, 0xFE, 0x00}); err == nil {
+		t.Errorf("expected an error, as the separator is missing")
+	}
+}
+
+func TestDiscover(t *testing.T) {
+	uids := []UID{MINIMUM_UID, NewUID(0x454E, 1), NewUID(0x454E, 2), NewUID(0x454E, 3), NewUID(0x7A70, 0xFFFFFFFF), MAXIMUM_UID}
+	line := newFakeDiscoveryLine(uids[3], uids[0], uids[5], uids[1], uids[4], uids[2])
+	found, err := Discover(line)
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if len(found) != len(uids) {
+		t.Fatalf("expected to find %d UIDs, but found %v", len(uids), found)
+	}
+	for i, uid := range uids {
+		if found[i] != uid {
+			t.Errorf("expected UID %d to be %v, but was %v", i, uid, found[i])
+		}
+	}
+}
+
+func TestDiscoverNothing(t *testing.T) {
+	line := newFakeDiscoveryLine()
+	found, err := Discover(line)
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if len(found) != 0 {
+		t.Errorf("expected to find nothing, but found %v", found)
+	}
+	if line.requests != 2 {
+		t.Errorf("expected %d requests, but were %d", 2, line.requests)
+	}
+}
+
+// Responder acknowledging DISC_MUTE, but still responding
+type unmutableLine struct {
+	fakeDiscoveryLine
+}
+
+func (l *unmutableLine) DiscoveryMute(uid UID) (bool, error) {
+	return true, nil
+}
+
+func TestDiscoverUnmutableResponder(t *testing.T) {
+	line := &unmutableLine{*newFakeDiscoveryLine(NewUID(0x454E, 1))}
+	found, err := Discover(line)
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if len(found) != 1 || found[0] != NewUID(0x454E, 1) {
+		t.Errorf("expected to find %v once, but found %v", NewUID(0x454E, 1), found)
+	}
+}
+
+type failingDiscoveryLine struct {
+	fakeDiscoveryLine
+}
+
+func (l *failingDiscoveryLine) DiscoverUniqueBranch(lower UID, upper UID) ([]byte, error) {
+	return nil, errors.New("unplugged")
+}
+
+func TestDiscoverError(t *testing.T) {
+	line := &failingDiscoveryLine{*newFakeDiscoveryLine()}
+	if _, err := Discover(line); err == nil {
+		t.Errorf("expected an error, as the line failed")
+	}
+}
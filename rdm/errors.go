@@ -0,0 +1,96 @@
+package rdm
+
+import (
+	"fmt"
+	"time"
+)
+
+// Unit of the ACK_TIMER delay
+const ACK_TIMER_UNIT = 100 * time.Millisecond
+
+/*
+The responder refused the request (RESPONSE_TYPE_NACK_REASON).
+
+Example useage:
+
+	var nack *rdm.NackError
+	if errors.As(err, &nack) && nack.Reason == rdm.NACK_REASON_UNKNOWN_PID { ... }
+*/
+type NackError struct {
+	// Parameter ID of the request
+	PID uint16
+	// Reason for refusing, see 'NACK_REASON_UNKNOWN_PID' etc.
+	Reason uint16
+}
+
+func (e *NackError) Error() string {
+	return fmt.Sprintf("request for PID %04X refused, %s", e.PID, nackReasonName(e.Reason))
+}
+
+/*
+The responder will handle the request later (RESPONSE_TYPE_ACK_TIMER).
+
+The result is queued by the responder, it can be fetched after 'Delay' with 'PID_QUEUED_MESSAGE'.
+*/
+type AckTimerError struct {
+	// Parameter ID of the request
+	PID uint16
+	// Time to wait before the result is available
+	Delay time.Duration
+}
+
+func (e *AckTimerError) Error() string {
+	return fmt.Sprintf("request for PID %04X will be handled within %v", e.PID, e.Delay)
+}
+
+/*
+Returns the error a response stands for, nil for RESPONSE_TYPE_ACK and RESPONSE_TYPE_ACK_OVERFLOW.
+
+Returns *NackError or *AckTimerError, depending on the response type.
+*/
+func ResponseError(response Message) error {
+	switch response.GetResponseType() {
+	case RESPONSE_TYPE_ACK, RESPONSE_TYPE_ACK_OVERFLOW:
+		return nil
+	case RESPONSE_TYPE_NACK_REASON:
+		if len(response.ParameterData) != 2 {
+			return fmt.Errorf("NACK reason must be %d bytes, but was %d", 2, len(response.ParameterData))
+		}
+		return &NackError{PID: response.PID, Reason: uint16(response.ParameterData[0])<<8 | uint16(response.ParameterData[1])}
+	case RESPONSE_TYPE_ACK_TIMER:
+		if len(response.ParameterData) != 2 {
+			return fmt.Errorf("ACK timer delay must be %d bytes, but was %d", 2, len(response.ParameterData))
+		}
+		delay := uint16(response.ParameterData[0])<<8 | uint16(response.ParameterData[1])
+		return &AckTimerError{PID: response.PID, Delay: time.Duration(delay) * ACK_TIMER_UNIT}
+	}
+	return fmt.Errorf("unknown response type %d", response.GetResponseType())
+}
+
+func nackReasonName(reason uint16) string {
+	switch reason {
+	case NACK_REASON_UNKNOWN_PID:
+		return "unknown PID"
+	case NACK_REASON_FORMAT_ERROR:
+		return "format error"
+	case NACK_REASON_HARDWARE_FAULT:
+		return "hardware fault"
+	case NACK_REASON_PROXY_REJECT:
+		return "proxy reject"
+	case NACK_REASON_WRITE_PROTECT:
+		return "write protect"
+	case NACK_REASON_UNSUPPORTED_COMMAND_CLASS:
+		return "unsupported command class"
+	case NACK_REASON_DATA_OUT_OF_RANGE:
+		return "data out of range"
+	case NACK_REASON_BUFFER_FULL:
+		return "buffer full"
+	case NACK_REASON_PACKET_SIZE_UNSUPPORTED:
+		return "packet size unsupported"
+	case NACK_REASON_SUB_DEVICE_OUT_OF_RANGE:
+		return "sub device out of range"
+	case NACK_REASON_PROXY_BUFFER_FULL:
+		return "proxy buffer full"
+	}
+	return fmt.Sprintf("reason %04X", reason)
+}
@@ -0,0 +1,33 @@
+package rdm
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestResponseError(t *testing.T) {
+	request := newTestRequest()
+	if err := ResponseError(NewResponse(request, RESPONSE_TYPE_ACK, nil)); err != nil {
+		t.Errorf("expected no error for ACK, but got %v", err)
+	}
+	var nack *NackError
+	err := ResponseError(NewResponse(request, RESPONSE_TYPE_NACK_REASON, []byte{0x00, NACK_REASON_WRITE_PROTECT}))
+	if !errors.As(err, &nack) {
+		t.Fatalf("expected a NackError, but got %v", err)
+	}
+	if nack.Reason != NACK_REASON_WRITE_PROTECT || nack.PID != PID_DMX_START_ADDRESS {
+		t.Errorf("expected reason %d for PID %04X, but was %d for %04X", NACK_REASON_WRITE_PROTECT, PID_DMX_START_ADDRESS, nack.Reason, nack.PID)
+	}
+	var timer *AckTimerError
+	err = ResponseError(NewResponse(request, RESPONSE_TYPE_ACK_TIMER, []byte{0x00, 15}))
+	if !errors.As(err, &timer) {
+		t.Fatalf("expected an AckTimerError, but got %v", err)
+	}
+	if timer.Delay != 1500*time.Millisecond {
+		t.Errorf("expected delay to be %v, but was %v", 1500*time.Millisecond, timer.Delay)
+	}
+	if err := ResponseError(NewResponse(request, RESPONSE_TYPE_NACK_REASON, nil)); err == nil || errors.As(err, &nack) {
+		t.Errorf("expected an error for a malformed NACK, but got %v", err)
+	}
+}
@@ -0,0 +1,203 @@
+package rdm
+
+import (
+	"fmt"
+)
+
+const (
+	// Maximum number of characters of text parameters, e.g. 'PID_DEVICE_LABEL'
+	MAXIMUM_LABEL_LENGTH = 32
+	// Number of bytes of the 'PID_DEVICE_INFO' parameter data
+	DEVICE_INFO_SIZE = 19
+	// Number of bytes of the 'PID_SENSOR_VALUE' parameter data
+	SENSOR_VALUE_SIZE = 9
+	// Lowest DMX start address
+	MINIMUM_DMX_START_ADDRESS = 1
+	// Highest DMX start address
+	MAXIMUM_DMX_START_ADDRESS = 512
+	// Start address of devices without a DMX footprint
+	NO_DMX_START_ADDRESS = 0xFFFF
+)
+
+// Parameter data of 'PID_DEVICE_INFO'
+type DeviceInfo struct {
+	// RDM protocol version, 0x0100 for 1.0
+	ProtocolVersion uint16
+	// Model ID, defined by the manufacturer
+	Model uint16
+	// Product category, e.g. 0x0100 for fixtures
+	ProductCategory uint16
+	// Software version ID, defined by the manufacturer
+	SoftwareVersion uint32
+	// Number of DMX channels used
+	Footprint uint16
+	// Selected DMX personality, counting from 1
+	CurrentPersonality byte
+	// Number of DMX personalities
+	PersonalityCount byte
+	// First DMX channel used, 'NO_DMX_START_ADDRESS' if the footprint is 0
+	StartAddress uint16
+	// Number of sub devices
+	SubDeviceCount uint16
+	// Number of sensors
+	SensorCount byte
+}
+
+/*
+Convert the parameter data of 'PID_DEVICE_INFO'.
+
+Byte layout (MSB first):
+
+0-1   - Protocol version
+
+2-3   - Model ID
+
+4-5   - Product category
+
+6-9   - Software version ID
+
+10-11 - DMX footprint
+
+12    - Current personality
+
+13    - Personality count
+
+14-15 - DMX start address
+
+16-17 - Sub device count
+
+18    - Sensor count
+*/
+func ToDeviceInfo(data []byte) (info DeviceInfo, err error) {
+	if len(data) != DEVICE_INFO_SIZE {
+		return info, fmt.Errorf("device info must be %d bytes, but was %d", DEVICE_INFO_SIZE, len(data))
+	}
+	info.ProtocolVersion = toUint16(data[0:])
+	info.Model = toUint16(data[2:])
+	info.ProductCategory = toUint16(data[4:])
+	info.SoftwareVersion = uint32(toUint16(data[6:]))<<16 | uint32(toUint16(data[8:]))
+	info.Footprint = toUint16(data[10:])
+	info.CurrentPersonality = data[12]
+	info.PersonalityCount = data[13]
+	info.StartAddress = toUint16(data[14:])
+	info.SubDeviceCount = toUint16(data[16:])
+	info.SensorCount = data[18]
+	return info, nil
+}
+
+// Create the parameter data of 'PID_DEVICE_INFO', see 'ToDeviceInfo'
+func (i DeviceInfo) ToBytes() []byte {
+	return []byte{
+		byte(i.ProtocolVersion >> 8), byte(i.ProtocolVersion),
+		byte(i.Model >> 8), byte(i.Model),
+		byte(i.ProductCategory >> 8), byte(i.ProductCategory),
+		byte(i.SoftwareVersion >> 24), byte(i.SoftwareVersion >> 16), byte(i.SoftwareVersion >> 8), byte(i.SoftwareVersion),
+		byte(i.Footprint >> 8), byte(i.Footprint),
+		i.CurrentPersonality, i.PersonalityCount,
+		byte(i.StartAddress >> 8), byte(i.StartAddress),
+		byte(i.SubDeviceCount >> 8), byte(i.SubDeviceCount),
+		i.SensorCount,
+	}
+}
+
+// Parameter data of 'PID_DMX_PERSONALITY'
+type Personality struct {
+	// Selected DMX personality, counting from 1
+	Current byte
+	// Number of DMX personalities
+	Count byte
+}
+
+// Convert the parameter data of 'PID_DMX_PERSONALITY', the current personality followed by the count
+func ToPersonality(data []byte) (personality Personality, err error) {
+	if len(data) != 2 {
+		return personality, fmt.Errorf("personality must be %d bytes, but was %d", 2, len(data))
+	}
+	return Personality{Current: data[0], Count: data[1]}, nil
+}
+
+// Parameter data of 'PID_SENSOR_VALUE', values are in the unit of the sensor definition
+type SensorValue struct {
+	// Number of the sensor, counting from 0
+	Sensor byte
+	// Current value
+	Present int16
+	// Lowest value detected
+	Lowest int16
+	// Highest value detected
+	Highest int16
+	// Value recorded on request
+	Recorded int16
+}
+
+/*
+Convert the parameter data of 'PID_SENSOR_VALUE'.
+
+Byte layout (MSB first):
+
+0   - Sensor number
+
+1-2 - Present value
+
+3-4 - Lowest detected value
+
+5-6 - Highest detected value
+
+7-8 - Recorded value
+*/
+func ToSensorValue(data []byte) (value SensorValue, err error) {
+	if len(data) != SENSOR_VALUE_SIZE {
+		return value, fmt.Errorf("sensor value must be %d bytes, but was %d", SENSOR_VALUE_SIZE, len(data))
+	}
+	value.Sensor = data[0]
+	value.Present = int16(toUint16(data[1:]))
+	value.Lowest = int16(toUint16(data[3:]))
+	value.Highest = int16(toUint16(data[5:]))
+	value.Recorded = int16(toUint16(data[7:]))
+	return value, nil
+}
+
+// Convert parameter data of 2 bytes, e.g. of 'PID_DMX_START_ADDRESS'
+func ToUint16(data []byte) (uint16, error) {
+	if len(data) != 2 {
+		return 0, fmt.Errorf("parameter data must be %d bytes, but was %d", 2, len(data))
+	}
+	return toUint16(data), nil
+}
+
+// Convert parameter data of 4 bytes, e.g. of 'PID_LAMP_HOURS'
+func ToUint32(data []byte) (uint32, error) {
+	if len(data) != 4 {
+		return 0, fmt.Errorf("parameter data must be %d bytes, but was %d", 4, len(data))
+	}
+	return uint32(toUint16(data))<<16 | uint32(toUint16(data[2:])), nil
+}
+
+// Convert parameter data of a list of PIDs, e.g. of 'PID_SUPPORTED_PARAMETERS'
+func ToPIDs(data []byte) ([]uint16, error) {
+	if len(data)%2 != 0 {
+		return nil, fmt.Errorf("list of PIDs must have an even number of bytes, but had %d", len(data))
+	}
+	pids := make([]uint16, len(data)/2)
+	for i := range pids {
+		pids[i] = toUint16(data[2*i:])
+	}
+	return pids, nil
+}
+
+// Convert parameter data of text, e.g. of 'PID_DEVICE_LABEL', responders may pad with zeros
+func ToLabel(data []byte) (string, error) {
+	if len(data) > MAXIMUM_LABEL_LENGTH {
+		return "", fmt.Errorf("label must be at most %d bytes, but was %d", MAXIMUM_LABEL_LENGTH, len(data))
+	}
+	for i, b := range data {
+		if b == 0 {
+			return string(data[:i]), nil
+		}
+	}
+	return string(data), nil
+}
+
+func toUint16(data []byte) uint16 {
+	return uint16(data[0])<<8 | uint16(data[1])
+}
@@ -0,0 +1,85 @@
+package rdm
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestDeviceInfoRoundTrip(t *testing.T) {
+	info := DeviceInfo{
+		ProtocolVersion:    0x0100,
+		Model:              0x1234,
+		ProductCategory:    0x0101,
+		SoftwareVersion:    0x01020304,
+		Footprint:          16,
+		CurrentPersonality: 2,
+		PersonalityCount:   3,
+		StartAddress:       511,
+		SubDeviceCount:     4,
+		SensorCount:        1,
+	}
+	data := info.ToBytes()
+	if len(data) != DEVICE_INFO_SIZE {
+		t.Fatalf("expected %d bytes, but were %d", DEVICE_INFO_SIZE, len(data))
+	}
+	if !bytes.Equal(data[6:10], []byte{1, 2, 3, 4}) {
+		t.Errorf("expected software version bytes to be %v, but were %v", []byte{1, 2, 3, 4}, data[6:10])
+	}
+	decoded, err := ToDeviceInfo(data)
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if decoded != info {
+		t.Errorf("expected device info to be %+v, but was %+v", info, decoded)
+	}
+	if _, err := ToDeviceInfo(data[1:]); err == nil {
+		t.Errorf("expected an error for too little data")
+	}
+}
+
+func TestToSensorValue(t *testing.T) {
+	value, err := ToSensorValue([]byte{2, 0xFF, 0xF6, 0x00, 0x01, 0x00, 0x02, 0x00, 0x03})
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	expected := SensorValue{Sensor: 2, Present: -10, Lowest: 1, Highest: 2, Recorded: 3}
+	if value != expected {
+		t.Errorf("expected sensor value to be %+v, but was %+v", expected, value)
+	}
+}
+
+func TestToLabel(t *testing.T) {
+	label, err := ToLabel([]byte{'s', 'p', 'o', 't', 0, 0})
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if label != "spot" {
+		t.Errorf("expected label to be 'spot', but was '%s'", label)
+	}
+	if _, err := ToLabel(make([]byte, MAXIMUM_LABEL_LENGTH+1)); err == nil {
+		t.Errorf("expected an error for a label of %d bytes", MAXIMUM_LABEL_LENGTH+1)
+	}
+}
+
+func TestToPIDs(t *testing.T) {
+	pids, err := ToPIDs([]byte{0x00, 0x82, 0x04, 0x01})
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if len(pids) != 2 || pids[0] != PID_DEVICE_LABEL || pids[1] != PID_LAMP_HOURS {
+		t.Errorf("expected PIDs to be %v, but were %v", []uint16{PID_DEVICE_LABEL, PID_LAMP_HOURS}, pids)
+	}
+	if _, err := ToPIDs([]byte{0x00}); err == nil {
+		t.Errorf("expected an error for an odd number of bytes")
+	}
+}
+
+func TestToUint32(t *testing.T) {
+	value, err := ToUint32([]byte{0x00, 0x01, 0x00, 0x02})
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if value != 0x00010002 {
+		t.Errorf("expected value to be %d, but was %d", 0x00010002, value)
+	}
+}
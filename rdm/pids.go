@@ -0,0 +1,75 @@
+package rdm
+
+// Parameter IDs
+const (
+	// Discovery: responders within the given UID range answer, unless muted
+	PID_DISC_UNIQUE_BRANCH = 0x0001
+	// Discovery: stop the responder from answering 'PID_DISC_UNIQUE_BRANCH'
+	PID_DISC_MUTE = 0x0002
+	// Discovery: let the responder answer 'PID_DISC_UNIQUE_BRANCH' again
+	PID_DISC_UN_MUTE = 0x0003
+	// Fetch a queued response, e.g. after 'RESPONSE_TYPE_ACK_TIMER'
+	PID_QUEUED_MESSAGE = 0x0020
+	// List of the optional parameters supported by the device
+	PID_SUPPORTED_PARAMETERS = 0x0050
+	// Description of a parameter specific to the manufacturer
+	PID_PARAMETER_DESCRIPTION = 0x0051
+	// Model, footprint, personality, start address etc. of the device
+	PID_DEVICE_INFO = 0x0060
+	// Product category, e.g. fixture or dimmer
+	PID_PRODUCT_DETAIL_ID_LIST = 0x0070
+	// Description of the device model
+	PID_DEVICE_MODEL_DESCRIPTION = 0x0080
+	// Name of the manufacturer
+	PID_MANUFACTURER_LABEL = 0x0081
+	// User defined name of the device
+	PID_DEVICE_LABEL = 0x0082
+	// Language of text responses
+	PID_LANGUAGE = 0x00B0
+	// Description of the software version
+	PID_SOFTWARE_VERSION_LABEL = 0x00C0
+	// Selected DMX personality (mode) and number of personalities
+	PID_DMX_PERSONALITY = 0x00E0
+	// Footprint and description of a DMX personality
+	PID_DMX_PERSONALITY_DESCRIPTION = 0x00E1
+	// First DMX channel used by the device
+	PID_DMX_START_ADDRESS = 0x00F0
+	// Description of a sensor
+	PID_SENSOR_DEFINITION = 0x0200
+	// Value of a sensor
+	PID_SENSOR_VALUE = 0x0201
+	// Hours the device was powered on
+	PID_DEVICE_HOURS = 0x0400
+	// Hours the lamp was on
+	PID_LAMP_HOURS = 0x0401
+	// Make the device identify itself, e.g. by flashing
+	PID_IDENTIFY_DEVICE = 0x1000
+	// Reset the device
+	PID_RESET_DEVICE = 0x1001
+)
+
+// Reasons for refusing a request, sent with 'RESPONSE_TYPE_NACK_REASON'
+const (
+	// The responder cannot comply with the request because the message is not implemented
+	NACK_REASON_UNKNOWN_PID = 0x0000
+	// The responder cannot interpret the request as the controller data was not formatted correctly
+	NACK_REASON_FORMAT_ERROR = 0x0001
+	// The responder cannot comply due to an internal hardware fault
+	NACK_REASON_HARDWARE_FAULT = 0x0002
+	// Proxy is not the RDM line master and cannot comply with the request
+	NACK_REASON_PROXY_REJECT = 0x0003
+	// SET command normally allowed but being blocked currently
+	NACK_REASON_WRITE_PROTECT = 0x0004
+	// Not valid for the command class attempted
+	NACK_REASON_UNSUPPORTED_COMMAND_CLASS = 0x0005
+	// Value for the given parameter is out of allowable range or not supported
+	NACK_REASON_DATA_OUT_OF_RANGE = 0x0006
+	// Buffer or queue space is full
+	NACK_REASON_BUFFER_FULL = 0x0007
+	// Incoming message exceeds buffer capacity
+	NACK_REASON_PACKET_SIZE_UNSUPPORTED = 0x0008
+	// Sub device is out of range or unknown
+	NACK_REASON_SUB_DEVICE_OUT_OF_RANGE = 0x0009
+	// Proxy buffer is full and cannot store more messages
+	NACK_REASON_PROXY_BUFFER_FULL = 0x000A
+)
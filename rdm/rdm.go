@@ -0,0 +1,210 @@
+/*
+Encoding and decoding of Remote Device Management (ANSI E1.20) messages.
+
+RDM shares the DMX line, requests and responses are told apart from DMX by their start code.
+*/
+package rdm
+
+import (
+	"fmt"
+)
+
+const (
+	// First byte of any RDM message, instead of the DMX start code
+	START_CODE = 0xCC
+	// Second byte of any RDM message
+	SUB_START_CODE = 0x01
+	// Number of bytes before the parameter data
+	NUM_BYTES_BEFORE_PARAMETER_DATA = 24
+	// Number of bytes of the checksum, after the parameter data
+	NUM_BYTES_CHECKSUM = 2
+	// Maximum number of bytes of parameter data
+	MAXIMUM_PARAMETER_DATA_LENGTH = 231
+)
+
+const (
+	// Position of 'START_CODE' in the byte array
+	START_CODE_INDEX = 0
+	// Position of 'SUB_START_CODE' in the byte array
+	SUB_START_CODE_INDEX = 1
+	// Position of the message length, counting from the start code up to the end of the parameter data
+	MESSAGE_LENGTH_INDEX = 2
+	// Position of the destination UID in the byte array
+	DESTINATION_UID_INDEX = 3
+	// Position of the source UID in the byte array
+	SOURCE_UID_INDEX = 9
+	// Position of the transaction number in the byte array
+	TRANSACTION_NUMBER_INDEX = 15
+	// Position of the port ID (requests) or response type (responses) in the byte array
+	PORT_ID_INDEX = 16
+	// Position of the message count in the byte array
+	MESSAGE_COUNT_INDEX = 17
+	// Position of the sub device (MSB first) in the byte array
+	SUB_DEVICE_INDEX = 18
+	// Position of the command class in the byte array
+	COMMAND_CLASS_INDEX = 20
+	// Position of the parameter ID (MSB first) in the byte array
+	PID_INDEX = 21
+	// Position of the parameter data length in the byte array
+	PARAMETER_DATA_LENGTH_INDEX = 23
+)
+
+const (
+	// Discovery request, see 'PID_DISC_UNIQUE_BRANCH', 'PID_DISC_MUTE' and 'PID_DISC_UN_MUTE'
+	DISCOVERY_COMMAND = 0x10
+	// Response to a discovery request
+	DISCOVERY_COMMAND_RESPONSE = 0x11
+	// Request to read a parameter
+	GET_COMMAND = 0x20
+	// Response to a 'GET_COMMAND'
+	GET_COMMAND_RESPONSE = 0x21
+	// Request to change a parameter
+	SET_COMMAND = 0x30
+	// Response to a 'SET_COMMAND'
+	SET_COMMAND_RESPONSE = 0x31
+)
+
+const (
+	// Request was handled, the parameter data holds the result
+	RESPONSE_TYPE_ACK = 0x00
+	// Request will be handled later, the parameter data holds the time to wait in 100ms units
+	RESPONSE_TYPE_ACK_TIMER = 0x01
+	// Request was refused, the parameter data holds the reason (see 'NACK_REASON_UNKNOWN_PID' etc.)
+	RESPONSE_TYPE_NACK_REASON = 0x02
+	// Result is too big for one response, more parts follow
+	RESPONSE_TYPE_ACK_OVERFLOW = 0x03
+)
+
+// Sub device addressing the root device
+const ROOT_DEVICE = 0x0000
+
+// Sub device addressing all sub devices
+const ALL_SUB_DEVICES = 0xFFFF
+
+// RDM request or response
+type Message struct {
+	// Device the message is addressed to
+	Destination UID
+	// Device sending the message
+	Source UID
+	// Counts up with every request, the response repeats the number of the request
+	TransactionNumber byte
+	// Port of the controller in requests, response type in responses (see 'RESPONSE_TYPE_ACK' etc.)
+	PortID byte
+	// Number of messages queued by the responder, 0 in requests
+	MessageCount byte
+	// Sub device addressed, 'ROOT_DEVICE' for the device itself
+	SubDevice uint16
+	// Kind of message, e.g. 'GET_COMMAND'
+	CommandClass byte
+	// Parameter ID, e.g. 'PID_DEVICE_INFO'
+	PID uint16
+	// Payload, up to 231 bytes
+	ParameterData []byte
+}
+
+// Returns the response type (see 'RESPONSE_TYPE_ACK' etc.), only meaningful in responses
+func (m *Message) GetResponseType() byte {
+	return m.PortID
+}
+
+// Is the message a response (as opposed to a request)
+func (m *Message) IsResponse() bool {
+	return m.CommandClass&0x01 == 0x01
+}
+
+// Convert the message into bytes as sent on the DMX line, beginning with the start code and ending with the checksum
+func (m *Message) ToBytes() ([]byte, error) {
+	dataLength := len(m.ParameterData)
+	if dataLength > MAXIMUM_PARAMETER_DATA_LENGTH {
+		return nil, fmt.Errorf("maximum parameter data length [%d bytes] exceeded, actually was [%d]", MAXIMUM_PARAMETER_DATA_LENGTH, dataLength)
+	}
+	messageLength := NUM_BYTES_BEFORE_PARAMETER_DATA + dataLength
+	packet := make([]byte, messageLength+NUM_BYTES_CHECKSUM)
+	packet[START_CODE_INDEX] = START_CODE
+	packet[SUB_START_CODE_INDEX] = SUB_START_CODE
+	packet[MESSAGE_LENGTH_INDEX] = byte(messageLength)
+	copy(packet[DESTINATION_UID_INDEX:], m.Destination.ToBytes())
+	copy(packet[SOURCE_UID_INDEX:], m.Source.ToBytes())
+	packet[TRANSACTION_NUMBER_INDEX] = m.TransactionNumber
+	packet[PORT_ID_INDEX] = m.PortID
+	packet[MESSAGE_COUNT_INDEX] = m.MessageCount
+	packet[SUB_DEVICE_INDEX] = byte(m.SubDevice >> 8)
+	packet[SUB_DEVICE_INDEX+1] = byte(m.SubDevice)
+	packet[COMMAND_CLASS_INDEX] = m.CommandClass
+	packet[PID_INDEX] = byte(m.PID >> 8)
+	packet[PID_INDEX+1] = byte(m.PID)
+	packet[PARAMETER_DATA_LENGTH_INDEX] = byte(dataLength)
+	copy(packet[NUM_BYTES_BEFORE_PARAMETER_DATA:], m.ParameterData)
+	checksum := Checksum(packet[:messageLength])
+	packet[messageLength] = byte(checksum >> 8)
+	packet[messageLength+1] = byte(checksum)
+	return packet, nil
+}
+
+/*
+Create from the bytes as received on the DMX line, beginning with the start code.
+
+Bytes after the checksum are ignored.
+*/
+func FromBytes(raw []byte) (msg Message, err error) {
+	if len(raw) < NUM_BYTES_BEFORE_PARAMETER_DATA+NUM_BYTES_CHECKSUM {
+		return msg, fmt.Errorf("message of size %d bytes is too small - must be at least %d bytes", len(raw), NUM_BYTES_BEFORE_PARAMETER_DATA+NUM_BYTES_CHECKSUM)
+	}
+	if raw[START_CODE_INDEX] != START_CODE {
+		return msg, fmt.Errorf("message must start with %X, but is %X", START_CODE, raw[START_CODE_INDEX])
+	}
+	if raw[SUB_START_CODE_INDEX] != SUB_START_CODE {
+		return msg, fmt.Errorf("sub start code must be %X, but is %X", SUB_START_CODE, raw[SUB_START_CODE_INDEX])
+	}
+	messageLength := int(raw[MESSAGE_LENGTH_INDEX])
+	dataLength := int(raw[PARAMETER_DATA_LENGTH_INDEX])
+	if messageLength != NUM_BYTES_BEFORE_PARAMETER_DATA+dataLength {
+		return msg, fmt.Errorf("message length %d does not match parameter data length %d", messageLength, dataLength)
+	}
+	if len(raw) < messageLength+NUM_BYTES_CHECKSUM {
+		return msg, fmt.Errorf("message declared length as %d bytes, but is %d", messageLength+NUM_BYTES_CHECKSUM, len(raw))
+	}
+	expected := Checksum(raw[:messageLength])
+	actual := uint16(raw[messageLength])<<8 | uint16(raw[messageLength+1])
+	if expected != actual {
+		return msg, fmt.Errorf("checksum should be %04X, but was %04X", expected, actual)
+	}
+	msg.Destination, _ = UIDFromBytes(raw[DESTINATION_UID_INDEX : DESTINATION_UID_INDEX+UID_SIZE])
+	msg.Source, _ = UIDFromBytes(raw[SOURCE_UID_INDEX : SOURCE_UID_INDEX+UID_SIZE])
+	msg.TransactionNumber = raw[TRANSACTION_NUMBER_INDEX]
+	msg.PortID = raw[PORT_ID_INDEX]
+	msg.MessageCount = raw[MESSAGE_COUNT_INDEX]
+	msg.SubDevice = uint16(raw[SUB_DEVICE_INDEX])<<8 | uint16(raw[SUB_DEVICE_INDEX+1])
+	msg.CommandClass = raw[COMMAND_CLASS_INDEX]
+	msg.PID = uint16(raw[PID_INDEX])<<8 | uint16(raw[PID_INDEX+1])
+	msg.ParameterData = append([]byte{}, raw[NUM_BYTES_BEFORE_PARAMETER_DATA:messageLength]...)
+	return msg, nil
+}
+
+// Sum of all bytes, as used for the RDM checksum
+func Checksum(data []byte) uint16 {
+	sum := uint16(0)
+	for _, b := range data {
+		sum += uint16(b)
+	}
+	return sum
+}
+
+/*
+Create the response to the request, addressed back to its source.
+
+The command class is the response class of the request's.
+*/
+func NewResponse(request Message, responseType byte, parameterData []byte) Message {
+	return Message{
+		Destination:       request.Source,
+		Source:            request.Destination,
+		TransactionNumber: request.TransactionNumber,
+		PortID:            responseType,
+		SubDevice:         request.SubDevice,
+		CommandClass:      request.CommandClass | 0x01,
+		PID:               request.PID,
+		ParameterData:     parameterData,
+	}
+}
@@ -0,0 +1,100 @@
+package rdm
+
+import (
+	"bytes"
+	"testing"
+)
+
+func newTestRequest() Message {
+	return Message{
+		Destination:       NewUID(0x1234, 0x56789ABC),
+		Source:            NewUID(0x454E, 1),
+		TransactionNumber: 7,
+		PortID:            1,
+		SubDevice:         ROOT_DEVICE,
+		CommandClass:      SET_COMMAND,
+		PID:               PID_DMX_START_ADDRESS,
+		ParameterData:     []byte{0x00, 0x45},
+	}
+}
+
+func TestMessageToBytes(t *testing.T) {
+	msg := newTestRequest()
+	raw, err := msg.ToBytes()
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	expected := []byte{
+		0xCC, 0x01, 26,
+		0x12, 0x34, 0x56, 0x78, 0x9A, 0xBC,
+		0x45, 0x4E, 0x00, 0x00, 0x00, 0x01,
+		7, 1, 0, 0x00, 0x00,
+		SET_COMMAND, 0x00, 0xF0, 2, 0x00, 0x45,
+	}
+	sum := 0
+	for _, b := range expected {
+		sum += int(b)
+	}
+	expected = append(expected, byte(sum>>8), byte(sum))
+	if !bytes.Equal(raw, expected) {
+		t.Errorf("expected bytes to be %X, but were %X", expected, raw)
+	}
+}
+
+func TestMessageRoundTrip(t *testing.T) {
+	msg := newTestRequest()
+	raw, _ := msg.ToBytes()
+	// Trailing bytes are ignored
+	decoded, err := FromBytes(append(raw, 0x00))
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if decoded.Destination != msg.Destination || decoded.Source != msg.Source || decoded.TransactionNumber != msg.TransactionNumber ||
+		decoded.PortID != msg.PortID || decoded.CommandClass != msg.CommandClass || decoded.PID != msg.PID {
+		t.Errorf("expected message to be %+v, but was %+v", msg, decoded)
+	}
+	if !bytes.Equal(decoded.ParameterData, msg.ParameterData) {
+		t.Errorf("expected parameter data to be %v, but was %v", msg.ParameterData, decoded.ParameterData)
+	}
+}
+
+func TestMessageFromBytesFails(t *testing.T) {
+	msg := newTestRequest()
+	valid, _ := msg.ToBytes()
+	invalid := map[string]func(raw []byte) []byte{
+		"too short":        func(raw []byte) []byte { return raw[:20] },
+		"wrong start code": func(raw []byte) []byte { raw[0] = 0x00; return raw },
+		"wrong sub start":  func(raw []byte) []byte { raw[1] = 0x02; return raw },
+		"wrong length":     func(raw []byte) []byte { raw[2] = 30; return raw },
+		"truncated":        func(raw []byte) []byte { return raw[:len(raw)-1] },
+		"wrong checksum":   func(raw []byte) []byte { raw[len(raw)-1]++; return raw },
+	}
+	for name, corrupt := range invalid {
+		raw := corrupt(append([]byte{}, valid...))
+		if _, err := FromBytes(raw); err == nil {
+			t.Errorf("expected an error for '%s'", name)
+		}
+	}
+}
+
+func TestMessageToBytesTooMuchData(t *testing.T) {
+	msg := newTestRequest()
+	msg.ParameterData = make([]byte, MAXIMUM_PARAMETER_DATA_LENGTH+1)
+	if _, err := msg.ToBytes(); err == nil {
+		t.Errorf("expected an error for too much parameter data")
+	}
+}
+
+func TestNewResponse(t *testing.T) {
+	request := newTestRequest()
+	response := NewResponse(request, RESPONSE_TYPE_ACK, []byte{0x00, 0x45})
+	if response.Destination != request.Source || response.Source != request.Destination {
+		t.Errorf("expected response to be addressed back, but was from %v to %v", response.Source, response.Destination)
+	}
+	if response.CommandClass != SET_COMMAND_RESPONSE || !response.IsResponse() {
+		t.Errorf("expected command class to be %X, but was %X", SET_COMMAND_RESPONSE, response.CommandClass)
+	}
+	if response.TransactionNumber != request.TransactionNumber {
+		t.Errorf("expected transaction number to be %d, but was %d", request.TransactionNumber, response.TransactionNumber)
+	}
+}
@@ -0,0 +1,93 @@
+package rdm
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Unique ID of an RDM device, the manufacturer ID in the upper 16 bits and the device ID in the lower 32 bits
+type UID uint64
+
+const (
+	// Addresses all devices
+	BROADCAST_UID UID = 0xFFFFFFFFFFFF
+	// Lowest UID a device can have
+	MINIMUM_UID UID = 0x000000000000
+	// Highest UID a device can have
+	MAXIMUM_UID UID = 0xFFFFFFFFFFFE
+	// Number of bytes of an encoded UID
+	UID_SIZE = 6
+)
+
+// Helper function for creating a UID from manufacturer and device ID
+func NewUID(manufacturer uint16, device uint32) UID {
+	return UID(uint64(manufacturer)<<32 | uint64(device))
+}
+
+// Returns the UID addressing all devices of the manufacturer
+func ManufacturerBroadcastUID(manufacturer uint16) UID {
+	return NewUID(manufacturer, 0xFFFFFFFF)
+}
+
+/*
+Parse a UID in the usual notation of manufacturer and device ID in hex.
+
+e.g. "454E:00000001"
+*/
+func ParseUID(s string) (UID, error) {
+	manufacturer, device, found := strings.Cut(s, ":")
+	if !found {
+		return 0, fmt.Errorf("UID '%s' must be of the form 'MMMM:DDDDDDDD'", s)
+	}
+	m, err := strconv.ParseUint(manufacturer, 16, 16)
+	if err != nil {
+		return 0, fmt.Errorf("invalid manufacturer ID in UID '%s', %v", s, err)
+	}
+	d, err := strconv.ParseUint(device, 16, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid device ID in UID '%s', %v", s, err)
+	}
+	return NewUID(uint16(m), uint32(d)), nil
+}
+
+// Returns the ESTA manufacturer ID
+func (u UID) GetManufacturer() uint16 {
+	return uint16(u >> 32)
+}
+
+// Returns the device ID
+func (u UID) GetDevice() uint32 {
+	return uint32(u)
+}
+
+// Is this UID addressing all devices, or all devices of a manufacturer
+func (u UID) IsBroadcast() bool {
+	return u.GetDevice() == 0xFFFFFFFF
+}
+
+// Returns the UID in the usual notation, e.g. "454E:00000001"
+func (u UID) String() string {
+	return fmt.Sprintf("%04X:%08X", u.GetManufacturer(), u.GetDevice())
+}
+
+// Encode the UID as 6 bytes, most significant byte first
+func (u UID) ToBytes() []byte {
+	out := make([]byte, UID_SIZE)
+	for i := 0; i < UID_SIZE; i++ {
+		out[i] = byte(u >> (8 * (UID_SIZE - 1 - i)))
+	}
+	return out
+}
+
+// Decode a UID from 6 bytes, most significant byte first
+func UIDFromBytes(raw []byte) (UID, error) {
+	if len(raw) != UID_SIZE {
+		return 0, fmt.Errorf("UID must be '%d' bytes, but was '%d'", UID_SIZE, len(raw))
+	}
+	u := UID(0)
+	for _, b := range raw {
+		u = u<<8 | UID(b)
+	}
+	return u, nil
+}
@@ -0,0 +1,59 @@
+package rdm
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestUIDParts(t *testing.T) {
+	uid := NewUID(0x454E, 0x12345678)
+	if uid.GetManufacturer() != 0x454E {
+		t.Errorf("expected manufacturer to be %04X, but was %04X", 0x454E, uid.GetManufacturer())
+	}
+	if uid.GetDevice() != 0x12345678 {
+		t.Errorf("expected device to be %08X, but was %08X", 0x12345678, uid.GetDevice())
+	}
+	if uid.String() != "454E:12345678" {
+		t.Errorf("expected string to be '454E:12345678', but was '%s'", uid.String())
+	}
+}
+
+func TestParseUID(t *testing.T) {
+	uid, err := ParseUID("454e:00000001")
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if uid != NewUID(0x454E, 1) {
+		t.Errorf("expected UID to be %v, but was %v", NewUID(0x454E, 1), uid)
+	}
+	for _, invalid := range []string{"", "454E", "454E:", "GGGG:00000001", "12345:00000001", "454E:123456789"} {
+		if _, err := ParseUID(invalid); err == nil {
+			t.Errorf("expected an error parsing '%s'", invalid)
+		}
+	}
+}
+
+func TestUIDBytes(t *testing.T) {
+	uid := NewUID(0x454E, 0x12345678)
+	raw := uid.ToBytes()
+	expected := []byte{0x45, 0x4E, 0x12, 0x34, 0x56, 0x78}
+	if !bytes.Equal(raw, expected) {
+		t.Errorf("expected bytes to be %X, but were %X", expected, raw)
+	}
+	decoded, err := UIDFromBytes(raw)
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if decoded != uid {
+		t.Errorf("expected UID to be %v, but was %v", uid, decoded)
+	}
+}
+
+func TestUIDBroadcast(t *testing.T) {
+	if !BROADCAST_UID.IsBroadcast() || !ManufacturerBroadcastUID(0x454E).IsBroadcast() {
+		t.Errorf("expected broadcast UIDs to be broadcasts")
+	}
+	if NewUID(0x454E, 1).IsBroadcast() {
+		t.Errorf("expected %v not to be a broadcast", NewUID(0x454E, 1))
+	}
+}
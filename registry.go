@@ -0,0 +1,145 @@
+package usbdmxgolang
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// Creates a controller from the URI it was opened with, without connecting it
+type DriverFactory func(uri ControllerURI) (DMXController, error)
+
+var (
+	driversMu sync.RWMutex
+	drivers   = make(map[string]DriverFactory)
+)
+
+/*
+Make a controller package available to 'Open' under the given URI scheme.
+
+Controller packages register themselves when imported, so applications import the ones they support:
+
+	import _ "github.com/H3rby7/usbdmx-golang/controller/enttec/dmxusbpro"
+
+Panics if the scheme is registered twice or the factory is nil.
+*/
+func Register(scheme string, factory DriverFactory) {
+	driversMu.Lock()
+	defer driversMu.Unlock()
+	if factory == nil {
+		log.Panicf("usbdmxgolang: factory of driver '%s' is nil", scheme)
+	}
+	if _, duplicate := drivers[scheme]; duplicate {
+		log.Panicf("usbdmxgolang: driver '%s' is registered twice", scheme)
+	}
+	drivers[scheme] = factory
+}
+
+// Returns the registered URI schemes, sorted
+func Drivers() []string {
+	driversMu.RLock()
+	defer driversMu.RUnlock()
+	schemes := make([]string, 0, len(drivers))
+	for scheme := range drivers {
+		schemes = append(schemes, scheme)
+	}
+	sort.Strings(schemes)
+	return schemes
+}
+
+/*
+Create a controller from a URI, the scheme selects the driver.
+
+The controller is not connected yet.
+
+Example useage:
+
+	controller, err := usbdmxgolang.Open("enttec-pro:///dev/ttyUSB0?channels=512&mode=write")
+	if err != nil { ... }
+	err = controller.Connect()
+*/
+func Open(uri string) (DMXController, error) {
+	parsed, err := ParseURI(uri)
+	if err != nil {
+		return nil, err
+	}
+	driversMu.RLock()
+	factory, ok := drivers[parsed.Scheme]
+	driversMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown driver '%s', registered are %v", parsed.Scheme, Drivers())
+	}
+	return factory(parsed)
+}
+
+// URI describing a controller, e.g. "enttec-pro:///dev/ttyUSB0?channels=512&mode=write"
+type ControllerURI struct {
+	// Selects the driver, e.g. "enttec-pro"
+	Scheme string
+	// Device to open, e.g. "/dev/ttyUSB0" or "COM4", may be empty
+	Device string
+	// Driver specific parameters
+	Params url.Values
+}
+
+/*
+Split the URI into scheme, device and parameters.
+
+The device may follow the scheme with or without slashes, e.g. "enttec-pro:///dev/ttyUSB0", "enttec-pro://COM4" or "enttec-pro:COM4".
+*/
+func ParseURI(uri string) (ControllerURI, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return ControllerURI{}, fmt.Errorf("invalid controller URI '%s', %v", uri, err)
+	}
+	if u.Scheme == "" {
+		return ControllerURI{}, fmt.Errorf("controller URI '%s' has no scheme", uri)
+	}
+	device := u.Opaque
+	if device == "" {
+		device = u.Host + u.Path
+	}
+	return ControllerURI{Scheme: u.Scheme, Device: device, Params: u.Query()}, nil
+}
+
+// Return an error naming the first parameter not allowed, to catch typos
+func (u ControllerURI) CheckParams(allowed ...string) error {
+	known := make(map[string]bool)
+	for _, name := range allowed {
+		known[name] = true
+	}
+	names := make([]string, 0, len(u.Params))
+	for name := range u.Params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if !known[name] {
+			return fmt.Errorf("unknown parameter '%s' for driver '%s', allowed are %v", name, u.Scheme, allowed)
+		}
+	}
+	return nil
+}
+
+// Returns the parameter, or the fallback if not given
+func (u ControllerURI) GetString(name string, fallback string) string {
+	if !u.Params.Has(name) {
+		return fallback
+	}
+	return u.Params.Get(name)
+}
+
+// Returns the parameter as integer, or the fallback if not given
+func (u ControllerURI) GetInt(name string, fallback int) (int, error) {
+	if !u.Params.Has(name) {
+		return fallback, nil
+	}
+	value, err := strconv.Atoi(u.Params.Get(name))
+	if err != nil {
+		return 0, fmt.Errorf("parameter '%s' must be a number, but was '%s'", name, u.Params.Get(name))
+	}
+	return value, nil
+}
@@ -0,0 +1,94 @@
+package usbdmxgolang
+
+import (
+	"testing"
+)
+
+// Controller doing nothing, remembering the URI it was opened with
+type fakeController struct {
+	DMXController
+	uri ControllerURI
+}
+
+func registerFake(t *testing.T, scheme string) {
+	Register(scheme, func(uri ControllerURI) (DMXController, error) {
+		return &fakeController{uri: uri}, nil
+	})
+	t.Cleanup(func() {
+		driversMu.Lock()
+		defer driversMu.Unlock()
+		delete(drivers, scheme)
+	})
+}
+
+func TestOpenUsesRegisteredDriver(t *testing.T) {
+	registerFake(t, "fake")
+	controller, err := Open("fake:///dev/ttyUSB0?channels=16&mode=read")
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	uri := controller.(*fakeController).uri
+	if uri.Scheme != "fake" {
+		t.Errorf("expected scheme to be 'fake', but was '%s'", uri.Scheme)
+	}
+	if uri.Device != "/dev/ttyUSB0" {
+		t.Errorf("expected device to be '/dev/ttyUSB0', but was '%s'", uri.Device)
+	}
+	if channels, _ := uri.GetInt("channels", 512); channels != 16 {
+		t.Errorf("expected channels to be 16, but was %d", channels)
+	}
+	if mode := uri.GetString("mode", "write"); mode != "read" {
+		t.Errorf("expected mode to be 'read', but was '%s'", mode)
+	}
+}
+
+func TestOpenUnknownDriver(t *testing.T) {
+	if _, err := Open("unknown:///dev/ttyUSB0"); err == nil {
+		t.Errorf("expected error for an unknown driver")
+	}
+	if _, err := Open("/dev/ttyUSB0"); err == nil {
+		t.Errorf("expected error for a URI without scheme")
+	}
+}
+
+func TestParseURIDevice(t *testing.T) {
+	cases := map[string]string{
+		"fake:///dev/ttyUSB0": "/dev/ttyUSB0",
+		"fake://COM4":         "COM4",
+		"fake:COM4":           "COM4",
+		"fake:?serial=1":      "",
+	}
+	for uri, expected := range cases {
+		parsed, err := ParseURI(uri)
+		if err != nil {
+			t.Errorf("expected no error for '%s', but got %v", uri, err)
+			continue
+		}
+		if parsed.Device != expected {
+			t.Errorf("expected device of '%s' to be '%s', but was '%s'", uri, expected, parsed.Device)
+		}
+	}
+}
+
+func TestControllerURIParams(t *testing.T) {
+	uri, _ := ParseURI("fake:///dev/ttyUSB0?channels=abc&chanels=1")
+	if err := uri.CheckParams("channels"); err == nil {
+		t.Errorf("expected error for the misspelled parameter")
+	}
+	if _, err := uri.GetInt("channels", 512); err == nil {
+		t.Errorf("expected error for a parameter not being a number")
+	}
+	if value, _ := uri.GetInt("missing", 7); value != 7 {
+		t.Errorf("expected fallback 7, but was %d", value)
+	}
+}
+
+func TestRegisterTwicePanics(t *testing.T) {
+	registerFake(t, "twice")
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected a panic registering a driver twice")
+		}
+	}()
+	registerFake(t, "twice")
+}